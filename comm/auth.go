@@ -0,0 +1,114 @@
+package comm
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	authKeyMetadata = "garage-auth"
+	passMetadata    = "garage-pass"
+)
+
+// KeyCredentials sends an auth key with every request.
+type KeyCredentials string
+
+func (k KeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authKeyMetadata: string(k)}, nil
+}
+
+func (k KeyCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// PassCredentials sends a guest pass token with every request.
+type PassCredentials string
+
+func (p PassCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{passMetadata: string(p)}, nil
+}
+
+func (p PassCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+// PassFromContext returns the guest pass token sent with a request, if any.
+func PassFromContext(ctx context.Context) string {
+	md, _ := metadata.FromContext(ctx)
+	if v := md[passMetadata]; len(v) != 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// NewContext returns ctx as if a request was sent with KeyCredentials
+// of key and PassCredentials of pass, for requests that did not come
+// over gRPC. Empty values are left out.
+func NewContext(ctx context.Context, key, pass string) context.Context {
+	md := metadata.MD{}
+	if len(key) != 0 {
+		md[authKeyMetadata] = []string{key}
+	}
+	if len(pass) != 0 {
+		md[passMetadata] = []string{pass}
+	}
+	return metadata.NewContext(ctx, md)
+}
+
+// CheckKey returns an Unauthenticated error unless the request was sent
+// with KeyCredentials matching key.
+func CheckKey(ctx context.Context, key string) error {
+	md, _ := metadata.FromContext(ctx)
+	for _, v := range md[authKeyMetadata] {
+		if subtle.ConstantTimeCompare([]byte(v), []byte(key)) == 1 {
+			return nil
+		}
+	}
+	return grpc.Errorf(codes.Unauthenticated, "missing or invalid auth key")
+}
+
+// CertRole returns the common name and role of the client certificate
+// sent with a request, if one was sent. The role is the first
+// organizational unit of the certificate, NO_ROLE when it is missing or
+// unknown. The certificate must have been verified during the handshake.
+func CertRole(ctx context.Context) (name string, role Role, ok bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", Role_NO_ROLE, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return "", Role_NO_ROLE, false
+	}
+	cert := info.State.PeerCertificates[0]
+	if ou := cert.Subject.OrganizationalUnit; len(ou) != 0 {
+		role = Role(Role_value[strings.ToUpper(ou[0])])
+	}
+	return cert.Subject.CommonName, role, true
+}
+
+// KeyInterceptors return server options that require every request
+// to carry the auth key.
+func KeyInterceptors(key string) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := CheckKey(ctx, key); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := CheckKey(ss.Context(), key); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
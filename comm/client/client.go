@@ -0,0 +1,392 @@
+// Package client connects to the garage mirror, or to the local API of an
+// opener, and keeps track of whether a door opener can be reached.
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+)
+
+// Config configures a connection. The zero value connects to the
+// compiled in mirror address without an auth key.
+type Config struct {
+	// Addr is the host:port to connect to. The compiled in mirror
+	// address is used when it is empty.
+	Addr string
+	// CA is the PEM encoded certificate that signed the server certificate.
+	// The compiled in CA is used when it is empty and no Pin is set.
+	CA []byte
+	// Pin is the SHA-256 fingerprint of the server certificate in hex,
+	// optionally separated by colons. When set, the server certificate
+	// must match it and CA is not used.
+	Pin string
+	// Key is the auth key sent with every request. No key is sent when empty.
+	Key string
+	// Cert and CertKey are the PEM encoded client certificate and its key,
+	// such as from Enroll. No client certificate is sent when empty.
+	Cert, CertKey []byte
+	// Pass is a guest pass token sent with every request, if set.
+	Pass string
+
+	// PollInterval is how often the connection is checked while it works.
+	PollInterval time.Duration
+	// MaxBackoff is the longest wait between checks while it does not work.
+	MaxBackoff time.Duration
+	// Timeout limits each check and each request that has no deadline.
+	Timeout time.Duration
+
+	// OnState is called with the new state each time it changes.
+	// It must not block.
+	OnState func(s State, err error)
+}
+
+func (c Config) withDefaults() Config {
+	if len(c.Addr) == 0 {
+		c.Addr = fmt.Sprintf("%s:%d", comm.Host(), comm.Port())
+	}
+	if len(c.CA) == 0 && len(c.Pin) == 0 {
+		c.CA = comm.CA()
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = 2500 * time.Millisecond
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 30 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	return c
+}
+
+// DialOptions returns the options to dial the server described by c.
+func (c Config) DialOptions() ([]grpc.DialOption, error) {
+	c = c.withDefaults()
+	config := &tls.Config{}
+	if len(c.Pin) != 0 {
+		pin, err := hex.DecodeString(strings.Replace(c.Pin, ":", "", -1))
+		if err != nil || len(pin) != sha256.Size {
+			return nil, fmt.Errorf("pin %q is not a SHA-256 fingerprint", c.Pin)
+		}
+		// The pin replaces the usual chain and host name checks.
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("server sent no certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if subtle.ConstantTimeCompare(sum[:], pin) != 1 {
+				return fmt.Errorf("server certificate %x does not match the pin", sum)
+			}
+			return nil
+		}
+	} else {
+		certpool := x509.NewCertPool()
+		if !certpool.AppendCertsFromPEM(c.CA) { // Add CA public cert.
+			return nil, errors.New("failed to add cert")
+		}
+		config.RootCAs = certpool
+	}
+	if len(c.Cert) != 0 {
+		cert, err := tls.X509KeyPair(c.Cert, c.CertKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client cert: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(credentials.NewTLS(config)),
+		grpc.WithBackoffMaxDelay(c.MaxBackoff),
+	}
+	if len(c.Key) != 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(comm.KeyCredentials(c.Key)))
+	}
+	if len(c.Pass) != 0 {
+		opts = append(opts, grpc.WithPerRPCCredentials(comm.PassCredentials(c.Pass)))
+	}
+	return opts, nil
+}
+
+// Dial connects to the server described by c. Extra options, such as
+// grpc.WithBlock, are applied after those from c.
+func Dial(ctx context.Context, c Config, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	c = c.withDefaults()
+	base, err := c.DialOptions()
+	if err != nil {
+		return nil, err
+	}
+	return grpc.DialContext(ctx, c.Addr, append(base, opts...)...)
+}
+
+// State is the state of a Client connection.
+type State int
+
+const (
+	// Connecting is the state until the first check completes.
+	Connecting State = iota
+	// Connected means the server answered and a door opener is online.
+	Connected
+	// NoOpener means the server answered but no door opener is online.
+	NoOpener
+	// Disconnected means the server could not be reached.
+	Disconnected
+)
+
+func (s State) String() string {
+	switch s {
+	case Connecting:
+		return "connecting"
+	case Connected:
+		return "connected"
+	case NoOpener:
+		return "no opener"
+	case Disconnected:
+		return "disconnected"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// ErrNoOpener is reported with the NoOpener state.
+var ErrNoOpener = errors.New("no door opener is connected")
+
+// Client is a connection that is checked in the background. The
+// connection is retried with a growing delay while it fails.
+type Client struct {
+	c    Config
+	conn *grpc.ClientConn
+	gc   comm.GarageClient
+
+	cancel func()
+	done   chan struct{}
+	check  chan struct{}
+
+	mu    sync.Mutex
+	state State
+	err   error
+}
+
+// New starts a client for c. Call Close when done.
+func New(c Config) (*Client, error) {
+	c = c.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := Dial(ctx, c)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	cl := &Client{
+		c:      c,
+		conn:   conn,
+		gc:     comm.NewGarageClient(conn),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		check:  make(chan struct{}, 1),
+	}
+	go cl.run(ctx)
+	return cl, nil
+}
+
+// Close stops checking the connection and closes it.
+func (cl *Client) Close() error {
+	cl.cancel()
+	<-cl.done
+	return cl.conn.Close()
+}
+
+// Garage returns the client for RPCs without a helper.
+func (cl *Client) Garage() comm.GarageClient {
+	return cl.gc
+}
+
+// State returns the current state and the error that caused it.
+func (cl *Client) State() (State, error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.state, cl.err
+}
+
+func (cl *Client) setState(s State, err error) {
+	cl.mu.Lock()
+	changed := s != cl.state || (err == nil) != (cl.err == nil) || (err != nil && err.Error() != cl.err.Error())
+	cl.state, cl.err = s, err
+	cl.mu.Unlock()
+	if changed && cl.c.OnState != nil {
+		cl.c.OnState(s, err)
+	}
+}
+
+// recheck checks the connection now, such as after a request failed.
+func (cl *Client) recheck() {
+	select {
+	case cl.check <- struct{}{}:
+	default:
+	}
+}
+
+func (cl *Client) run(ctx context.Context) {
+	defer close(cl.done)
+	failures := 0
+	for {
+		state, err := cl.probe(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		cl.setState(state, err)
+
+		delay := cl.c.PollInterval
+		if state == Disconnected {
+			delay = backoff(failures, cl.c.MaxBackoff)
+			failures++
+		} else {
+			failures = 0
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-cl.check:
+		case <-t.C:
+		}
+		t.Stop()
+	}
+}
+
+// probe asks the server which doors are online.
+func (cl *Client) probe(ctx context.Context) (State, error) {
+	ctx, cancel := context.WithTimeout(ctx, cl.c.Timeout)
+	defer cancel()
+	resp, err := cl.gc.Doors(ctx, &comm.DoorsReq{})
+	if err != nil {
+		return Disconnected, err
+	}
+	for _, d := range resp.Doors {
+		if d.Online {
+			return Connected, nil
+		}
+	}
+	return NoOpener, ErrNoOpener
+}
+
+// backoff returns the delay before the next check after failures
+// failed checks in a row, with some jitter.
+func backoff(failures int, max time.Duration) time.Duration {
+	d := 500 * time.Millisecond
+	for i := 0; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	// Spread out clients that lost the server at the same time.
+	return d - time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+// withTimeout applies the configured timeout if ctx has no deadline.
+func (cl *Client) withTimeout(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, cl.c.Timeout)
+}
+
+// result rechecks the connection if err means the server or opener
+// could not be reached.
+func (cl *Client) result(err error) error {
+	switch grpc.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		cl.recheck()
+	}
+	return err
+}
+
+// Ping checks a door opener is connected.
+func (cl *Client) Ping(ctx context.Context) error {
+	ctx, cancel := cl.withTimeout(ctx)
+	defer cancel()
+	_, err := cl.gc.Ping(ctx, &comm.PingReq{TimeUnix: time.Now().Unix()})
+	return cl.result(err)
+}
+
+// Command sends action to door. The door may be empty when only one door is
+// known. The returned message notes why no press was needed, if any.
+func (cl *Client) Command(ctx context.Context, door string, action comm.Action) (string, error) {
+	ctx, cancel := cl.withTimeout(ctx)
+	defer cancel()
+	resp, err := cl.gc.Toggle(ctx, &comm.ToggleReq{TimeUnix: time.Now().Unix(), Action: action, Door: door})
+	if err != nil {
+		return "", cl.result(err)
+	}
+	return resp.Message, nil
+}
+
+// Toggle presses the door button.
+func (cl *Client) Toggle(ctx context.Context, door string) (string, error) {
+	return cl.Command(ctx, door, comm.Action_TOGGLE)
+}
+
+// OpenDoor opens the door unless it is already open.
+func (cl *Client) OpenDoor(ctx context.Context, door string) (string, error) {
+	return cl.Command(ctx, door, comm.Action_OPEN_DOOR)
+}
+
+// CloseDoor closes the door unless it is already closed.
+func (cl *Client) CloseDoor(ctx context.Context, door string) (string, error) {
+	return cl.Command(ctx, door, comm.Action_CLOSE_DOOR)
+}
+
+// Status returns the status of door.
+func (cl *Client) Status(ctx context.Context, door string) (*comm.StatusResp, error) {
+	ctx, cancel := cl.withTimeout(ctx)
+	defer cancel()
+	resp, err := cl.gc.Status(ctx, &comm.StatusReq{Door: door})
+	return resp, cl.result(err)
+}
+
+// Doors lists the known doors.
+func (cl *Client) Doors(ctx context.Context) ([]*comm.Door, error) {
+	ctx, cancel := cl.withTimeout(ctx)
+	defer cancel()
+	resp, err := cl.gc.Doors(ctx, &comm.DoorsReq{})
+	if err != nil {
+		return nil, cl.result(err)
+	}
+	return resp.Doors, nil
+}
+
+// History returns up to limit recent events for door, oldest first.
+// An empty door returns events for all doors.
+func (cl *Client) History(ctx context.Context, door string, limit int) ([]*comm.Event, error) {
+	ctx, cancel := cl.withTimeout(ctx)
+	defer cancel()
+	resp, err := cl.gc.History(ctx, &comm.HistoryReq{Door: door, Limit: int32(limit)})
+	if err != nil {
+		return nil, cl.result(err)
+	}
+	return resp.Events, nil
+}
+
+// Watch streams events for door until ctx is done.
+// An empty door watches all doors.
+func (cl *Client) Watch(ctx context.Context, door string) (comm.Garage_WatchClient, error) {
+	wc, err := cl.gc.Watch(ctx, &comm.WatchReq{Door: door})
+	return wc, cl.result(err)
+}
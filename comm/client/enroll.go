@@ -0,0 +1,90 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// Enrolled is the result of Enroll.
+type Enrolled struct {
+	// Cert and CertKey are the PEM encoded client certificate and its key.
+	Cert, CertKey []byte
+	// CA is the PEM encoded CA certificate that signed Cert.
+	CA   []byte
+	Name string
+	Role comm.Role
+}
+
+// Enroll creates a key and exchanges the pairing code for a client
+// certificate from the server described by c. The key never leaves
+// this device.
+func Enroll(ctx context.Context, c Config, code string) (*Enrolled, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	// The server sets the subject from the pairing code.
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "garage"}}, key)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c = c.withDefaults()
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	conn, err := Dial(ctx, c, grpc.WithBlock())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	resp, err := comm.NewGarageClient(conn).Enroll(ctx, &comm.EnrollReq{Code: code, CSR: csr})
+	if err != nil {
+		return nil, err
+	}
+	return &Enrolled{
+		Cert:    resp.Cert,
+		CertKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		CA:      resp.CA,
+		Name:    resp.Name,
+		Role:    resp.Role,
+	}, nil
+}
+
+// PairingURI returns a URI holding the server address and pairing code,
+// suitable for a QR code.
+func PairingURI(addr, code string) string {
+	return (&url.URL{Scheme: "garage", Host: addr, Path: "/enroll", RawQuery: url.Values{"code": {code}}.Encode()}).String()
+}
+
+// ParsePairing returns the address and code from a pairing URI. A plain
+// pairing code is returned with an empty address.
+func ParsePairing(s string) (addr, code string, err error) {
+	if !strings.Contains(s, "://") {
+		return "", s, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", err
+	}
+	code = u.Query().Get("code")
+	if u.Scheme != "garage" || len(code) == 0 {
+		return "", "", fmt.Errorf("%q is not a pairing URI", s)
+	}
+	return u.Host, code, nil
+}
@@ -28,6 +28,8 @@ func AuthKey() string {
 	return authKey
 }
 
+// Request is a line of the JSON line protocol the mirror serves for
+// devices that can not use gRPC. Each Request is answered by a Response.
 type Request struct {
 	Type string
 	Body string
@@ -41,10 +43,15 @@ type Response struct {
 const (
 	RequestPing   = "ping"
 	RequestToggle = "toggle"
+	RequestOpen   = "open"
 	RequestClose  = "close"
+	RequestStatus = "status"
 	RequestAuth   = "auth"
 	FromClient    = "client"
 	FromOpener    = "opener"
+
+	// RequestReconnect is sent to an opener when the mirror shuts down.
+	RequestReconnect = "reconnect"
 )
 
 var (
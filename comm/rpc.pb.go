@@ -10,11 +10,32 @@ It is generated from these files:
 
 It has these top-level messages:
 	FromGarage
+	DoorStatus
+	OpenerInfo
 	ToGarage
+	CommandResult
 	PingReq
 	PingResp
 	ToggleReq
 	ToggleResp
+	StatusReq
+	StatusResp
+	Door
+	DoorsReq
+	DoorsResp
+	Event
+	HistoryReq
+	HistoryResp
+	WatchReq
+	PairReq
+	PairResp
+	EnrollReq
+	EnrollResp
+	Pass
+	CreatePassReq
+	ListPassesReq
+	ListPassesResp
+	RevokePassReq
 */
 package comm
 
@@ -38,8 +59,131 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type DoorState int32
+
+const (
+	DoorState_UNKNOWN DoorState = 0
+	DoorState_CLOSED  DoorState = 1
+	DoorState_OPEN    DoorState = 2
+	DoorState_BETWEEN DoorState = 3
+	DoorState_OPENING DoorState = 4
+	DoorState_CLOSING DoorState = 5
+	DoorState_STOPPED DoorState = 6
+)
+
+var DoorState_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "CLOSED",
+	2: "OPEN",
+	3: "BETWEEN",
+	4: "OPENING",
+	5: "CLOSING",
+	6: "STOPPED",
+}
+var DoorState_value = map[string]int32{
+	"UNKNOWN": 0,
+	"CLOSED":  1,
+	"OPEN":    2,
+	"BETWEEN": 3,
+	"OPENING": 4,
+	"CLOSING": 5,
+	"STOPPED": 6,
+}
+
+func (x DoorState) String() string {
+	return proto.EnumName(DoorState_name, int32(x))
+}
+func (DoorState) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type Action int32
+
+const (
+	Action_TOGGLE     Action = 0
+	Action_OPEN_DOOR  Action = 1
+	Action_CLOSE_DOOR Action = 2
+)
+
+var Action_name = map[int32]string{
+	0: "TOGGLE",
+	1: "OPEN_DOOR",
+	2: "CLOSE_DOOR",
+}
+var Action_value = map[string]int32{
+	"TOGGLE":     0,
+	"OPEN_DOOR":  1,
+	"CLOSE_DOOR": 2,
+}
+
+func (x Action) String() string {
+	return proto.EnumName(Action_name, int32(x))
+}
+func (Action) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+type EventKind int32
+
+const (
+	EventKind_STATUS       EventKind = 0
+	EventKind_COMMAND      EventKind = 1
+	EventKind_CONNECTED    EventKind = 2
+	EventKind_DISCONNECTED EventKind = 3
+	EventKind_NOTICE       EventKind = 4
+)
+
+var EventKind_name = map[int32]string{
+	0: "STATUS",
+	1: "COMMAND",
+	2: "CONNECTED",
+	3: "DISCONNECTED",
+	4: "NOTICE",
+}
+var EventKind_value = map[string]int32{
+	"STATUS":       0,
+	"COMMAND":      1,
+	"CONNECTED":    2,
+	"DISCONNECTED": 3,
+	"NOTICE":       4,
+}
+
+func (x EventKind) String() string {
+	return proto.EnumName(EventKind_name, int32(x))
+}
+func (EventKind) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type Role int32
+
+const (
+	Role_NO_ROLE Role = 0
+	Role_ADMIN   Role = 1
+	Role_MEMBER  Role = 2
+	Role_GUEST   Role = 3
+	Role_OPENER  Role = 4
+)
+
+var Role_name = map[int32]string{
+	0: "NO_ROLE",
+	1: "ADMIN",
+	2: "MEMBER",
+	3: "GUEST",
+	4: "OPENER",
+}
+var Role_value = map[string]int32{
+	"NO_ROLE": 0,
+	"ADMIN":   1,
+	"MEMBER":  2,
+	"GUEST":   3,
+	"OPENER":  4,
+}
+
+func (x Role) String() string {
+	return proto.EnumName(Role_name, int32(x))
+}
+func (Role) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
 type FromGarage struct {
-	TimeUnix int64 `protobuf:"varint,1,opt,name=TimeUnix,json=timeUnix" json:"TimeUnix,omitempty"`
+	TimeUnix int64          `protobuf:"varint,1,opt,name=TimeUnix,json=timeUnix" json:"TimeUnix,omitempty"`
+	Info     *OpenerInfo    `protobuf:"bytes,2,opt,name=Info,json=info" json:"Info,omitempty"`
+	Status   *DoorStatus    `protobuf:"bytes,3,opt,name=Status,json=status" json:"Status,omitempty"`
+	Result   *CommandResult `protobuf:"bytes,4,opt,name=Result,json=result" json:"Result,omitempty"`
 }
 
 func (m *FromGarage) Reset()                    { *m = FromGarage{} }
@@ -47,92 +191,866 @@ func (m *FromGarage) String() string            { return proto.CompactTextString
 func (*FromGarage) ProtoMessage()               {}
 func (*FromGarage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
-func (m *FromGarage) GetTimeUnix() int64 {
+func (m *FromGarage) GetTimeUnix() int64 {
+	if m != nil {
+		return m.TimeUnix
+	}
+	return 0
+}
+
+func (m *FromGarage) GetInfo() *OpenerInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *FromGarage) GetStatus() *DoorStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *FromGarage) GetResult() *CommandResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+type DoorStatus struct {
+	State     DoorState `protobuf:"varint,1,opt,name=State,json=state,enum=comm.DoorState" json:"State,omitempty"`
+	SinceUnix int64     `protobuf:"varint,2,opt,name=SinceUnix,json=sinceUnix" json:"SinceUnix,omitempty"`
+	Reason    string    `protobuf:"bytes,3,opt,name=Reason,json=reason" json:"Reason,omitempty"`
+	Fault     bool      `protobuf:"varint,4,opt,name=Fault,json=fault" json:"Fault,omitempty"`
+}
+
+func (m *DoorStatus) Reset()                    { *m = DoorStatus{} }
+func (m *DoorStatus) String() string            { return proto.CompactTextString(m) }
+func (*DoorStatus) ProtoMessage()               {}
+func (*DoorStatus) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *DoorStatus) GetState() DoorState {
+	if m != nil {
+		return m.State
+	}
+	return DoorState_UNKNOWN
+}
+
+func (m *DoorStatus) GetSinceUnix() int64 {
+	if m != nil {
+		return m.SinceUnix
+	}
+	return 0
+}
+
+func (m *DoorStatus) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *DoorStatus) GetFault() bool {
+	if m != nil {
+		return m.Fault
+	}
+	return false
+}
+
+type OpenerInfo struct {
+	Driver      string `protobuf:"bytes,1,opt,name=Driver,json=driver" json:"Driver,omitempty"`
+	Chip        string `protobuf:"bytes,2,opt,name=Chip,json=chip" json:"Chip,omitempty"`
+	Pin         int32  `protobuf:"varint,3,opt,name=Pin,json=pin" json:"Pin,omitempty"`
+	ActiveLow   bool   `protobuf:"varint,4,opt,name=ActiveLow,json=activeLow" json:"ActiveLow,omitempty"`
+	PulseMillis int64  `protobuf:"varint,5,opt,name=PulseMillis,json=pulseMillis" json:"PulseMillis,omitempty"`
+	GapMillis   int64  `protobuf:"varint,6,opt,name=GapMillis,json=gapMillis" json:"GapMillis,omitempty"`
+	Door        string `protobuf:"bytes,7,opt,name=Door,json=door" json:"Door,omitempty"`
+}
+
+func (m *OpenerInfo) Reset()                    { *m = OpenerInfo{} }
+func (m *OpenerInfo) String() string            { return proto.CompactTextString(m) }
+func (*OpenerInfo) ProtoMessage()               {}
+func (*OpenerInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *OpenerInfo) GetDriver() string {
+	if m != nil {
+		return m.Driver
+	}
+	return ""
+}
+
+func (m *OpenerInfo) GetChip() string {
+	if m != nil {
+		return m.Chip
+	}
+	return ""
+}
+
+func (m *OpenerInfo) GetPin() int32 {
+	if m != nil {
+		return m.Pin
+	}
+	return 0
+}
+
+func (m *OpenerInfo) GetActiveLow() bool {
+	if m != nil {
+		return m.ActiveLow
+	}
+	return false
+}
+
+func (m *OpenerInfo) GetPulseMillis() int64 {
+	if m != nil {
+		return m.PulseMillis
+	}
+	return 0
+}
+
+func (m *OpenerInfo) GetGapMillis() int64 {
+	if m != nil {
+		return m.GapMillis
+	}
+	return 0
+}
+
+func (m *OpenerInfo) GetDoor() string {
+	if m != nil {
+		return m.Door
+	}
+	return ""
+}
+
+type ToGarage struct {
+	TimeUnix  int64  `protobuf:"varint,1,opt,name=TimeUnix,json=timeUnix" json:"TimeUnix,omitempty"`
+	Toggle    bool   `protobuf:"varint,2,opt,name=Toggle,json=toggle" json:"Toggle,omitempty"`
+	ID        uint64 `protobuf:"varint,3,opt,name=ID,json=iD" json:"ID,omitempty"`
+	Action    Action `protobuf:"varint,4,opt,name=Action,json=action,enum=comm.Action" json:"Action,omitempty"`
+	Reconnect bool   `protobuf:"varint,5,opt,name=Reconnect,json=reconnect" json:"Reconnect,omitempty"`
+}
+
+func (m *ToGarage) Reset()                    { *m = ToGarage{} }
+func (m *ToGarage) String() string            { return proto.CompactTextString(m) }
+func (*ToGarage) ProtoMessage()               {}
+func (*ToGarage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *ToGarage) GetTimeUnix() int64 {
+	if m != nil {
+		return m.TimeUnix
+	}
+	return 0
+}
+
+func (m *ToGarage) GetToggle() bool {
+	if m != nil {
+		return m.Toggle
+	}
+	return false
+}
+
+func (m *ToGarage) GetID() uint64 {
+	if m != nil {
+		return m.ID
+	}
+	return 0
+}
+
+func (m *ToGarage) GetAction() Action {
+	if m != nil {
+		return m.Action
+	}
+	return Action_TOGGLE
+}
+
+func (m *ToGarage) GetReconnect() bool {
+	if m != nil {
+		return m.Reconnect
+	}
+	return false
+}
+
+type CommandResult struct {
+	ID      uint64 `protobuf:"varint,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	OK      bool   `protobuf:"varint,2,opt,name=OK,json=oK" json:"OK,omitempty"`
+	Message string `protobuf:"bytes,3,opt,name=Message,json=message" json:"Message,omitempty"`
+}
+
+func (m *CommandResult) Reset()                    { *m = CommandResult{} }
+func (m *CommandResult) String() string            { return proto.CompactTextString(m) }
+func (*CommandResult) ProtoMessage()               {}
+func (*CommandResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *CommandResult) GetID() uint64 {
+	if m != nil {
+		return m.ID
+	}
+	return 0
+}
+
+func (m *CommandResult) GetOK() bool {
+	if m != nil {
+		return m.OK
+	}
+	return false
+}
+
+func (m *CommandResult) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+type PingReq struct {
+	TimeUnix int64 `protobuf:"varint,1,opt,name=TimeUnix,json=timeUnix" json:"TimeUnix,omitempty"`
+}
+
+func (m *PingReq) Reset()                    { *m = PingReq{} }
+func (m *PingReq) String() string            { return proto.CompactTextString(m) }
+func (*PingReq) ProtoMessage()               {}
+func (*PingReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *PingReq) GetTimeUnix() int64 {
+	if m != nil {
+		return m.TimeUnix
+	}
+	return 0
+}
+
+type PingResp struct {
+}
+
+func (m *PingResp) Reset()                    { *m = PingResp{} }
+func (m *PingResp) String() string            { return proto.CompactTextString(m) }
+func (*PingResp) ProtoMessage()               {}
+func (*PingResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+type ToggleReq struct {
+	TimeUnix int64  `protobuf:"varint,1,opt,name=TimeUnix,json=timeUnix" json:"TimeUnix,omitempty"`
+	Action   Action `protobuf:"varint,2,opt,name=Action,json=action,enum=comm.Action" json:"Action,omitempty"`
+	Door     string `protobuf:"bytes,3,opt,name=Door,json=door" json:"Door,omitempty"`
+}
+
+func (m *ToggleReq) Reset()                    { *m = ToggleReq{} }
+func (m *ToggleReq) String() string            { return proto.CompactTextString(m) }
+func (*ToggleReq) ProtoMessage()               {}
+func (*ToggleReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *ToggleReq) GetTimeUnix() int64 {
+	if m != nil {
+		return m.TimeUnix
+	}
+	return 0
+}
+
+func (m *ToggleReq) GetAction() Action {
+	if m != nil {
+		return m.Action
+	}
+	return Action_TOGGLE
+}
+
+func (m *ToggleReq) GetDoor() string {
+	if m != nil {
+		return m.Door
+	}
+	return ""
+}
+
+type ToggleResp struct {
+	Message string `protobuf:"bytes,1,opt,name=Message,json=message" json:"Message,omitempty"`
+}
+
+func (m *ToggleResp) Reset()                    { *m = ToggleResp{} }
+func (m *ToggleResp) String() string            { return proto.CompactTextString(m) }
+func (*ToggleResp) ProtoMessage()               {}
+func (*ToggleResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *ToggleResp) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+type StatusReq struct {
+	Door string `protobuf:"bytes,1,opt,name=Door,json=door" json:"Door,omitempty"`
+}
+
+func (m *StatusReq) Reset()                    { *m = StatusReq{} }
+func (m *StatusReq) String() string            { return proto.CompactTextString(m) }
+func (*StatusReq) ProtoMessage()               {}
+func (*StatusReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *StatusReq) GetDoor() string {
+	if m != nil {
+		return m.Door
+	}
+	return ""
+}
+
+type StatusResp struct {
+	Status       *DoorStatus `protobuf:"bytes,1,opt,name=Status,json=status" json:"Status,omitempty"`
+	OpenerOnline bool        `protobuf:"varint,2,opt,name=OpenerOnline,json=openerOnline" json:"OpenerOnline,omitempty"`
+	Door         string      `protobuf:"bytes,3,opt,name=Door,json=door" json:"Door,omitempty"`
+}
+
+func (m *StatusResp) Reset()                    { *m = StatusResp{} }
+func (m *StatusResp) String() string            { return proto.CompactTextString(m) }
+func (*StatusResp) ProtoMessage()               {}
+func (*StatusResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *StatusResp) GetStatus() *DoorStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *StatusResp) GetOpenerOnline() bool {
+	if m != nil {
+		return m.OpenerOnline
+	}
+	return false
+}
+
+func (m *StatusResp) GetDoor() string {
+	if m != nil {
+		return m.Door
+	}
+	return ""
+}
+
+type Door struct {
+	Name        string      `protobuf:"bytes,1,opt,name=Name,json=name" json:"Name,omitempty"`
+	Status      *DoorStatus `protobuf:"bytes,2,opt,name=Status,json=status" json:"Status,omitempty"`
+	Info        *OpenerInfo `protobuf:"bytes,3,opt,name=Info,json=info" json:"Info,omitempty"`
+	Online      bool        `protobuf:"varint,4,opt,name=Online,json=online" json:"Online,omitempty"`
+	ChangedUnix int64       `protobuf:"varint,5,opt,name=ChangedUnix,json=changedUnix" json:"ChangedUnix,omitempty"`
+}
+
+func (m *Door) Reset()                    { *m = Door{} }
+func (m *Door) String() string            { return proto.CompactTextString(m) }
+func (*Door) ProtoMessage()               {}
+func (*Door) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *Door) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Door) GetStatus() *DoorStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *Door) GetInfo() *OpenerInfo {
+	if m != nil {
+		return m.Info
+	}
+	return nil
+}
+
+func (m *Door) GetOnline() bool {
+	if m != nil {
+		return m.Online
+	}
+	return false
+}
+
+func (m *Door) GetChangedUnix() int64 {
+	if m != nil {
+		return m.ChangedUnix
+	}
+	return 0
+}
+
+type DoorsReq struct {
+}
+
+func (m *DoorsReq) Reset()                    { *m = DoorsReq{} }
+func (m *DoorsReq) String() string            { return proto.CompactTextString(m) }
+func (*DoorsReq) ProtoMessage()               {}
+func (*DoorsReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+type DoorsResp struct {
+	Doors []*Door `protobuf:"bytes,1,rep,name=Doors,json=doors" json:"Doors,omitempty"`
+}
+
+func (m *DoorsResp) Reset()                    { *m = DoorsResp{} }
+func (m *DoorsResp) String() string            { return proto.CompactTextString(m) }
+func (*DoorsResp) ProtoMessage()               {}
+func (*DoorsResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *DoorsResp) GetDoors() []*Door {
+	if m != nil {
+		return m.Doors
+	}
+	return nil
+}
+
+type Event struct {
+	TimeUnix int64       `protobuf:"varint,1,opt,name=TimeUnix,json=timeUnix" json:"TimeUnix,omitempty"`
+	Kind     EventKind   `protobuf:"varint,2,opt,name=Kind,json=kind,enum=comm.EventKind" json:"Kind,omitempty"`
+	Door     string      `protobuf:"bytes,3,opt,name=Door,json=door" json:"Door,omitempty"`
+	Status   *DoorStatus `protobuf:"bytes,4,opt,name=Status,json=status" json:"Status,omitempty"`
+	Action   Action      `protobuf:"varint,5,opt,name=Action,json=action,enum=comm.Action" json:"Action,omitempty"`
+	Actor    string      `protobuf:"bytes,6,opt,name=Actor,json=actor" json:"Actor,omitempty"`
+	OK       bool        `protobuf:"varint,7,opt,name=OK,json=oK" json:"OK,omitempty"`
+	Message  string      `protobuf:"bytes,8,opt,name=Message,json=message" json:"Message,omitempty"`
+	To       string      `protobuf:"bytes,9,opt,name=To,json=to" json:"To,omitempty"`
+}
+
+func (m *Event) Reset()                    { *m = Event{} }
+func (m *Event) String() string            { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()               {}
+func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *Event) GetTimeUnix() int64 {
+	if m != nil {
+		return m.TimeUnix
+	}
+	return 0
+}
+
+func (m *Event) GetKind() EventKind {
+	if m != nil {
+		return m.Kind
+	}
+	return EventKind_STATUS
+}
+
+func (m *Event) GetDoor() string {
+	if m != nil {
+		return m.Door
+	}
+	return ""
+}
+
+func (m *Event) GetStatus() *DoorStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *Event) GetAction() Action {
+	if m != nil {
+		return m.Action
+	}
+	return Action_TOGGLE
+}
+
+func (m *Event) GetActor() string {
+	if m != nil {
+		return m.Actor
+	}
+	return ""
+}
+
+func (m *Event) GetOK() bool {
+	if m != nil {
+		return m.OK
+	}
+	return false
+}
+
+func (m *Event) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *Event) GetTo() string {
+	if m != nil {
+		return m.To
+	}
+	return ""
+}
+
+type HistoryReq struct {
+	Door  string `protobuf:"bytes,1,opt,name=Door,json=door" json:"Door,omitempty"`
+	Limit int32  `protobuf:"varint,2,opt,name=Limit,json=limit" json:"Limit,omitempty"`
+}
+
+func (m *HistoryReq) Reset()                    { *m = HistoryReq{} }
+func (m *HistoryReq) String() string            { return proto.CompactTextString(m) }
+func (*HistoryReq) ProtoMessage()               {}
+func (*HistoryReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *HistoryReq) GetDoor() string {
+	if m != nil {
+		return m.Door
+	}
+	return ""
+}
+
+func (m *HistoryReq) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type HistoryResp struct {
+	Events []*Event `protobuf:"bytes,1,rep,name=Events,json=events" json:"Events,omitempty"`
+}
+
+func (m *HistoryResp) Reset()                    { *m = HistoryResp{} }
+func (m *HistoryResp) String() string            { return proto.CompactTextString(m) }
+func (*HistoryResp) ProtoMessage()               {}
+func (*HistoryResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *HistoryResp) GetEvents() []*Event {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
+type WatchReq struct {
+	Door string `protobuf:"bytes,1,opt,name=Door,json=door" json:"Door,omitempty"`
+}
+
+func (m *WatchReq) Reset()                    { *m = WatchReq{} }
+func (m *WatchReq) String() string            { return proto.CompactTextString(m) }
+func (*WatchReq) ProtoMessage()               {}
+func (*WatchReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *WatchReq) GetDoor() string {
+	if m != nil {
+		return m.Door
+	}
+	return ""
+}
+
+type PairReq struct {
+	Name       string `protobuf:"bytes,1,opt,name=Name,json=name" json:"Name,omitempty"`
+	Role       Role   `protobuf:"varint,2,opt,name=Role,json=role,enum=comm.Role" json:"Role,omitempty"`
+	TTLSeconds int64  `protobuf:"varint,3,opt,name=TTLSeconds,json=tTLSeconds" json:"TTLSeconds,omitempty"`
+}
+
+func (m *PairReq) Reset()                    { *m = PairReq{} }
+func (m *PairReq) String() string            { return proto.CompactTextString(m) }
+func (*PairReq) ProtoMessage()               {}
+func (*PairReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *PairReq) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *PairReq) GetRole() Role {
+	if m != nil {
+		return m.Role
+	}
+	return Role_NO_ROLE
+}
+
+func (m *PairReq) GetTTLSeconds() int64 {
+	if m != nil {
+		return m.TTLSeconds
+	}
+	return 0
+}
+
+type PairResp struct {
+	Code        string `protobuf:"bytes,1,opt,name=Code,json=code" json:"Code,omitempty"`
+	ExpiresUnix int64  `protobuf:"varint,2,opt,name=ExpiresUnix,json=expiresUnix" json:"ExpiresUnix,omitempty"`
+}
+
+func (m *PairResp) Reset()                    { *m = PairResp{} }
+func (m *PairResp) String() string            { return proto.CompactTextString(m) }
+func (*PairResp) ProtoMessage()               {}
+func (*PairResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *PairResp) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *PairResp) GetExpiresUnix() int64 {
+	if m != nil {
+		return m.ExpiresUnix
+	}
+	return 0
+}
+
+type EnrollReq struct {
+	Code string `protobuf:"bytes,1,opt,name=Code,json=code" json:"Code,omitempty"`
+	CSR  []byte `protobuf:"bytes,2,opt,name=CSR,json=cSR,proto3" json:"CSR,omitempty"`
+}
+
+func (m *EnrollReq) Reset()                    { *m = EnrollReq{} }
+func (m *EnrollReq) String() string            { return proto.CompactTextString(m) }
+func (*EnrollReq) ProtoMessage()               {}
+func (*EnrollReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *EnrollReq) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+func (m *EnrollReq) GetCSR() []byte {
+	if m != nil {
+		return m.CSR
+	}
+	return nil
+}
+
+type EnrollResp struct {
+	Cert []byte `protobuf:"bytes,1,opt,name=Cert,json=cert,proto3" json:"Cert,omitempty"`
+	CA   []byte `protobuf:"bytes,2,opt,name=CA,json=cA,proto3" json:"CA,omitempty"`
+	Name string `protobuf:"bytes,3,opt,name=Name,json=name" json:"Name,omitempty"`
+	Role Role   `protobuf:"varint,4,opt,name=Role,json=role,enum=comm.Role" json:"Role,omitempty"`
+}
+
+func (m *EnrollResp) Reset()                    { *m = EnrollResp{} }
+func (m *EnrollResp) String() string            { return proto.CompactTextString(m) }
+func (*EnrollResp) ProtoMessage()               {}
+func (*EnrollResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *EnrollResp) GetCert() []byte {
+	if m != nil {
+		return m.Cert
+	}
+	return nil
+}
+
+func (m *EnrollResp) GetCA() []byte {
+	if m != nil {
+		return m.CA
+	}
+	return nil
+}
+
+func (m *EnrollResp) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *EnrollResp) GetRole() Role {
+	if m != nil {
+		return m.Role
+	}
+	return Role_NO_ROLE
+}
+
+type Pass struct {
+	ID          string   `protobuf:"bytes,1,opt,name=ID,json=iD" json:"ID,omitempty"`
+	Token       string   `protobuf:"bytes,2,opt,name=Token,json=token" json:"Token,omitempty"`
+	Name        string   `protobuf:"bytes,3,opt,name=Name,json=name" json:"Name,omitempty"`
+	Doors       []string `protobuf:"bytes,4,rep,name=Doors,json=doors" json:"Doors,omitempty"`
+	ExpiresUnix int64    `protobuf:"varint,5,opt,name=ExpiresUnix,json=expiresUnix" json:"ExpiresUnix,omitempty"`
+	MaxUses     int32    `protobuf:"varint,6,opt,name=MaxUses,json=maxUses" json:"MaxUses,omitempty"`
+	Uses        int32    `protobuf:"varint,7,opt,name=Uses,json=uses" json:"Uses,omitempty"`
+	Creator     string   `protobuf:"bytes,8,opt,name=Creator,json=creator" json:"Creator,omitempty"`
+	CreatedUnix int64    `protobuf:"varint,9,opt,name=CreatedUnix,json=createdUnix" json:"CreatedUnix,omitempty"`
+	Revoked     bool     `protobuf:"varint,10,opt,name=Revoked,json=revoked" json:"Revoked,omitempty"`
+}
+
+func (m *Pass) Reset()                    { *m = Pass{} }
+func (m *Pass) String() string            { return proto.CompactTextString(m) }
+func (*Pass) ProtoMessage()               {}
+func (*Pass) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *Pass) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
+
+func (m *Pass) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+func (m *Pass) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Pass) GetDoors() []string {
+	if m != nil {
+		return m.Doors
+	}
+	return nil
+}
+
+func (m *Pass) GetExpiresUnix() int64 {
+	if m != nil {
+		return m.ExpiresUnix
+	}
+	return 0
+}
+
+func (m *Pass) GetMaxUses() int32 {
+	if m != nil {
+		return m.MaxUses
+	}
+	return 0
+}
+
+func (m *Pass) GetUses() int32 {
+	if m != nil {
+		return m.Uses
+	}
+	return 0
+}
+
+func (m *Pass) GetCreator() string {
+	if m != nil {
+		return m.Creator
+	}
+	return ""
+}
+
+func (m *Pass) GetCreatedUnix() int64 {
 	if m != nil {
-		return m.TimeUnix
+		return m.CreatedUnix
 	}
 	return 0
 }
 
-type ToGarage struct {
-	TimeUnix int64 `protobuf:"varint,1,opt,name=TimeUnix,json=timeUnix" json:"TimeUnix,omitempty"`
-	Toggle   bool  `protobuf:"varint,2,opt,name=Toggle,json=toggle" json:"Toggle,omitempty"`
+func (m *Pass) GetRevoked() bool {
+	if m != nil {
+		return m.Revoked
+	}
+	return false
 }
 
-func (m *ToGarage) Reset()                    { *m = ToGarage{} }
-func (m *ToGarage) String() string            { return proto.CompactTextString(m) }
-func (*ToGarage) ProtoMessage()               {}
-func (*ToGarage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+type CreatePassReq struct {
+	Name        string   `protobuf:"bytes,1,opt,name=Name,json=name" json:"Name,omitempty"`
+	Doors       []string `protobuf:"bytes,2,rep,name=Doors,json=doors" json:"Doors,omitempty"`
+	ExpiresUnix int64    `protobuf:"varint,3,opt,name=ExpiresUnix,json=expiresUnix" json:"ExpiresUnix,omitempty"`
+	MaxUses     int32    `protobuf:"varint,4,opt,name=MaxUses,json=maxUses" json:"MaxUses,omitempty"`
+}
 
-func (m *ToGarage) GetTimeUnix() int64 {
+func (m *CreatePassReq) Reset()                    { *m = CreatePassReq{} }
+func (m *CreatePassReq) String() string            { return proto.CompactTextString(m) }
+func (*CreatePassReq) ProtoMessage()               {}
+func (*CreatePassReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *CreatePassReq) GetName() string {
 	if m != nil {
-		return m.TimeUnix
+		return m.Name
 	}
-	return 0
+	return ""
 }
 
-func (m *ToGarage) GetToggle() bool {
+func (m *CreatePassReq) GetDoors() []string {
 	if m != nil {
-		return m.Toggle
+		return m.Doors
 	}
-	return false
+	return nil
 }
 
-type PingReq struct {
-	TimeUnix int64 `protobuf:"varint,1,opt,name=TimeUnix,json=timeUnix" json:"TimeUnix,omitempty"`
+func (m *CreatePassReq) GetExpiresUnix() int64 {
+	if m != nil {
+		return m.ExpiresUnix
+	}
+	return 0
 }
 
-func (m *PingReq) Reset()                    { *m = PingReq{} }
-func (m *PingReq) String() string            { return proto.CompactTextString(m) }
-func (*PingReq) ProtoMessage()               {}
-func (*PingReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
-
-func (m *PingReq) GetTimeUnix() int64 {
+func (m *CreatePassReq) GetMaxUses() int32 {
 	if m != nil {
-		return m.TimeUnix
+		return m.MaxUses
 	}
 	return 0
 }
 
-type PingResp struct {
+type ListPassesReq struct {
 }
 
-func (m *PingResp) Reset()                    { *m = PingResp{} }
-func (m *PingResp) String() string            { return proto.CompactTextString(m) }
-func (*PingResp) ProtoMessage()               {}
-func (*PingResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (m *ListPassesReq) Reset()                    { *m = ListPassesReq{} }
+func (m *ListPassesReq) String() string            { return proto.CompactTextString(m) }
+func (*ListPassesReq) ProtoMessage()               {}
+func (*ListPassesReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
 
-type ToggleReq struct {
-	TimeUnix int64 `protobuf:"varint,1,opt,name=TimeUnix,json=timeUnix" json:"TimeUnix,omitempty"`
+type ListPassesResp struct {
+	Passes []*Pass `protobuf:"bytes,1,rep,name=Passes,json=passes" json:"Passes,omitempty"`
 }
 
-func (m *ToggleReq) Reset()                    { *m = ToggleReq{} }
-func (m *ToggleReq) String() string            { return proto.CompactTextString(m) }
-func (*ToggleReq) ProtoMessage()               {}
-func (*ToggleReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (m *ListPassesResp) Reset()                    { *m = ListPassesResp{} }
+func (m *ListPassesResp) String() string            { return proto.CompactTextString(m) }
+func (*ListPassesResp) ProtoMessage()               {}
+func (*ListPassesResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
 
-func (m *ToggleReq) GetTimeUnix() int64 {
+func (m *ListPassesResp) GetPasses() []*Pass {
 	if m != nil {
-		return m.TimeUnix
+		return m.Passes
 	}
-	return 0
+	return nil
 }
 
-type ToggleResp struct {
+type RevokePassReq struct {
+	ID string `protobuf:"bytes,1,opt,name=ID,json=iD" json:"ID,omitempty"`
 }
 
-func (m *ToggleResp) Reset()                    { *m = ToggleResp{} }
-func (m *ToggleResp) String() string            { return proto.CompactTextString(m) }
-func (*ToggleResp) ProtoMessage()               {}
-func (*ToggleResp) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (m *RevokePassReq) Reset()                    { *m = RevokePassReq{} }
+func (m *RevokePassReq) String() string            { return proto.CompactTextString(m) }
+func (*RevokePassReq) ProtoMessage()               {}
+func (*RevokePassReq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *RevokePassReq) GetID() string {
+	if m != nil {
+		return m.ID
+	}
+	return ""
+}
 
 func init() {
 	proto.RegisterType((*FromGarage)(nil), "comm.FromGarage")
+	proto.RegisterType((*DoorStatus)(nil), "comm.DoorStatus")
+	proto.RegisterType((*OpenerInfo)(nil), "comm.OpenerInfo")
 	proto.RegisterType((*ToGarage)(nil), "comm.ToGarage")
+	proto.RegisterType((*CommandResult)(nil), "comm.CommandResult")
 	proto.RegisterType((*PingReq)(nil), "comm.PingReq")
 	proto.RegisterType((*PingResp)(nil), "comm.PingResp")
 	proto.RegisterType((*ToggleReq)(nil), "comm.ToggleReq")
 	proto.RegisterType((*ToggleResp)(nil), "comm.ToggleResp")
+	proto.RegisterType((*StatusReq)(nil), "comm.StatusReq")
+	proto.RegisterType((*StatusResp)(nil), "comm.StatusResp")
+	proto.RegisterType((*Door)(nil), "comm.Door")
+	proto.RegisterType((*DoorsReq)(nil), "comm.DoorsReq")
+	proto.RegisterType((*DoorsResp)(nil), "comm.DoorsResp")
+	proto.RegisterType((*Event)(nil), "comm.Event")
+	proto.RegisterType((*HistoryReq)(nil), "comm.HistoryReq")
+	proto.RegisterType((*HistoryResp)(nil), "comm.HistoryResp")
+	proto.RegisterType((*WatchReq)(nil), "comm.WatchReq")
+	proto.RegisterType((*PairReq)(nil), "comm.PairReq")
+	proto.RegisterType((*PairResp)(nil), "comm.PairResp")
+	proto.RegisterType((*EnrollReq)(nil), "comm.EnrollReq")
+	proto.RegisterType((*EnrollResp)(nil), "comm.EnrollResp")
+	proto.RegisterType((*Pass)(nil), "comm.Pass")
+	proto.RegisterType((*CreatePassReq)(nil), "comm.CreatePassReq")
+	proto.RegisterType((*ListPassesReq)(nil), "comm.ListPassesReq")
+	proto.RegisterType((*ListPassesResp)(nil), "comm.ListPassesResp")
+	proto.RegisterType((*RevokePassReq)(nil), "comm.RevokePassReq")
+	proto.RegisterEnum("comm.DoorState", DoorState_name, DoorState_value)
+	proto.RegisterEnum("comm.Action", Action_name, Action_value)
+	proto.RegisterEnum("comm.EventKind", EventKind_name, EventKind_value)
+	proto.RegisterEnum("comm.Role", Role_name, Role_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -148,6 +1066,15 @@ const _ = grpc.SupportPackageIsVersion4
 type GarageClient interface {
 	Ping(ctx context.Context, in *PingReq, opts ...grpc.CallOption) (*PingResp, error)
 	Toggle(ctx context.Context, in *ToggleReq, opts ...grpc.CallOption) (*ToggleResp, error)
+	Status(ctx context.Context, in *StatusReq, opts ...grpc.CallOption) (*StatusResp, error)
+	Doors(ctx context.Context, in *DoorsReq, opts ...grpc.CallOption) (*DoorsResp, error)
+	History(ctx context.Context, in *HistoryReq, opts ...grpc.CallOption) (*HistoryResp, error)
+	Watch(ctx context.Context, in *WatchReq, opts ...grpc.CallOption) (Garage_WatchClient, error)
+	Pair(ctx context.Context, in *PairReq, opts ...grpc.CallOption) (*PairResp, error)
+	Enroll(ctx context.Context, in *EnrollReq, opts ...grpc.CallOption) (*EnrollResp, error)
+	CreatePass(ctx context.Context, in *CreatePassReq, opts ...grpc.CallOption) (*Pass, error)
+	ListPasses(ctx context.Context, in *ListPassesReq, opts ...grpc.CallOption) (*ListPassesResp, error)
+	RevokePass(ctx context.Context, in *RevokePassReq, opts ...grpc.CallOption) (*Pass, error)
 	Garage(ctx context.Context, opts ...grpc.CallOption) (Garage_GarageClient, error)
 }
 
@@ -177,8 +1104,112 @@ func (c *garageClient) Toggle(ctx context.Context, in *ToggleReq, opts ...grpc.C
 	return out, nil
 }
 
+func (c *garageClient) Status(ctx context.Context, in *StatusReq, opts ...grpc.CallOption) (*StatusResp, error) {
+	out := new(StatusResp)
+	err := grpc.Invoke(ctx, "/comm.Garage/Status", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *garageClient) Doors(ctx context.Context, in *DoorsReq, opts ...grpc.CallOption) (*DoorsResp, error) {
+	out := new(DoorsResp)
+	err := grpc.Invoke(ctx, "/comm.Garage/Doors", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *garageClient) History(ctx context.Context, in *HistoryReq, opts ...grpc.CallOption) (*HistoryResp, error) {
+	out := new(HistoryResp)
+	err := grpc.Invoke(ctx, "/comm.Garage/History", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *garageClient) Watch(ctx context.Context, in *WatchReq, opts ...grpc.CallOption) (Garage_WatchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Garage_serviceDesc.Streams[0], c.cc, "/comm.Garage/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &garageWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Garage_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type garageWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *garageWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *garageClient) Pair(ctx context.Context, in *PairReq, opts ...grpc.CallOption) (*PairResp, error) {
+	out := new(PairResp)
+	err := grpc.Invoke(ctx, "/comm.Garage/Pair", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *garageClient) Enroll(ctx context.Context, in *EnrollReq, opts ...grpc.CallOption) (*EnrollResp, error) {
+	out := new(EnrollResp)
+	err := grpc.Invoke(ctx, "/comm.Garage/Enroll", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *garageClient) CreatePass(ctx context.Context, in *CreatePassReq, opts ...grpc.CallOption) (*Pass, error) {
+	out := new(Pass)
+	err := grpc.Invoke(ctx, "/comm.Garage/CreatePass", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *garageClient) ListPasses(ctx context.Context, in *ListPassesReq, opts ...grpc.CallOption) (*ListPassesResp, error) {
+	out := new(ListPassesResp)
+	err := grpc.Invoke(ctx, "/comm.Garage/ListPasses", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *garageClient) RevokePass(ctx context.Context, in *RevokePassReq, opts ...grpc.CallOption) (*Pass, error) {
+	out := new(Pass)
+	err := grpc.Invoke(ctx, "/comm.Garage/RevokePass", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *garageClient) Garage(ctx context.Context, opts ...grpc.CallOption) (Garage_GarageClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_Garage_serviceDesc.Streams[0], c.cc, "/comm.Garage/Garage", opts...)
+	stream, err := grpc.NewClientStream(ctx, &_Garage_serviceDesc.Streams[1], c.cc, "/comm.Garage/Garage", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -213,6 +1244,15 @@ func (x *garageGarageClient) Recv() (*ToGarage, error) {
 type GarageServer interface {
 	Ping(context.Context, *PingReq) (*PingResp, error)
 	Toggle(context.Context, *ToggleReq) (*ToggleResp, error)
+	Status(context.Context, *StatusReq) (*StatusResp, error)
+	Doors(context.Context, *DoorsReq) (*DoorsResp, error)
+	History(context.Context, *HistoryReq) (*HistoryResp, error)
+	Watch(*WatchReq, Garage_WatchServer) error
+	Pair(context.Context, *PairReq) (*PairResp, error)
+	Enroll(context.Context, *EnrollReq) (*EnrollResp, error)
+	CreatePass(context.Context, *CreatePassReq) (*Pass, error)
+	ListPasses(context.Context, *ListPassesReq) (*ListPassesResp, error)
+	RevokePass(context.Context, *RevokePassReq) (*Pass, error)
 	Garage(Garage_GarageServer) error
 }
 
@@ -256,6 +1296,171 @@ func _Garage_Toggle_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Garage_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GarageServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/comm.Garage/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GarageServer).Status(ctx, req.(*StatusReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Garage_Doors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DoorsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GarageServer).Doors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/comm.Garage/Doors",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GarageServer).Doors(ctx, req.(*DoorsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Garage_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GarageServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/comm.Garage/History",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GarageServer).History(ctx, req.(*HistoryReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Garage_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GarageServer).Watch(m, &garageWatchServer{stream})
+}
+
+type Garage_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type garageWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *garageWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Garage_Pair_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PairReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GarageServer).Pair(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/comm.Garage/Pair",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GarageServer).Pair(ctx, req.(*PairReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Garage_Enroll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnrollReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GarageServer).Enroll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/comm.Garage/Enroll",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GarageServer).Enroll(ctx, req.(*EnrollReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Garage_CreatePass_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePassReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GarageServer).CreatePass(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/comm.Garage/CreatePass",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GarageServer).CreatePass(ctx, req.(*CreatePassReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Garage_ListPasses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPassesReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GarageServer).ListPasses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/comm.Garage/ListPasses",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GarageServer).ListPasses(ctx, req.(*ListPassesReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Garage_RevokePass_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokePassReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GarageServer).RevokePass(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/comm.Garage/RevokePass",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GarageServer).RevokePass(ctx, req.(*RevokePassReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Garage_Garage_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(GarageServer).Garage(&garageGarageServer{stream})
 }
@@ -294,8 +1499,45 @@ var _Garage_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Toggle",
 			Handler:    _Garage_Toggle_Handler,
 		},
+		{
+			MethodName: "Status",
+			Handler:    _Garage_Status_Handler,
+		},
+		{
+			MethodName: "Doors",
+			Handler:    _Garage_Doors_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _Garage_History_Handler,
+		},
+		{
+			MethodName: "Pair",
+			Handler:    _Garage_Pair_Handler,
+		},
+		{
+			MethodName: "Enroll",
+			Handler:    _Garage_Enroll_Handler,
+		},
+		{
+			MethodName: "CreatePass",
+			Handler:    _Garage_CreatePass_Handler,
+		},
+		{
+			MethodName: "ListPasses",
+			Handler:    _Garage_ListPasses_Handler,
+		},
+		{
+			MethodName: "RevokePass",
+			Handler:    _Garage_RevokePass_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Garage_Watch_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "Garage",
 			Handler:       _Garage_Garage_Handler,
@@ -309,18 +1551,92 @@ var _Garage_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("rpc.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 208 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2c, 0x2a, 0x48, 0xd6,
-	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x49, 0xce, 0xcf, 0xcd, 0x55, 0xd2, 0xe0, 0xe2, 0x72,
-	0x2b, 0xca, 0xcf, 0x75, 0x4f, 0x2c, 0x4a, 0x4c, 0x4f, 0x15, 0x92, 0xe2, 0xe2, 0x08, 0xc9, 0xcc,
-	0x4d, 0x0d, 0xcd, 0xcb, 0xac, 0x90, 0x60, 0x54, 0x60, 0xd4, 0x60, 0x0e, 0xe2, 0x28, 0x81, 0xf2,
-	0x95, 0xec, 0xb8, 0x38, 0x42, 0xf2, 0x09, 0xab, 0x13, 0x12, 0xe3, 0x62, 0x0b, 0xc9, 0x4f, 0x4f,
-	0xcf, 0x49, 0x95, 0x60, 0x52, 0x60, 0xd4, 0xe0, 0x08, 0x62, 0x2b, 0x01, 0xf3, 0x94, 0x54, 0xb9,
-	0xd8, 0x03, 0x32, 0xf3, 0xd2, 0x83, 0x52, 0x0b, 0xf1, 0x5a, 0xc3, 0xc5, 0xc5, 0x01, 0x51, 0x56,
-	0x5c, 0xa0, 0xa4, 0xce, 0xc5, 0x09, 0x31, 0x8a, 0x90, 0x26, 0x1e, 0x2e, 0x2e, 0x98, 0xc2, 0xe2,
-	0x02, 0xa3, 0x1e, 0x46, 0x2e, 0x36, 0xa8, 0x43, 0x55, 0xb9, 0x58, 0x40, 0xa6, 0x09, 0xf1, 0xea,
-	0x81, 0x7c, 0xab, 0x07, 0x75, 0x80, 0x14, 0x1f, 0x32, 0xb7, 0xb8, 0x40, 0x48, 0x1b, 0xe6, 0x66,
-	0x21, 0x7e, 0x88, 0x0c, 0xdc, 0x5a, 0x29, 0x01, 0x54, 0x81, 0xe2, 0x02, 0x21, 0x3d, 0xb8, 0xe9,
-	0x50, 0x39, 0x44, 0x00, 0xc2, 0x0c, 0x86, 0x05, 0x94, 0x06, 0xa3, 0x01, 0x63, 0x12, 0x1b, 0x38,
-	0xbc, 0x8d, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0xc3, 0x1b, 0xc6, 0x53, 0x7c, 0x01, 0x00, 0x00,
+	// 1384 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x57, 0xdb, 0x6e, 0xdb, 0x46,
+	0x13, 0x0e, 0x8f, 0x12, 0x47, 0xb6, 0xc2, 0x7f, 0x7f, 0x23, 0x10, 0x84, 0x22, 0x31, 0x98, 0x03,
+	0x0c, 0x17, 0x31, 0x52, 0xa7, 0x68, 0x6f, 0xab, 0x48, 0x8c, 0x6b, 0xd8, 0x12, 0xd5, 0x15, 0x8d,
+	0x5c, 0x15, 0x01, 0x43, 0x6d, 0x64, 0x22, 0x12, 0x97, 0x21, 0x69, 0x37, 0xbd, 0xea, 0x3b, 0xf4,
+	0x19, 0xda, 0x3e, 0x45, 0x1f, 0xad, 0x17, 0xc5, 0xec, 0x2e, 0x45, 0x2a, 0x76, 0xe4, 0x5e, 0x89,
+	0xf3, 0xed, 0xcc, 0xce, 0x7c, 0xb3, 0x33, 0xb3, 0x2b, 0x70, 0xf2, 0x2c, 0x3e, 0xca, 0x72, 0x5e,
+	0x72, 0x62, 0xc6, 0x7c, 0xb5, 0xf2, 0xfe, 0xd4, 0x00, 0x5e, 0xe7, 0x7c, 0x75, 0x12, 0xe5, 0xd1,
+	0x82, 0x91, 0x3e, 0xb4, 0xc3, 0x64, 0xc5, 0x2e, 0xd2, 0xe4, 0x53, 0x4f, 0xdb, 0xd7, 0x0e, 0x0c,
+	0xda, 0x2e, 0x95, 0x4c, 0x9e, 0x80, 0x79, 0x9a, 0xbe, 0xe7, 0x3d, 0x7d, 0x5f, 0x3b, 0xe8, 0x1c,
+	0xbb, 0x47, 0x68, 0x7f, 0x14, 0x64, 0x2c, 0x65, 0x39, 0xe2, 0xd4, 0x4c, 0xd2, 0xf7, 0x9c, 0x1c,
+	0x80, 0x3d, 0x2b, 0xa3, 0xf2, 0xaa, 0xe8, 0x19, 0x4d, 0xbd, 0x11, 0xe7, 0xb9, 0xc4, 0xa9, 0x5d,
+	0x88, 0x5f, 0xf2, 0x35, 0xd8, 0x94, 0x15, 0x57, 0xcb, 0xb2, 0x67, 0x0a, 0xcd, 0xff, 0x4b, 0xcd,
+	0x21, 0x5f, 0xad, 0xa2, 0x74, 0x2e, 0x97, 0xa8, 0x9d, 0x8b, 0x5f, 0xef, 0x37, 0x80, 0x7a, 0x0b,
+	0xf2, 0x14, 0x2c, 0xfc, 0x62, 0x22, 0xc6, 0xee, 0xf1, 0xfd, 0x4d, 0x1f, 0x8c, 0x5a, 0xe8, 0x82,
+	0x91, 0xaf, 0xc0, 0x99, 0x25, 0x69, 0x2c, 0xe9, 0xe8, 0x82, 0x8e, 0x53, 0x54, 0x00, 0x79, 0x80,
+	0xfe, 0xa3, 0x82, 0xa7, 0x22, 0x52, 0x07, 0x5d, 0xa1, 0x44, 0xf6, 0xc0, 0x7a, 0x1d, 0x55, 0x61,
+	0xb5, 0xa9, 0xf5, 0x1e, 0x05, 0xef, 0x6f, 0x0d, 0xa0, 0x26, 0x8b, 0xc6, 0xa3, 0x3c, 0xb9, 0x66,
+	0xb9, 0x08, 0xc1, 0xa1, 0xf6, 0x5c, 0x48, 0x84, 0x80, 0x39, 0xbc, 0x4c, 0x32, 0xe1, 0xcd, 0xa1,
+	0x66, 0x7c, 0x99, 0x64, 0xc4, 0x05, 0x63, 0x9a, 0x48, 0x2f, 0x16, 0x35, 0xb2, 0x24, 0xc5, 0xc0,
+	0x06, 0x71, 0x99, 0x5c, 0xb3, 0x73, 0xfe, 0x8b, 0x72, 0xe3, 0x44, 0x15, 0x40, 0xf6, 0xa1, 0x33,
+	0xbd, 0x5a, 0x16, 0x6c, 0x9c, 0x2c, 0x97, 0x49, 0xd1, 0xb3, 0x44, 0xe0, 0x9d, 0xac, 0x86, 0xd0,
+	0xfe, 0x24, 0xca, 0xd4, 0xba, 0x2d, 0x89, 0x2d, 0x2a, 0x00, 0x63, 0xc0, 0x54, 0xf4, 0x5a, 0x32,
+	0x86, 0x39, 0xe7, 0xb9, 0xf7, 0xbb, 0x06, 0xed, 0x90, 0xff, 0x87, 0x53, 0x7e, 0x00, 0x76, 0xc8,
+	0x17, 0x8b, 0x25, 0x13, 0x14, 0xda, 0xd4, 0x2e, 0x85, 0x44, 0xba, 0xa0, 0x9f, 0x8e, 0x04, 0x07,
+	0x93, 0xea, 0xc9, 0x88, 0x3c, 0x01, 0x1b, 0x29, 0xf0, 0x54, 0xc4, 0xdf, 0x3d, 0xde, 0x91, 0x67,
+	0x20, 0x31, 0x6a, 0x47, 0xe2, 0x17, 0x03, 0xa5, 0x2c, 0xe6, 0x69, 0xca, 0xe2, 0x52, 0x10, 0x69,
+	0x53, 0x27, 0xaf, 0x00, 0xef, 0x14, 0x76, 0x37, 0x4e, 0x5b, 0x39, 0xd1, 0xd6, 0x4e, 0xba, 0xa0,
+	0x07, 0x67, 0x2a, 0x10, 0x9d, 0x9f, 0x91, 0x1e, 0xb4, 0xc6, 0xac, 0x28, 0xa2, 0x05, 0x53, 0x67,
+	0xd6, 0x5a, 0x49, 0xd1, 0x7b, 0x0a, 0xad, 0x69, 0x92, 0x2e, 0x28, 0xfb, 0xb8, 0x8d, 0x9d, 0x07,
+	0xd0, 0x96, 0x6a, 0x45, 0xe6, 0x45, 0xe0, 0x48, 0xa6, 0x77, 0x18, 0x35, 0xa8, 0xea, 0x5b, 0xa8,
+	0x56, 0x59, 0x37, 0x1a, 0x59, 0x7f, 0x06, 0x50, 0xb9, 0x28, 0xb2, 0x66, 0xf4, 0xda, 0x66, 0xf4,
+	0x8f, 0xc0, 0x51, 0xcd, 0xc1, 0x3e, 0xae, 0x37, 0xd2, 0x1a, 0x1b, 0xa5, 0x00, 0x95, 0x42, 0x91,
+	0x35, 0x7a, 0x4c, 0xbb, 0xa3, 0xc7, 0x3c, 0xd8, 0x91, 0x45, 0x1b, 0xa4, 0xcb, 0x24, 0xad, 0xce,
+	0x74, 0x87, 0x37, 0xb0, 0x5b, 0x03, 0xff, 0x43, 0x93, 0x20, 0x2e, 0x4e, 0xa2, 0x55, 0x15, 0xb0,
+	0x99, 0x46, 0x2b, 0xd6, 0x70, 0xaf, 0xdf, 0xe1, 0xbe, 0x1a, 0x19, 0xc6, 0xd6, 0x91, 0xf1, 0x00,
+	0x6c, 0x15, 0x9e, 0x6c, 0x05, 0x9b, 0xcb, 0xc0, 0xf6, 0xa1, 0x33, 0xbc, 0x8c, 0xd2, 0x05, 0x9b,
+	0x8b, 0x63, 0x51, 0x7d, 0x10, 0xd7, 0x10, 0x1e, 0x27, 0x7a, 0xc5, 0xb4, 0x79, 0xcf, 0xc1, 0x51,
+	0xdf, 0x45, 0x46, 0xf6, 0xc1, 0x12, 0x42, 0x4f, 0xdb, 0x37, 0x0e, 0x3a, 0xc7, 0x50, 0x47, 0x48,
+	0x2d, 0x24, 0x58, 0x78, 0xff, 0x68, 0x60, 0xf9, 0xd7, 0x2c, 0x2d, 0xb7, 0x1e, 0xfd, 0x63, 0x30,
+	0xcf, 0x92, 0x74, 0xde, 0xd3, 0x9b, 0x73, 0x46, 0x98, 0x21, 0x4c, 0xcd, 0x0f, 0x49, 0x3a, 0xbf,
+	0x2d, 0x81, 0x8d, 0x1c, 0x99, 0x77, 0xe6, 0xa8, 0xaa, 0x2e, 0x6b, 0x4b, 0x75, 0xed, 0x81, 0x35,
+	0x88, 0x4b, 0x9e, 0x8b, 0x6e, 0x77, 0xa8, 0x15, 0xa1, 0xa0, 0xfa, 0xa3, 0x75, 0x5b, 0x7f, 0xb4,
+	0x37, 0x2a, 0x0c, 0x35, 0x43, 0xde, 0x73, 0x04, 0xa8, 0x97, 0xdc, 0xfb, 0x0e, 0xe0, 0xc7, 0xa4,
+	0x28, 0x79, 0xfe, 0xeb, 0x17, 0x4a, 0x0e, 0x3d, 0x9e, 0x27, 0xab, 0xa4, 0x14, 0xdc, 0x2d, 0x6a,
+	0x2d, 0x51, 0xf0, 0x8e, 0xa1, 0xb3, 0xb6, 0x2b, 0x32, 0xf2, 0x18, 0x6c, 0x91, 0x8d, 0x2a, 0xd1,
+	0x9d, 0x46, 0x86, 0xa8, 0xcd, 0xc4, 0x92, 0xf7, 0x10, 0xda, 0x6f, 0xa2, 0x32, 0xbe, 0xfc, 0x52,
+	0x71, 0xff, 0x0c, 0xad, 0x69, 0x94, 0xe4, 0x6a, 0xf9, 0x46, 0xb9, 0x3d, 0x04, 0x93, 0x72, 0x35,
+	0x8f, 0xba, 0xd5, 0x51, 0x22, 0x42, 0xcd, 0x9c, 0x2f, 0x71, 0x1d, 0xc2, 0xf0, 0x7c, 0x86, 0x53,
+	0x65, 0x2e, 0x6f, 0x1d, 0x83, 0x42, 0xb9, 0x46, 0xbc, 0x1f, 0xa0, 0x2d, 0xb7, 0x2f, 0x32, 0x31,
+	0x9e, 0xf9, 0x7c, 0xbd, 0x7f, 0xcc, 0xe7, 0xa2, 0xcc, 0xfc, 0x4f, 0x59, 0x92, 0xb3, 0xa2, 0x71,
+	0x4f, 0x74, 0x58, 0x0d, 0x79, 0xdf, 0x80, 0xe3, 0xa7, 0x39, 0x5f, 0x2e, 0x55, 0x88, 0x37, 0xb6,
+	0x70, 0xc1, 0x18, 0xce, 0xa8, 0x30, 0xdd, 0xa1, 0x46, 0x3c, 0xa3, 0xde, 0x1c, 0xa0, 0x32, 0x51,
+	0x6e, 0x59, 0x5e, 0x0a, 0x9b, 0x1d, 0x6a, 0xc6, 0x2c, 0x17, 0xb3, 0x6e, 0x38, 0x50, 0x26, 0x7a,
+	0x3c, 0x58, 0x53, 0x37, 0x6e, 0xa1, 0x6e, 0xde, 0x4e, 0x1d, 0x8b, 0xd8, 0x9c, 0x46, 0x45, 0xd1,
+	0x18, 0x9c, 0x8e, 0x18, 0x9c, 0x7b, 0x60, 0x85, 0xfc, 0x03, 0x4b, 0xd5, 0x3d, 0x64, 0x95, 0x28,
+	0xdc, 0xea, 0x62, 0xaf, 0xea, 0x14, 0x73, 0xdf, 0x40, 0x4d, 0xd1, 0x1d, 0x9f, 0xe7, 0xc4, 0xba,
+	0x91, 0x13, 0x51, 0x6a, 0xd1, 0xa7, 0x8b, 0x82, 0xc9, 0x0b, 0xc8, 0xa2, 0xad, 0x95, 0x14, 0xd1,
+	0x8b, 0x80, 0x5b, 0x02, 0x36, 0xaf, 0x10, 0xeb, 0x41, 0x6b, 0x98, 0xb3, 0x08, 0x0b, 0x58, 0x15,
+	0x66, 0x2c, 0x45, 0xd1, 0xe4, 0xf8, 0xa9, 0x9a, 0xdc, 0x51, 0x4d, 0x5e, 0x43, 0x68, 0x4b, 0xd9,
+	0x35, 0xff, 0xc0, 0xe6, 0x3d, 0x10, 0x95, 0xde, 0xca, 0xa5, 0xe8, 0x5d, 0xc1, 0xae, 0xb4, 0xc5,
+	0x1c, 0x7c, 0xa9, 0x7c, 0xd6, 0x04, 0xf5, 0x2d, 0x04, 0x8d, 0xad, 0x04, 0xcd, 0x0d, 0x82, 0xde,
+	0x7d, 0xd8, 0x3d, 0x4f, 0x8a, 0x12, 0x9d, 0x32, 0x31, 0x7a, 0xbe, 0x85, 0x6e, 0x13, 0x28, 0x32,
+	0xe2, 0x81, 0x2d, 0xa5, 0xcd, 0x01, 0x24, 0xe2, 0xb4, 0x33, 0xb1, 0xe2, 0x3d, 0x82, 0x5d, 0xc9,
+	0xab, 0x8a, 0xfe, 0xb3, 0x43, 0x3c, 0x7c, 0x07, 0x4e, 0x35, 0x2f, 0x18, 0xe9, 0x40, 0xeb, 0x62,
+	0x72, 0x36, 0x09, 0xde, 0x4c, 0xdc, 0x7b, 0x04, 0xc0, 0x1e, 0x9e, 0x07, 0x33, 0x7f, 0xe4, 0x6a,
+	0xa4, 0x0d, 0x66, 0x30, 0xf5, 0x27, 0xae, 0x8e, 0x2a, 0xaf, 0xfc, 0xf0, 0x8d, 0xef, 0x4f, 0x5c,
+	0x03, 0x05, 0x84, 0x4f, 0x27, 0x27, 0xae, 0x89, 0x02, 0xea, 0xa3, 0x60, 0xa1, 0x30, 0x0b, 0x83,
+	0xe9, 0xd4, 0x1f, 0xb9, 0xf6, 0xe1, 0xcb, 0x6a, 0xfa, 0xe0, 0x9e, 0x61, 0x70, 0x72, 0x72, 0xee,
+	0xbb, 0xf7, 0xc8, 0x2e, 0x38, 0x68, 0xfc, 0x76, 0x14, 0x04, 0xd4, 0xd5, 0x48, 0x17, 0x40, 0xb8,
+	0x93, 0xb2, 0x7e, 0xf8, 0x13, 0x38, 0xeb, 0x19, 0x88, 0x76, 0xb3, 0x70, 0x10, 0x5e, 0xcc, 0xdc,
+	0x7b, 0xc2, 0x4f, 0x30, 0x1e, 0x0f, 0x26, 0x18, 0xd8, 0x2e, 0x38, 0xc3, 0x60, 0x32, 0xf1, 0x87,
+	0xa1, 0x3f, 0x72, 0x75, 0xe2, 0xc2, 0xce, 0xe8, 0x74, 0x56, 0x23, 0x06, 0x5a, 0x4e, 0x82, 0xf0,
+	0x74, 0xe8, 0xbb, 0xe6, 0xe1, 0x40, 0x56, 0x3a, 0xee, 0x30, 0x09, 0xde, 0xd2, 0x40, 0x84, 0xe1,
+	0x80, 0x35, 0x18, 0x8d, 0x4f, 0x27, 0xae, 0x86, 0xba, 0x63, 0x7f, 0xfc, 0xca, 0xa7, 0xae, 0x8e,
+	0xf0, 0xc9, 0x85, 0x3f, 0x0b, 0xe5, 0x16, 0x18, 0xa8, 0x4f, 0x5d, 0xf3, 0xf8, 0x2f, 0x13, 0x6c,
+	0xf5, 0xc0, 0x79, 0x0a, 0x26, 0x5e, 0xf3, 0x64, 0x57, 0xa5, 0x5d, 0xbe, 0x0c, 0xfa, 0xdd, 0xa6,
+	0x58, 0x64, 0xf8, 0x02, 0x95, 0xd7, 0x33, 0x51, 0x93, 0x7d, 0xfd, 0x1e, 0xe8, 0xbb, 0x9b, 0x80,
+	0x54, 0x56, 0xaf, 0x4f, 0xa5, 0xbc, 0xbe, 0xb1, 0xfb, 0xee, 0x26, 0x20, 0x6e, 0x68, 0x59, 0x74,
+	0xa4, 0x5b, 0xcf, 0x7d, 0xa1, 0x7a, 0x7f, 0x43, 0x2e, 0x32, 0x72, 0x04, 0x2d, 0x35, 0x50, 0x89,
+	0xda, 0xa6, 0x9e, 0xcb, 0xfd, 0xff, 0x7d, 0x86, 0x14, 0x19, 0x79, 0x06, 0x96, 0x18, 0xa6, 0xd5,
+	0xce, 0xd5, 0x64, 0xed, 0x37, 0x47, 0xef, 0x0b, 0x4d, 0xa4, 0x20, 0x4a, 0xf2, 0x75, 0x0a, 0xe4,
+	0x80, 0xed, 0x77, 0x9b, 0xa2, 0x64, 0x25, 0xe7, 0x54, 0xc5, 0x6a, 0x3d, 0xe8, 0xfa, 0xee, 0x26,
+	0x50, 0x64, 0xe4, 0x39, 0x40, 0xdd, 0x6f, 0xa4, 0x7a, 0xaf, 0x37, 0x3b, 0xb0, 0xdf, 0x28, 0x74,
+	0xf2, 0x3d, 0x40, 0xdd, 0x16, 0x95, 0xfa, 0x46, 0xe7, 0xf4, 0xf7, 0x6e, 0x82, 0xd2, 0x4f, 0xdd,
+	0x19, 0x95, 0xe1, 0x46, 0xaf, 0x6c, 0xf8, 0x39, 0x5a, 0x9f, 0xbb, 0x0a, 0xb9, 0xfe, 0x43, 0x53,
+	0xf1, 0xad, 0x9e, 0xbe, 0x07, 0xda, 0x0b, 0xed, 0x9d, 0x2d, 0xfe, 0x00, 0xbd, 0xfc, 0x77, 0x00,
+	0xbd, 0x59, 0x01, 0xab, 0x0d, 0x0d, 0x00, 0x00,
 }
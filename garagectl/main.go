@@ -0,0 +1,307 @@
+// Command garagectl operates and inspects garage doors through the mirror.
+//
+//	garagectl [flags] <command> [door]
+//
+// Commands are ping, toggle, open, close, status, watch, history and doors.
+// The door may be left out when only one door is known.
+//
+// A device is added by an admin creating a pairing code for it:
+//
+//	garagectl -role member pair kitchen-tablet
+//
+// The device then exchanges the code for a client certificate:
+//
+//	garagectl -cert tablet.pem -certkey tablet.key enroll ABCD-EFGH
+//
+// An admin may create a guest pass instead, for a visitor to use with -pass
+// until it expires or is used up:
+//
+//	garagectl -doors north -for 4h -uses 2 pass delivery
+//
+// garagectl exits with one of the following codes:
+//
+//	0 success
+//	1 other error
+//	2 invalid usage, or an unknown door
+//	3 the command was refused, such as by a safety input
+//	4 the mirror or the door opener is unavailable or did not answer
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+	"github.com/kardianos/garage/comm/client"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Exit codes.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitRefused     = 3
+	exitUnavailable = 4
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `usage: garagectl [flags] <command> [door | name | code | id]
+
+commands:
+  ping     check the mirror has an opener connected, for the door if given
+  toggle   press the door button
+  open     open the door unless it is already open
+  close    close the door unless it is already closed
+  status   show the door status
+  watch    show the door status as it changes
+  history  show recent events
+  doors    list the known doors
+  pair     create a pairing code for the device called name
+  enroll   exchange a pairing code or URI for the certificate in -cert
+  pass     create a guest pass called name, limited by -doors, -for and -uses
+  passes   list the guest passes
+  revoke   revoke the guest pass with the given ID
+
+flags:
+`)
+	flag.PrintDefaults()
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// codedError is an error that ends garagectl with code.
+type codedError struct {
+	error
+	code int
+}
+
+func errorf(code int, format string, v ...interface{}) error {
+	return codedError{fmt.Errorf(format, v...), code}
+}
+
+// run runs the command given on the command line and returns the exit code.
+func run() int {
+	addr := flag.String("addr", fmt.Sprintf("%s:%d", comm.Host(), comm.Port()), "mirror address, or the local address of an opener")
+	key := flag.String("key", comm.AuthKey(), "auth key to send, if any")
+	asJSON := flag.Bool("json", false, "write JSON instead of text")
+	timeout := flag.Duration("timeout", 10*time.Second, "time to wait for an answer")
+	limit := flag.Int("n", 20, "number of history events to show, 0 for all")
+	certFile := flag.String("cert", "", "client certificate file to send, or to write with enroll")
+	certKeyFile := flag.String("certkey", "", "key file for -cert")
+	role := flag.String("role", "member", "role to pair a device as: admin, member, guest or opener")
+	ttl := flag.Duration("ttl", 0, "how long a pairing code lasts, the mirror default if zero")
+	qr := flag.Bool("qr", false, "show the pairing URI as a QR code, using qrencode")
+	passToken := flag.String("pass", "", "guest pass to send instead of a certificate or auth key")
+	passDoors := flag.String("doors", "", "comma separated doors a pass covers, all doors if empty")
+	passFor := flag.Duration("for", 0, "how long a pass lasts, no time limit if zero")
+	passUses := flag.Int("uses", 0, "how many commands a pass allows, no limit if zero")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 1 || len(args) > 2 {
+		usage()
+		return exitUsage
+	}
+	cmd, door := args[0], ""
+	if len(args) == 2 {
+		door = args[1]
+	}
+	out := newOutput(os.Stdout, *asJSON)
+	if (len(*certFile) == 0) != (len(*certKeyFile) == 0) {
+		return out.fail(errorf(exitUsage, "-cert and -certkey must be set together"))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	config := client.Config{Addr: *addr, Key: *key, Pass: *passToken}
+	if cmd == "enroll" {
+		err := enroll(ctx, out, config, door, *certFile, *certKeyFile)
+		if err != nil {
+			return out.fail(err)
+		}
+		return out.exit()
+	}
+	if len(*certFile) != 0 {
+		var err error
+		config.Cert, err = ioutil.ReadFile(*certFile)
+		if err != nil {
+			return out.fail(codedError{err, exitUsage})
+		}
+		config.CertKey, err = ioutil.ReadFile(*certKeyFile)
+		if err != nil {
+			return out.fail(codedError{err, exitUsage})
+		}
+	}
+	conn, err := client.Dial(ctx, config, grpc.WithBlock())
+	if err != nil {
+		return out.fail(errorf(exitUnavailable, "failed to connect to %s: %v", *addr, err))
+	}
+	defer conn.Close()
+	gc := comm.NewGarageClient(conn)
+
+	switch cmd {
+	default:
+		err = errorf(exitUsage, "unknown command %q", cmd)
+	case "ping":
+		err = ping(ctx, gc, door)
+		if err == nil {
+			out.ok("ping", door, "")
+		}
+	case "toggle", "open", "close":
+		action := map[string]comm.Action{
+			"toggle": comm.Action_TOGGLE,
+			"open":   comm.Action_OPEN_DOOR,
+			"close":  comm.Action_CLOSE_DOOR,
+		}[cmd]
+		var resp *comm.ToggleResp
+		resp, err = gc.Toggle(ctx, &comm.ToggleReq{TimeUnix: time.Now().Unix(), Action: action, Door: door})
+		if err == nil {
+			out.ok(cmd, door, resp.Message)
+		}
+	case "status":
+		var resp *comm.StatusResp
+		resp, err = gc.Status(ctx, &comm.StatusReq{Door: door})
+		if err == nil {
+			out.status(resp)
+		}
+	case "doors":
+		var resp *comm.DoorsResp
+		resp, err = gc.Doors(ctx, &comm.DoorsReq{})
+		if err == nil {
+			out.doors(resp.Doors)
+		}
+	case "history":
+		var resp *comm.HistoryResp
+		resp, err = gc.History(ctx, &comm.HistoryReq{Door: door, Limit: int32(*limit)})
+		if err == nil {
+			out.history(resp.Events)
+		}
+	case "pair":
+		r, found := comm.Role_value[strings.ToUpper(*role)]
+		if !found || r == int32(comm.Role_NO_ROLE) {
+			err = errorf(exitUsage, "unknown role %q", *role)
+			break
+		}
+		var resp *comm.PairResp
+		resp, err = gc.Pair(ctx, &comm.PairReq{Name: door, Role: comm.Role(r), TTLSeconds: int64(*ttl / time.Second)})
+		if err == nil {
+			err = out.pairing(door, comm.Role(r), resp, client.PairingURI(*addr, resp.Code), *qr)
+		}
+	case "pass":
+		req := &comm.CreatePassReq{Name: door, MaxUses: int32(*passUses)}
+		if len(*passDoors) != 0 {
+			req.Doors = strings.Split(*passDoors, ",")
+		}
+		if *passFor > 0 {
+			req.ExpiresUnix = time.Now().Add(*passFor).Unix()
+		}
+		var resp *comm.Pass
+		resp, err = gc.CreatePass(ctx, req)
+		if err == nil {
+			out.passes([]*comm.Pass{resp})
+		}
+	case "passes":
+		var resp *comm.ListPassesResp
+		resp, err = gc.ListPasses(ctx, &comm.ListPassesReq{})
+		if err == nil {
+			out.passes(resp.Passes)
+		}
+	case "revoke":
+		var resp *comm.Pass
+		resp, err = gc.RevokePass(ctx, &comm.RevokePassReq{ID: door})
+		if err == nil {
+			out.passes([]*comm.Pass{resp})
+		}
+	case "watch":
+		// Watch until interrupted; only the connection uses the timeout.
+		var wc comm.Garage_WatchClient
+		wc, err = gc.Watch(context.Background(), &comm.WatchReq{Door: door})
+		if err == nil {
+			err = out.watch(wc)
+		}
+	}
+	if err != nil {
+		return out.fail(err)
+	}
+	return out.exit()
+}
+
+// ping checks the mirror has an opener connected, or that door has when
+// it is set.
+func ping(ctx context.Context, gc comm.GarageClient, door string) error {
+	_, err := gc.Ping(ctx, &comm.PingReq{TimeUnix: time.Now().Unix()})
+	if err != nil || len(door) == 0 {
+		return err
+	}
+	resp, err := gc.Status(ctx, &comm.StatusReq{Door: door})
+	if err != nil {
+		return err
+	}
+	if !resp.OpenerOnline {
+		return errorf(exitUnavailable, "door %q has no opener connected", resp.Door)
+	}
+	return nil
+}
+
+// enroll exchanges a pairing code or URI for a client certificate and
+// writes it to certFile and keyFile, named after the device if empty.
+func enroll(ctx context.Context, out *output, c client.Config, pairing, certFile, keyFile string) error {
+	addr, code, err := client.ParsePairing(pairing)
+	if err != nil || len(code) == 0 {
+		return errorf(exitUsage, "enroll needs a pairing code or URI")
+	}
+	if len(addr) != 0 {
+		c.Addr = addr
+	}
+	e, err := client.Enroll(ctx, c, code)
+	if err != nil {
+		return err
+	}
+	if len(certFile) == 0 {
+		// The name comes from the mirror, so it may only name a file in
+		// the working directory.
+		name := e.Name
+		if len(name) == 0 || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || filepath.Base(name) != name {
+			return fmt.Errorf("the mirror sent the device name %q, which can not name a file, give -cert and -certkey", name)
+		}
+		certFile, keyFile = name+".pem", name+".key"
+	}
+	err = ioutil.WriteFile(keyFile, e.CertKey, 0600)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(certFile, e.Cert, 0644)
+	if err != nil {
+		return err
+	}
+	out.ok("enroll", "", fmt.Sprintf("enrolled %q as %s, wrote %s and %s", e.Name, strings.ToLower(e.Role.String()), certFile, keyFile))
+	return nil
+}
+
+// exitCode maps an error to an exit code.
+func exitCode(err error) int {
+	if ce, ok := err.(codedError); ok {
+		return ce.code
+	}
+	switch grpc.Code(err) {
+	case codes.InvalidArgument, codes.NotFound:
+		return exitUsage
+	case codes.FailedPrecondition, codes.PermissionDenied, codes.Unauthenticated:
+		return exitRefused
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return exitUnavailable
+	}
+	return exitError
+}
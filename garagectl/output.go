@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"google.golang.org/grpc"
+)
+
+// output writes results as text or as JSON, one value per line.
+type output struct {
+	w    io.Writer
+	json bool
+	// live redraws the watch view in place, as w is a terminal.
+	live bool
+	// err is the first error writing JSON.
+	err error
+}
+
+func newOutput(f *os.File, asJSON bool) *output {
+	o := &output{w: f, json: asJSON}
+	if fi, err := f.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		o.live = !asJSON
+	}
+	return o
+}
+
+// doorJSON is the JSON form of a door status.
+type doorJSON struct {
+	Door   string    `json:"door"`
+	Online bool      `json:"online"`
+	State  string    `json:"state"`
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason,omitempty"`
+	Fault  bool      `json:"fault,omitempty"`
+}
+
+// eventJSON is the JSON form of an event.
+type eventJSON struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Door    string    `json:"door"`
+	State   string    `json:"state,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Fault   bool      `json:"fault,omitempty"`
+	Action  string    `json:"action,omitempty"`
+	Actor   string    `json:"actor,omitempty"`
+	OK      *bool     `json:"ok,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+func newDoorJSON(name string, online bool, st *comm.DoorStatus) doorJSON {
+	if st == nil {
+		st = &comm.DoorStatus{}
+	}
+	return doorJSON{
+		Door:   name,
+		Online: online,
+		State:  st.State.String(),
+		Since:  time.Unix(st.SinceUnix, 0),
+		Reason: st.Reason,
+		Fault:  st.Fault,
+	}
+}
+
+func newEventJSON(ev *comm.Event) eventJSON {
+	j := eventJSON{
+		Time: time.Unix(ev.TimeUnix, 0),
+		Kind: ev.Kind.String(),
+		Door: ev.Door,
+	}
+	if st := ev.Status; st != nil {
+		j.State, j.Reason, j.Fault = st.State.String(), st.Reason, st.Fault
+	}
+	switch ev.Kind {
+	case comm.EventKind_COMMAND:
+		ok := ev.OK
+		j.Action, j.Actor, j.OK, j.Message = ev.Action.String(), ev.Actor, &ok, ev.Message
+	case comm.EventKind_NOTICE:
+		j.Message = ev.Message
+	}
+	return j
+}
+
+func (o *output) writeJSON(v interface{}) {
+	err := json.NewEncoder(o.w).Encode(v)
+	if err != nil && o.err == nil {
+		o.err = err
+	}
+}
+
+// fail reports err and returns the exit code for it.
+func (o *output) fail(err error) int {
+	code := exitCode(err)
+	msg := grpc.ErrorDesc(err)
+	if o.json {
+		o.writeJSON(struct {
+			Error string `json:"error"`
+			Code  int    `json:"code"`
+		}{msg, code})
+	}
+	fmt.Fprintln(os.Stderr, "garagectl:", msg)
+	return code
+}
+
+// exit returns the exit code of a successful command, which fails if
+// its JSON could not be written.
+func (o *output) exit() int {
+	if o.err != nil {
+		fmt.Fprintln(os.Stderr, "garagectl:", o.err)
+		return exitError
+	}
+	return exitOK
+}
+
+// ok reports a successful command.
+func (o *output) ok(cmd, door, msg string) {
+	if o.json {
+		o.writeJSON(struct {
+			Command string `json:"command"`
+			Door    string `json:"door,omitempty"`
+			OK      bool   `json:"ok"`
+			Message string `json:"message,omitempty"`
+		}{cmd, door, true, msg})
+		return
+	}
+	if len(msg) == 0 {
+		msg = "ok"
+	}
+	fmt.Fprintln(o.w, msg)
+}
+
+// pairing reports a new pairing code, as a QR code too if qr is set.
+func (o *output) pairing(name string, role comm.Role, resp *comm.PairResp, uri string, qr bool) error {
+	expires := time.Unix(resp.ExpiresUnix, 0)
+	if o.json {
+		o.writeJSON(struct {
+			Code    string    `json:"code"`
+			Name    string    `json:"name"`
+			Role    string    `json:"role"`
+			Expires time.Time `json:"expires"`
+			URI     string    `json:"uri"`
+		}{resp.Code, name, strings.ToLower(role.String()), expires, uri})
+		return nil
+	}
+	fmt.Fprintf(o.w, "pairing code %s for %q as %s, expires %s\n%s\n", resp.Code, name, strings.ToLower(role.String()), expires.Format("15:04:05"), uri)
+	if !qr {
+		return nil
+	}
+	cmd := exec.Command("qrencode", "-t", "ANSIUTF8", uri)
+	cmd.Stdout, cmd.Stderr = o.w, os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("failed to show the QR code, is qrencode installed? %v", err)
+	}
+	return nil
+}
+
+func (o *output) status(resp *comm.StatusResp) {
+	if o.json {
+		o.writeJSON(newDoorJSON(resp.Door, resp.OpenerOnline, resp.Status))
+		return
+	}
+	fmt.Fprintln(o.w, formatDoor(resp.Door, resp.OpenerOnline, resp.Status))
+}
+
+func (o *output) doors(doors []*comm.Door) {
+	if o.json {
+		list := make([]doorJSON, len(doors))
+		for i, d := range doors {
+			list[i] = newDoorJSON(d.Name, d.Online, d.Status)
+		}
+		o.writeJSON(list)
+		return
+	}
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOOR\tONLINE\tSTATE\tSINCE\tREASON")
+	for _, d := range doors {
+		st := d.Status
+		if st == nil {
+			st = &comm.DoorStatus{}
+		}
+		state := st.State.String()
+		if st.Fault {
+			state += " (fault)"
+		}
+		fmt.Fprintf(tw, "%s\t%t\t%s\t%s\t%s\n", d.Name, d.Online, state, formatTime(st.SinceUnix), st.Reason)
+	}
+	tw.Flush()
+}
+
+// passJSON is the JSON form of a guest pass.
+type passJSON struct {
+	ID      string     `json:"id"`
+	Token   string     `json:"token,omitempty"`
+	Name    string     `json:"name"`
+	Doors   []string   `json:"doors,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+	MaxUses int32      `json:"maxUses,omitempty"`
+	Uses    int32      `json:"uses"`
+	Creator string     `json:"creator"`
+	Created time.Time  `json:"created"`
+	Revoked bool       `json:"revoked,omitempty"`
+}
+
+// passes lists guest passes. The token is only known to the mirror
+// when a pass is created, so it is shown then.
+func (o *output) passes(passes []*comm.Pass) {
+	if o.json {
+		list := make([]passJSON, len(passes))
+		for i, p := range passes {
+			list[i] = passJSON{
+				ID:      p.ID,
+				Token:   p.Token,
+				Name:    p.Name,
+				Doors:   p.Doors,
+				MaxUses: p.MaxUses,
+				Uses:    p.Uses,
+				Creator: p.Creator,
+				Created: time.Unix(p.CreatedUnix, 0),
+				Revoked: p.Revoked,
+			}
+			if p.ExpiresUnix != 0 {
+				expires := time.Unix(p.ExpiresUnix, 0)
+				list[i].Expires = &expires
+			}
+		}
+		o.writeJSON(list)
+		return
+	}
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tDOORS\tEXPIRES\tUSES\tCREATOR\tSTATE")
+	for _, p := range passes {
+		doors := strings.Join(p.Doors, ",")
+		if len(doors) == 0 {
+			doors = "all"
+		}
+		uses := fmt.Sprintf("%d", p.Uses)
+		if p.MaxUses != 0 {
+			uses += fmt.Sprintf("/%d", p.MaxUses)
+		}
+		state := "active"
+		switch {
+		case p.Revoked:
+			state = "revoked"
+		case p.MaxUses != 0 && p.Uses >= p.MaxUses:
+			state = "used up"
+		case p.ExpiresUnix != 0 && time.Now().Unix() >= p.ExpiresUnix:
+			state = "expired"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", p.ID, p.Name, doors, formatTime(p.ExpiresUnix), uses, p.Creator, state)
+	}
+	tw.Flush()
+	for _, p := range passes {
+		if len(p.Token) != 0 {
+			fmt.Fprintf(o.w, "\nGive the guest this pass, to use with garagectl -pass:\n%s\n", p.Token)
+		}
+	}
+}
+
+func (o *output) history(events []*comm.Event) {
+	if o.json {
+		list := make([]eventJSON, len(events))
+		for i, ev := range events {
+			list[i] = newEventJSON(ev)
+		}
+		o.writeJSON(list)
+		return
+	}
+	for _, ev := range events {
+		fmt.Fprintln(o.w, formatEvent(ev))
+	}
+}
+
+// watchLines is how many recent events the live watch view shows.
+const watchLines = 10
+
+// watch writes each event until the stream ends. A terminal shows a view
+// of each door that is redrawn as events arrive.
+func (o *output) watch(wc comm.Garage_WatchClient) error {
+	type doorView struct {
+		online bool
+		status *comm.DoorStatus
+	}
+	doors := make(map[string]*doorView)
+	var names, recent []string
+	for {
+		ev, err := wc.Recv()
+		if err == io.EOF {
+			return fmt.Errorf("the mirror ended the watch")
+		}
+		if err != nil {
+			return err
+		}
+		if o.json {
+			o.writeJSON(newEventJSON(ev))
+			continue
+		}
+		if !o.live {
+			fmt.Fprintln(o.w, formatEvent(ev))
+			continue
+		}
+
+		recent = append(recent, formatEvent(ev))
+		if len(recent) > watchLines {
+			recent = recent[len(recent)-watchLines:]
+		}
+		// Notices are only listed.
+		if ev.Kind != comm.EventKind_NOTICE {
+			d := doors[ev.Door]
+			if d == nil {
+				d = &doorView{}
+				doors[ev.Door] = d
+				names = append(names, ev.Door)
+			}
+			switch ev.Kind {
+			case comm.EventKind_CONNECTED:
+				d.online = true
+			case comm.EventKind_DISCONNECTED:
+				d.online = false
+			}
+			if ev.Status != nil {
+				d.status = ev.Status
+			}
+		}
+
+		fmt.Fprint(o.w, "\x1b[H\x1b[2J")
+		for _, name := range names {
+			fmt.Fprintln(o.w, formatDoor(name, doors[name].online, doors[name].status))
+		}
+		fmt.Fprintln(o.w)
+		for _, line := range recent {
+			fmt.Fprintln(o.w, line)
+		}
+	}
+}
+
+func formatTime(unix int64) string {
+	if unix == 0 {
+		return "-"
+	}
+	return time.Unix(unix, 0).Format("2006-01-02 15:04:05")
+}
+
+func formatDoor(name string, online bool, st *comm.DoorStatus) string {
+	if len(name) == 0 {
+		name = "door"
+	}
+	if st == nil {
+		st = &comm.DoorStatus{}
+	}
+	s := fmt.Sprintf("%s: %v since %s", name, st.State, formatTime(st.SinceUnix))
+	if len(st.Reason) != 0 {
+		s += ", " + st.Reason
+	}
+	if st.Fault {
+		s += " (fault)"
+	}
+	if !online {
+		s += " (opener offline)"
+	}
+	return s
+}
+
+func formatEvent(ev *comm.Event) string {
+	door := ev.Door
+	if len(door) == 0 {
+		door = "mirror"
+	}
+	s := fmt.Sprintf("%s %s %v", formatTime(ev.TimeUnix), door, ev.Kind)
+	switch ev.Kind {
+	case comm.EventKind_NOTICE:
+		s += ": " + ev.Message
+	case comm.EventKind_COMMAND:
+		result := "ok"
+		if !ev.OK {
+			result = "failed"
+		}
+		s += fmt.Sprintf(" %v by %s: %s", ev.Action, ev.Actor, result)
+		if len(ev.Message) != 0 {
+			s += ", " + ev.Message
+		}
+	default:
+		if st := ev.Status; st != nil {
+			s += fmt.Sprintf(" %v", st.State)
+			if len(st.Reason) != 0 {
+				s += ", " + st.Reason
+			}
+			if st.Fault {
+				s += " (fault)"
+			}
+		}
+	}
+	return s
+}
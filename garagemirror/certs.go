@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+)
+
+// certPoll is how often the certificate files are checked for changes.
+const certPoll = 10 * time.Second
+
+// expiryCheck is how often certificate expiry is checked. A warning
+// is repeated each check until the certificate is replaced.
+const expiryCheck = 24 * time.Hour
+
+var (
+	// certExpiryDays maps "server" and "ca" to the days left before
+	// each expires.
+	certExpiryDays   = expvar.NewMap("cert_expiry_days")
+	certReloads      = expvar.NewInt("cert_reloads")
+	certReloadErrors = expvar.NewInt("cert_reload_errors")
+)
+
+// certStore serves the mirror certificate. When it is read from files it
+// is loaded again when the files change or on SIGHUP. Only new connections
+// use a new certificate, so no connection is dropped.
+type certStore struct {
+	certFile, keyFile, caFile string
+	// warn is how long before expiry to warn.
+	warn time.Duration
+	// notice tells the people using the mirror of msg.
+	notice func(msg string)
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	leaf    *x509.Certificate
+	ca      *x509.Certificate
+	modTime time.Time
+}
+
+// newCertStore loads the certificate from certFile and keyFile, or uses
+// the compiled in certificate if certFile is empty. The CA is read from
+// caFile, or is the compiled in CA if caFile is empty.
+func newCertStore(certFile, keyFile, caFile string, warn time.Duration, notice func(msg string)) (*certStore, error) {
+	s := &certStore{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		warn:     warn,
+		notice:   notice,
+	}
+	err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCertificate returns the current certificate for tls.Config.
+func (s *certStore) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert, nil
+}
+
+// load reads the certificate, key and CA. The current certificate is kept
+// if any fails to load.
+func (s *certStore) load() error {
+	certPEM, keyPEM, caPEM := comm.Cert(), comm.Key(), comm.CA()
+	var modTime time.Time
+	var err error
+	if len(s.certFile) != 0 {
+		modTime = s.lastModified()
+		certPEM, err = ioutil.ReadFile(s.certFile)
+		if err != nil {
+			return err
+		}
+		keyPEM, err = ioutil.ReadFile(s.keyFile)
+		if err != nil {
+			return err
+		}
+	}
+	if len(s.caFile) != 0 {
+		caPEM, err = ioutil.ReadFile(s.caFile)
+		if err != nil {
+			return err
+		}
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load cert and key: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	ca, err := parseCert(caPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load CA: %v", err)
+	}
+
+	s.mu.Lock()
+	s.cert, s.leaf, s.ca, s.modTime = &cert, leaf, ca, modTime
+	s.mu.Unlock()
+	return nil
+}
+
+// verifyClient checks a client certificate, if one was sent, against
+// the current CA.
+func (s *certStore) verifyClient(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return nil
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	s.mu.RLock()
+	roots := x509.NewCertPool()
+	roots.AddCert(s.ca)
+	s.mu.RUnlock()
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+// lastModified returns the latest modification time of the files.
+func (s *certStore) lastModified() time.Time {
+	var latest time.Time
+	for _, name := range []string{s.certFile, s.keyFile, s.caFile} {
+		if len(name) == 0 {
+			continue
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
+// reload loads the certificate again and logs the result.
+func (s *certStore) reload(why string) {
+	err := s.load()
+	if err != nil {
+		certReloadErrors.Add(1)
+		logger.Errorf("certificate not reloaded after %s, keeping the current one: %v", why, err)
+		return
+	}
+	certReloads.Add(1)
+	s.mu.RLock()
+	leaf := s.leaf
+	s.mu.RUnlock()
+	logger.Infof("certificate reloaded after %s, %q expires %s", why, leaf.Subject.CommonName, leaf.NotAfter.Format("2006-01-02"))
+	s.checkExpiry(time.Now())
+}
+
+// checkExpiry warns if the certificate or CA expires within the warn time.
+func (s *certStore) checkExpiry(now time.Time) {
+	s.mu.RLock()
+	certs := []struct {
+		name string
+		cert *x509.Certificate
+	}{
+		{"server", s.leaf},
+		{"ca", s.ca},
+	}
+	s.mu.RUnlock()
+
+	for _, c := range certs {
+		left := c.cert.NotAfter.Sub(now)
+		certExpiryDays.Set(c.name, expvarFloat(left.Hours()/24))
+		if left > s.warn {
+			continue
+		}
+		var msg string
+		if left <= 0 {
+			msg = fmt.Sprintf("the mirror %s certificate %q expired on %s", c.name, c.cert.Subject.CommonName, c.cert.NotAfter.Format("2006-01-02"))
+		} else {
+			msg = fmt.Sprintf("the mirror %s certificate %q expires in %d days, on %s", c.name, c.cert.Subject.CommonName, int(left.Hours()/24), c.cert.NotAfter.Format("2006-01-02"))
+		}
+		logger.Warning(msg)
+		s.notice(msg)
+	}
+}
+
+// run reloads the certificate when the files change or on SIGHUP, and
+// checks its expiry, until ctx is done.
+func (s *certStore) run(ctx context.Context) {
+	s.checkExpiry(time.Now())
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	poll := time.NewTicker(certPoll)
+	defer poll.Stop()
+	expiry := time.NewTicker(expiryCheck)
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			s.reload("SIGHUP")
+		case <-poll.C:
+			if len(s.certFile) == 0 {
+				continue
+			}
+			s.mu.RLock()
+			modTime := s.modTime
+			s.mu.RUnlock()
+			if s.lastModified().After(modTime) {
+				s.reload("a file changed")
+			}
+		case now := <-expiry.C:
+			s.checkExpiry(now)
+		}
+	}
+}
+
+// expvarFloat is a float expvar.Var that is set as a whole.
+type expvarFloat float64
+
+func (f expvarFloat) String() string {
+	return fmt.Sprintf("%.1f", float64(f))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/kardianos/garage/comm"
+)
+
+// historySize is how many events the mirror keeps in memory.
+const historySize = 500
+
+// watchBuffer is how many events a watcher may fall behind by before
+// it is dropped.
+const watchBuffer = 32
+
+// eventLog keeps recent events and sends new events to watchers.
+type eventLog struct {
+	mu     sync.Mutex
+	events []*comm.Event // Ring buffer, oldest at next once full.
+	next   int
+	full   bool
+
+	// watchers maps each watcher to the door it watches, or to an
+	// empty string for all doors.
+	watchers map[chan *comm.Event]string
+}
+
+func newEventLog(size int) *eventLog {
+	return &eventLog{
+		events:   make([]*comm.Event, size),
+		watchers: make(map[chan *comm.Event]string),
+	}
+}
+
+// add records ev and sends it to the watchers. A watcher that is too far
+// behind is closed.
+func (l *eventLog) add(ev *comm.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events[l.next] = ev
+	l.next++
+	if l.next == len(l.events) {
+		l.next = 0
+		l.full = true
+	}
+	for w, door := range l.watchers {
+		if len(door) != 0 && len(ev.Door) != 0 && door != ev.Door {
+			continue
+		}
+		select {
+		case w <- ev:
+		default:
+			delete(l.watchers, w)
+			close(w)
+		}
+	}
+}
+
+// list returns up to limit of the most recent events, oldest first.
+// Only events for door, and events for no door, are returned if door is set.
+// A limit of zero returns all kept events.
+func (l *eventLog) list(door string, limit int) []*comm.Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ordered := l.events[:l.next]
+	if l.full {
+		ordered = append(append([]*comm.Event{}, l.events[l.next:]...), l.events[:l.next]...)
+	}
+	var list []*comm.Event
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if limit > 0 && len(list) == limit {
+			break
+		}
+		ev := ordered[i]
+		if len(door) != 0 && len(ev.Door) != 0 && ev.Door != door {
+			continue
+		}
+		list = append(list, ev)
+	}
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return list
+}
+
+// watch returns a channel that receives new events for door and events
+// for no door, or for all doors if door is empty. The channel is closed if the watcher falls
+// behind. Call cancel when done watching.
+func (l *eventLog) watch(door string) (events chan *comm.Event, cancel func()) {
+	w := make(chan *comm.Event, watchBuffer)
+	l.mu.Lock()
+	l.watchers[w] = door
+	l.mu.Unlock()
+	return w, func() {
+		l.mu.Lock()
+		if _, found := l.watchers[w]; found {
+			delete(l.watchers, w)
+			close(w)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kardianos/garage/comm"
+)
+
+func TestEventLogList(t *testing.T) {
+	// events returns an event for each door, with times counting from one.
+	events := func(doors ...string) []*comm.Event {
+		list := make([]*comm.Event, len(doors))
+		for i, door := range doors {
+			list[i] = &comm.Event{TimeUnix: int64(i + 1), Door: door}
+		}
+		return list
+	}
+	list := []struct {
+		name  string
+		size  int
+		add   []string // The door of each added event.
+		door  string
+		limit int
+		want  []int64 // The times of the listed events.
+	}{
+		{name: "empty", size: 4},
+		{name: "not full", size: 4, add: []string{"a", "a"}, want: []int64{1, 2}},
+		{name: "exactly full", size: 4, add: []string{"a", "a", "a", "a"}, want: []int64{1, 2, 3, 4}},
+		{name: "wrapped", size: 4, add: []string{"a", "a", "a", "a", "a", "a"}, want: []int64{3, 4, 5, 6}},
+		{name: "wrapped twice", size: 3, add: []string{"a", "a", "a", "a", "a", "a", "a"}, want: []int64{5, 6, 7}},
+		{name: "limit", size: 4, add: []string{"a", "a", "a", "a", "a"}, limit: 2, want: []int64{4, 5}},
+		{name: "limit over kept", size: 4, add: []string{"a", "a"}, limit: 10, want: []int64{1, 2}},
+		{name: "door", size: 8, add: []string{"a", "b", "", "a", "b"}, door: "a", want: []int64{1, 3, 4}},
+		{name: "door and limit", size: 8, add: []string{"a", "b", "", "a", "b"}, door: "b", limit: 2, want: []int64{3, 5}},
+		{name: "door wrapped", size: 3, add: []string{"a", "b", "a", "b", "a"}, door: "a", want: []int64{3, 5}},
+	}
+	for _, item := range list {
+		l := newEventLog(item.size)
+		for _, ev := range events(item.add...) {
+			l.add(ev)
+		}
+		var got []int64
+		for _, ev := range l.list(item.door, item.limit) {
+			got = append(got, ev.TimeUnix)
+		}
+		if !reflect.DeepEqual(got, item.want) {
+			t.Errorf("%s: got %v, want %v", item.name, got, item.want)
+		}
+	}
+}
+
+func TestEventLogWatch(t *testing.T) {
+	l := newEventLog(4)
+	all, cancelAll := l.watch("")
+	defer cancelAll()
+	north, cancelNorth := l.watch("north")
+	defer cancelNorth()
+
+	l.add(&comm.Event{TimeUnix: 1, Door: "north"})
+	l.add(&comm.Event{TimeUnix: 2, Door: "south"})
+	l.add(&comm.Event{TimeUnix: 3})
+	for _, want := range []int64{1, 2, 3} {
+		if ev := <-all; ev.TimeUnix != want {
+			t.Errorf("all doors: got %d, want %d", ev.TimeUnix, want)
+		}
+	}
+	for _, want := range []int64{1, 3} {
+		if ev := <-north; ev.TimeUnix != want {
+			t.Errorf("north: got %d, want %d", ev.TimeUnix, want)
+		}
+	}
+
+	// A watcher that falls behind is closed, the others keep watching.
+	for i := 0; i <= watchBuffer; i++ {
+		l.add(&comm.Event{TimeUnix: int64(10 + i), Door: "south"})
+	}
+	for range all {
+	}
+	select {
+	case ev, ok := <-north:
+		t.Errorf("north: got %v %t, want nothing", ev, ok)
+	default:
+	}
+	// Cancel after the log closed the watcher must not close it again.
+	cancelAll()
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// The line protocol is for devices that can not use gRPC, such as door
+// openers on a microcontroller and shell scripts. It is served over TLS on
+// the -line address with the same certificates as gRPC, and each line is a
+// JSON comm.Request or comm.Response.
+//
+// A device first authenticates with the auth key, a guest pass, or only
+// its client certificate, and an opener names its door:
+//
+//	{"Type":"auth","Body":"client key=<auth key>"}
+//	{"Type":"auth","Body":"client pass=<guest pass>"}
+//	{"Type":"auth","Body":"opener key=<auth key> door=north"}
+//
+// Each request is answered by a response, such as:
+//
+//	{"OK":false,"Message":"PermissionDenied: only an admin may pair a device"}
+//
+// A client may then send ping, status, toggle, open and close, each with
+// the door as the body, or an empty body when there is one door. The
+// message of a status response is the state followed by the reason:
+//
+//	{"Type":"status","Body":"north"}
+//	{"OK":true,"Message":"CLOSED closed switch active"}
+//
+// An opener sends its status the same way, and a ping at least every
+// lineIdle so the mirror knows it is still there:
+//
+//	{"Type":"status","Body":"OPENING relay pressed while closed"}
+//
+// The mirror sends an opener toggle, open and close requests, which the
+// opener answers in order. When the mirror shuts down it sends a reconnect
+// request and closes the connection; the opener should connect again after
+// a short delay.
+
+const (
+	// lineIdle is how long a connection may send nothing.
+	lineIdle = 2 * time.Minute
+	// lineAuthTimeout is how long a device has to authenticate.
+	lineAuthTimeout = 10 * time.Second
+	// lineWriteTimeout is how long a line may take to send.
+	lineWriteTimeout = 10 * time.Second
+	// lineMax is the longest line read.
+	lineMax = 4096
+)
+
+// lineMsg is a line read from a device. It is a response if Type is empty.
+type lineMsg struct {
+	Type    string
+	Body    string
+	OK      bool
+	Message string
+}
+
+// lineConn reads and writes the lines of a connection.
+type lineConn struct {
+	conn net.Conn
+	scan *bufio.Scanner
+	enc  *json.Encoder
+}
+
+func newLineConn(conn net.Conn) *lineConn {
+	scan := bufio.NewScanner(conn)
+	scan.Buffer(make([]byte, 0, 512), lineMax)
+	return &lineConn{conn: conn, scan: scan, enc: json.NewEncoder(conn)}
+}
+
+// invalidLineError is returned by read for a line that is not JSON.
+type invalidLineError struct {
+	err error
+}
+
+func (e invalidLineError) Error() string {
+	return fmt.Sprintf("invalid line: %v", e.err)
+}
+
+// read returns the next line, waiting at most timeout. Blank lines are
+// skipped. A line that is not JSON returns an invalidLineError, which
+// the caller answers with readFailed before ending the connection. read
+// never writes, so it may run beside a loop that does.
+func (lc *lineConn) read(timeout time.Duration) (lineMsg, error) {
+	var msg lineMsg
+	for {
+		lc.conn.SetReadDeadline(time.Now().Add(timeout))
+		if !lc.scan.Scan() {
+			err := lc.scan.Err()
+			if err == nil {
+				err = io.EOF
+			}
+			return msg, err
+		}
+		if line := bytes.TrimSpace(lc.scan.Bytes()); len(line) != 0 {
+			break
+		}
+	}
+	err := json.Unmarshal(lc.scan.Bytes(), &msg)
+	if err != nil {
+		return msg, invalidLineError{err: err}
+	}
+	return msg, nil
+}
+
+// readFailed answers the error from read if it was an invalid line.
+func (lc *lineConn) readFailed(err error) {
+	if err, ok := err.(invalidLineError); ok {
+		lc.write(comm.Response{Message: "InvalidArgument: " + err.Error()})
+	}
+}
+
+// write sends v as a line.
+func (lc *lineConn) write(v interface{}) error {
+	lc.conn.SetWriteDeadline(time.Now().Add(lineWriteTimeout))
+	return lc.enc.Encode(v)
+}
+
+// lineError is the response to a failed request, with the gRPC code
+// first so a script may match on it.
+func lineError(err error) comm.Response {
+	return comm.Response{Message: fmt.Sprintf("%v: %s", grpc.Code(err), grpc.ErrorDesc(err))}
+}
+
+// serveLine serves the line protocol on addr until the mirror stops.
+func (m *mirror) serveLine(addr string, config *tls.Config) error {
+	l, err := tls.Listen("tcp", addr, config)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %v", addr, err)
+	}
+	go func() {
+		<-m.appCtx.Done()
+		l.Close()
+	}()
+	m.serving.Add(1)
+	go func() {
+		defer m.serving.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					time.Sleep(100 * time.Millisecond)
+					continue
+				}
+				if m.appCtx.Err() == nil {
+					logger.Error("line protocol stopped: ", err)
+				}
+				return
+			}
+			m.serving.Add(1)
+			go func() {
+				defer m.serving.Done()
+				m.serveLineConn(conn.(*tls.Conn))
+			}()
+		}
+	}()
+	return nil
+}
+
+// serveLineConn authenticates a device and serves it as a client or opener.
+func (m *mirror) serveLineConn(conn *tls.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(lineAuthTimeout))
+	err := conn.Handshake()
+	if err != nil {
+		logger.Warningf("line %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	ctx := peer.NewContext(m.appCtx, &peer.Peer{
+		Addr:     conn.RemoteAddr(),
+		AuthInfo: credentials.TLSInfo{State: conn.ConnectionState()},
+	})
+	lc := newLineConn(conn)
+	msg, err := lc.read(lineAuthTimeout)
+	if err != nil {
+		lc.readFailed(err)
+		return
+	}
+	if msg.Type != comm.RequestAuth {
+		lc.write(comm.Response{Message: "Unauthenticated: send an auth request first"})
+		return
+	}
+	from, key, pass, name := "", "", "", defaultDoor
+	for i, field := range strings.Fields(msg.Body) {
+		if i == 0 {
+			from = field
+			continue
+		}
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			lc.write(comm.Response{Message: fmt.Sprintf("InvalidArgument: %q is not name=value", field)})
+			return
+		}
+		switch kv[0] {
+		case "key":
+			key = kv[1]
+		case "pass":
+			pass = kv[1]
+		case "door":
+			name = kv[1]
+		default:
+			lc.write(comm.Response{Message: fmt.Sprintf("InvalidArgument: unknown auth field %q", kv[0])})
+			return
+		}
+	}
+	ctx = comm.NewContext(ctx, key, pass)
+
+	var method string
+	switch from {
+	case comm.FromClient:
+		// Each request is checked again, so only the identity is checked here.
+		method = "Ping"
+	case comm.FromOpener:
+		method = "Garage"
+	default:
+		lc.write(comm.Response{Message: fmt.Sprintf("InvalidArgument: auth must be from %q or %q", comm.FromClient, comm.FromOpener)})
+		return
+	}
+	id, err := m.who(ctx)
+	if err == nil {
+		err = m.authorize(ctx, method)
+	}
+	if err != nil {
+		logger.Warningf("line %s auth as %s: %v", actor(ctx), from, grpc.ErrorDesc(err))
+		lc.write(lineError(err))
+		return
+	}
+	var o *opener
+	var d *door
+	if from == comm.FromOpener {
+		o = &opener{
+			commands: make(chan command, 6),
+		}
+		d, err = m.connect(ctx, o, &comm.OpenerInfo{Driver: "line", Door: name})
+		if err != nil {
+			lc.write(lineError(err))
+			return
+		}
+		defer m.disconnect(o, d)
+	}
+	err = lc.write(comm.Response{OK: true, Message: fmt.Sprintf("%s %q", roleName(id.role), id.name)})
+	if err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	if o != nil {
+		m.lineOpener(lc, o, d)
+		return
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// Commands were drained before the mirror stops, so a client
+		// waiting for its next request can be cut off. An opener is
+		// told to reconnect by lineOpener.
+		select {
+		case <-done:
+		case <-m.appCtx.Done():
+			conn.Close()
+		}
+	}()
+	for {
+		msg, err := lc.read(lineIdle)
+		if err != nil {
+			lc.readFailed(err)
+			return
+		}
+		err = lc.write(m.lineRequest(ctx, msg))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// lineRequest answers a request from a client.
+func (m *mirror) lineRequest(ctx context.Context, req lineMsg) comm.Response {
+	method := map[string]string{
+		comm.RequestPing:   "Ping",
+		comm.RequestStatus: "Status",
+		comm.RequestToggle: "Toggle",
+		comm.RequestOpen:   "Toggle",
+		comm.RequestClose:  "Toggle",
+	}[req.Type]
+	if len(method) == 0 {
+		return comm.Response{Message: fmt.Sprintf("InvalidArgument: unknown request %q", req.Type)}
+	}
+	if err := m.authorize(ctx, method); err != nil {
+		logger.Warningf("line %s %s: %v", actor(ctx), req.Type, grpc.ErrorDesc(err))
+		return lineError(err)
+	}
+	door := strings.TrimSpace(req.Body)
+	now := time.Now().Unix()
+	switch req.Type {
+	case comm.RequestPing:
+		if len(door) == 0 {
+			_, err := m.Ping(ctx, &comm.PingReq{TimeUnix: now})
+			if err != nil {
+				return lineError(err)
+			}
+			return comm.Response{OK: true}
+		}
+		resp, err := m.Status(ctx, &comm.StatusReq{Door: door})
+		if err != nil {
+			return lineError(err)
+		}
+		if !resp.OpenerOnline {
+			return lineError(grpc.Errorf(codes.Unavailable, "door %q is offline", resp.Door))
+		}
+		return comm.Response{OK: true}
+	case comm.RequestStatus:
+		resp, err := m.Status(ctx, &comm.StatusReq{Door: door})
+		if err != nil {
+			return lineError(err)
+		}
+		st := resp.Status
+		if st == nil {
+			st = &comm.DoorStatus{}
+		}
+		msg := st.State.String()
+		if len(st.Reason) != 0 {
+			msg += " " + st.Reason
+		}
+		if st.Fault {
+			msg += " (fault)"
+		}
+		if !resp.OpenerOnline {
+			msg += " (opener offline)"
+		}
+		return comm.Response{OK: true, Message: msg}
+	}
+	action := map[string]comm.Action{
+		comm.RequestToggle: comm.Action_TOGGLE,
+		comm.RequestOpen:   comm.Action_OPEN_DOOR,
+		comm.RequestClose:  comm.Action_CLOSE_DOOR,
+	}[req.Type]
+	resp, err := m.Toggle(ctx, &comm.ToggleReq{TimeUnix: now, Action: action, Door: door})
+	if err != nil {
+		return lineError(err)
+	}
+	return comm.Response{OK: true, Message: resp.Message}
+}
+
+// lineOpener serves o, the connected opener of d.
+func (m *mirror) lineOpener(lc *lineConn, o *opener, d *door) {
+	done := make(chan struct{})
+	defer close(done)
+	recv := make(chan lineMsg)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := lc.read(lineIdle)
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case recv <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// sent are the IDs of the commands not yet answered, oldest first.
+	var sent []uint64
+	for {
+		select {
+		case <-m.appCtx.Done():
+			lc.write(comm.Request{Type: comm.RequestReconnect})
+			return
+		case err := <-errc:
+			lc.readFailed(err)
+			if err != io.EOF {
+				logger.Warningf("line opener of door %q: %v", d.name, err)
+			}
+			return
+		case c := <-o.commands:
+			req := comm.Request{Type: map[comm.Action]string{
+				comm.Action_TOGGLE:     comm.RequestToggle,
+				comm.Action_OPEN_DOOR:  comm.RequestOpen,
+				comm.Action_CLOSE_DOOR: comm.RequestClose,
+			}[c.action]}
+			if err := lc.write(req); err != nil {
+				return
+			}
+			sent = append(sent, c.id)
+		case msg := <-recv:
+			if len(msg.Type) == 0 {
+				if len(sent) == 0 {
+					logger.Warningf("line opener of door %q answered no command: %s", d.name, msg.Message)
+					continue
+				}
+				m.finish(&comm.CommandResult{ID: sent[0], OK: msg.OK, Message: msg.Message})
+				sent = sent[1:]
+				continue
+			}
+			if err := lc.write(m.lineOpenerRequest(d, msg)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// lineOpenerRequest answers a request from the opener of d.
+func (m *mirror) lineOpenerRequest(d *door, req lineMsg) comm.Response {
+	switch req.Type {
+	default:
+		return comm.Response{Message: fmt.Sprintf("InvalidArgument: unknown request %q", req.Type)}
+	case comm.RequestPing:
+		return comm.Response{OK: true}
+	case comm.RequestStatus:
+	}
+	fields := strings.SplitN(strings.TrimSpace(req.Body), " ", 2)
+	state, found := comm.DoorState_value[strings.ToUpper(fields[0])]
+	if !found {
+		return comm.Response{Message: fmt.Sprintf("InvalidArgument: unknown state %q", fields[0])}
+	}
+	now := time.Now().Unix()
+	st := &comm.DoorStatus{State: comm.DoorState(state), SinceUnix: now}
+	if len(fields) == 2 {
+		st.Reason = fields[1]
+	}
+	// The opener only reports the state, so keep the time it started.
+	m.RLock()
+	if cur := d.status; cur != nil && cur.State == st.State && cur.Reason == st.Reason {
+		st.SinceUnix = cur.SinceUnix
+	}
+	m.RUnlock()
+	m.setStatus(d, st, now)
+	return comm.Response{OK: true}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestLineConnRead(t *testing.T) {
+	list := []struct {
+		name  string
+		input string
+		want  []lineMsg
+		// invalid is set if reading ends with an invalid line, and
+		// tooLong if it ends with a line over lineMax, otherwise it
+		// ends with io.EOF.
+		invalid bool
+		tooLong bool
+	}{
+		{name: "empty"},
+		{name: "request", input: `{"Type":"ping","Body":"north"}` + "\n", want: []lineMsg{{Type: "ping", Body: "north"}}},
+		{name: "response", input: `{"OK":true,"Message":"pressed"}` + "\n", want: []lineMsg{{OK: true, Message: "pressed"}}},
+		{name: "no last newline", input: `{"Type":"ping"}`, want: []lineMsg{{Type: "ping"}}},
+		{name: "carriage returns", input: "{\"Type\":\"ping\"}\r\n{\"Type\":\"status\"}\r\n", want: []lineMsg{{Type: "ping"}, {Type: "status"}}},
+		{name: "blank lines", input: "\n  \n{\"Type\":\"ping\"}\n\n\t\n{\"Type\":\"status\"}\n\n", want: []lineMsg{{Type: "ping"}, {Type: "status"}}},
+		{name: "unknown fields", input: `{"Type":"ping","Extra":1}` + "\n", want: []lineMsg{{Type: "ping"}}},
+		{name: "not json", input: "{\"Type\":\"ping\"}\nping\n{\"Type\":\"status\"}\n", want: []lineMsg{{Type: "ping"}}, invalid: true},
+		{name: "wrong type", input: `{"Type":1}` + "\n", invalid: true},
+		{name: "too long", input: `{"Type":"` + strings.Repeat("x", lineMax) + `"}` + "\n", tooLong: true},
+	}
+	for _, item := range list {
+		client, server := net.Pipe()
+		go func() {
+			io.WriteString(client, item.input)
+			client.Close()
+		}()
+		lc := newLineConn(server)
+		var got []lineMsg
+		var err error
+		for {
+			var msg lineMsg
+			msg, err = lc.read(time.Second)
+			if err != nil {
+				break
+			}
+			got = append(got, msg)
+		}
+		server.Close()
+		if !reflect.DeepEqual(got, item.want) {
+			t.Errorf("%s: got %+v, want %+v", item.name, got, item.want)
+		}
+		_, invalid := err.(invalidLineError)
+		switch {
+		case item.invalid != invalid:
+			t.Errorf("%s: got error %v, want invalid line %t", item.name, err, item.invalid)
+		case item.tooLong && err != bufio.ErrTooLong:
+			t.Errorf("%s: got error %v, want %v", item.name, err, bufio.ErrTooLong)
+		case !item.invalid && !item.tooLong && err != io.EOF:
+			t.Errorf("%s: got error %v, want EOF", item.name, err)
+		}
+	}
+}
+
+func TestLineConnReadFailed(t *testing.T) {
+	list := []struct {
+		name  string
+		input string
+		// reply is the start of the reply message, or empty if no
+		// reply is sent.
+		reply string
+	}{
+		{name: "not json", input: "ping\n", reply: "InvalidArgument: invalid line: "},
+		{name: "wrong type", input: `{"OK":"yes"}` + "\n", reply: "InvalidArgument: invalid line: "},
+		{name: "hung up"},
+	}
+	for _, item := range list {
+		client, server := net.Pipe()
+		replies := make(chan []comm.Response, 1)
+		go func() {
+			io.WriteString(client, item.input)
+			var list []comm.Response
+			dec := json.NewDecoder(client)
+			for {
+				var resp comm.Response
+				if dec.Decode(&resp) != nil {
+					break
+				}
+				list = append(list, resp)
+			}
+			replies <- list
+		}()
+		lc := newLineConn(server)
+		// With no input the device hung up, which is not answered.
+		err := error(io.EOF)
+		if len(item.input) != 0 {
+			_, err = lc.read(time.Second)
+		}
+		lc.readFailed(err)
+		server.Close()
+		got := <-replies
+		client.Close()
+
+		switch {
+		case len(item.reply) == 0 && len(got) != 0:
+			t.Errorf("%s: got replies %+v, want none", item.name, got)
+		case len(item.reply) != 0 && (len(got) != 1 || got[0].OK || !strings.HasPrefix(got[0].Message, item.reply)):
+			t.Errorf("%s: got replies %+v, want one starting %q", item.name, got, item.reply)
+		}
+	}
+}
+
+func TestLineError(t *testing.T) {
+	list := []struct {
+		err  error
+		want string
+	}{
+		{grpc.Errorf(codes.PermissionDenied, "only an admin may pair a device"), "PermissionDenied: only an admin may pair a device"},
+		{grpc.Errorf(codes.Unavailable, "door %q is offline", "north"), `Unavailable: door "north" is offline`},
+	}
+	for _, item := range list {
+		got := lineError(item.err)
+		if got.OK || got.Message != item.want {
+			t.Errorf("%v: got %+v, want %q", item.err, got, item.want)
+		}
+	}
+}
@@ -2,86 +2,262 @@ package main
 
 import (
 	"crypto/tls"
-	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/kardianos/garage/comm"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/kardianos/service"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
 )
 
 var _ service.Interface = &program{}
 
 type program struct {
-	quit func()
+	config mirrorConfig
+	quit   func()
+
+	m        *mirror
+	grpc     *grpc.Server
+	stopOnce sync.Once
+}
+
+// mirrorConfig is set from the command line flags.
+type mirrorConfig struct {
+	certFile, keyFile, caFile string
+	// caKeyFile is the CA key used to sign enrolled devices, if any.
+	caKeyFile string
+	// warn is how long before a certificate expires to warn.
+	warn time.Duration
+	// metrics is the address expvar metrics are served on, if any.
+	metrics string
+	// policyFile limits members and guests, if set.
+	policyFile string
+	// passesFile keeps guest passes across restarts, if set.
+	passesFile string
+	// lineAddr is the address the line protocol is served on, if any.
+	lineAddr string
+	// webAddr is the address the web UI is served on, if any.
+	webAddr string
 }
 
-func (p *program) Start(svc service.Service) error {
+func (p *program) Start(svc service.Service) (err error) {
 	ctx, quit := context.WithCancel(context.Background())
 	p.quit = quit
+	// If the mirror fails to start, what was started stops with the
+	// context.
+	defer func() {
+		if err != nil {
+			quit()
+		}
+	}()
 
 	on := fmt.Sprintf(":%d", comm.Port())
 
-	cert, err := tls.X509KeyPair(comm.Cert(), comm.Key())
+	m := &mirror{
+		appCtx:  ctx,
+		doors:   make(map[string]*door, 3),
+		pending: make(map[uint64]chan *comm.CommandResult),
+		events:  newEventLog(historySize),
+	}
+
+	c := p.config
+	certs, err := newCertStore(c.certFile, c.keyFile, c.caFile, c.warn, func(msg string) { m.notice("", msg) })
+	if err != nil {
+		return err
+	}
+	go certs.run(ctx)
+	tlsConfig := &tls.Config{
+		GetCertificate: certs.GetCertificate,
+		// Client certificates are optional and are checked against the current CA.
+		ClientAuth:            tls.RequestClientCert,
+		VerifyPeerCertificate: certs.verifyClient,
+	}
+	creds := credentials.NewTLS(tlsConfig)
+	if len(c.caKeyFile) != 0 {
+		m.enroll, err = newEnroller(c.caFile, c.caKeyFile)
+		if err != nil {
+			return fmt.Errorf("pairing: %v", err)
+		}
+	}
+	pol, err := loadPolicy(c.policyFile)
 	if err != nil {
-		return fmt.Errorf("failed to load cert and key", err)
+		return err
+	}
+	m.policy = &policyStore{file: c.policyFile, p: pol}
+	go m.policy.run(ctx)
+	m.passes, err = loadPasses(c.passesFile)
+	if err != nil {
+		return err
 	}
-	creds := credentials.NewServerTLSFromCert(&cert)
 
-	s := grpc.NewServer(
+	s := grpc.NewServer(append([]grpc.ServerOption{
 		grpc.Creds(creds),
-	)
+	}, m.authInterceptors()...)...)
 	listener, err := net.Listen("tcp", on)
 	if err != nil {
 		return fmt.Errorf("failed to listen on %q", on)
 	}
-	m := &mirror{
-		appCtx: ctx,
-		g:      make(map[comm.Garage_GarageServer]chan time.Time, 3),
+	// gRPC is served once the line protocol and web UI are listening, so
+	// a failure to start either leaves nothing behind.
+	if len(c.lineAddr) != 0 {
+		if err := m.serveLine(c.lineAddr, tlsConfig); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+	if len(c.webAddr) != 0 {
+		if err := m.serveWeb(c.webAddr, tlsConfig); err != nil {
+			listener.Close()
+			return err
+		}
 	}
 	comm.RegisterGarageServer(s, m)
+	p.m, p.grpc = m, s
 	go func() {
-		err = s.Serve(listener)
-		if err != nil {
-			log.Fatal("failed to serve", err)
+		err := s.Serve(listener)
+		if ctx.Err() != nil {
+			// Stopped by shutdown.
+			return
 		}
+		logger.Error("failed to serve: ", err)
+		p.shutdown()
+		os.Exit(1)
 	}()
+	if len(c.metrics) != 0 {
+		// expvar serves /debug/vars on the default mux.
+		go func() {
+			err := http.ListenAndServe(c.metrics, nil)
+			if err != nil {
+				logger.Error("metrics ", err)
+			}
+		}()
+	}
 
 	return nil
 }
 
 func (p *program) Stop(s service.Service) error {
-	// Stop should not block. Return with a few seconds.
-	p.quit()
-	go func() {
-		<-time.After(3 * time.Second)
-		os.Exit(0)
-	}()
-	return nil
+	return p.shutdown()
+}
+
+// shutdownTimeout is how long requests in progress have to finish when
+// the mirror shuts down.
+const shutdownTimeout = 10 * time.Second
+
+// shutdown stops the mirror. Commands in flight are answered or failed,
+// openers are told to reconnect, other requests have until shutdownTimeout
+// to finish, and the guest passes are saved.
+func (p *program) shutdown() error {
+	var err error
+	p.stopOnce.Do(func() {
+		if p.m == nil {
+			p.quit()
+			return
+		}
+		m := p.m
+		deadline := time.Now().Add(commandTimeout + shutdownTimeout)
+		m.drain(commandTimeout)
+		// Streams end and openers are told to reconnect once the
+		// context is done.
+		p.quit()
+
+		stopped := make(chan struct{})
+		go func() {
+			p.grpc.GracefulStop()
+			m.serving.Wait()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(time.Until(deadline)):
+			logger.Warning("requests still in progress at shutdown were closed")
+			p.grpc.Stop()
+		}
+		err = m.passes.flush()
+		if err != nil {
+			logger.Error("failed to save guest passes: ", err)
+		}
+		logger.Info("mirror stopped")
+	})
+	return err
 }
 
 var logger service.Logger
 
 func main() {
 	svcFlag := flag.String("service", "", "control the service")
+	certFile := flag.String("cert", "", "certificate file, reloaded when it changes or on SIGHUP; the compiled in certificate if empty")
+	keyFile := flag.String("key", "", "key file for -cert")
+	caFile := flag.String("ca", "", "CA certificate file checked for expiry; the compiled in CA if empty")
+	caKeyFile := flag.String("ca-key", "", "CA key file for -ca, to sign the certificates of paired devices")
+	warnDays := flag.Int("warn-days", 30, "warn this many days before a certificate expires")
+	policyFile := flag.String("policy", "", "JSON file limiting the doors and times of members and guests, read again on SIGHUP")
+	webAddr := flag.String("web", "", "address to serve the web UI on over HTTPS, such as :443")
+	lineAddr := flag.String("line", "", "address to serve the JSON line protocol on, such as :8443, for devices that can not use gRPC")
+	passesFile := flag.String("passes", "", "file to keep guest passes in; passes are lost on restart if empty")
+	metrics := flag.String("metrics", "", "address to serve expvar metrics on at /debug/vars, such as localhost:6060")
 	flag.Parse()
 
+	if flag.Arg(0) == "pki" {
+		os.Exit(runPKI(flag.Args()[1:]))
+	}
+
+	if (len(*certFile) == 0) != (len(*keyFile) == 0) {
+		log.Fatal("-cert and -key must be set together")
+	}
+	if len(*caKeyFile) != 0 && len(*caFile) == 0 {
+		log.Fatal("-ca-key needs -ca")
+	}
+	config := mirrorConfig{
+		certFile:   *certFile,
+		keyFile:    *keyFile,
+		caFile:     *caFile,
+		caKeyFile:  *caKeyFile,
+		warn:       time.Duration(*warnDays) * 24 * time.Hour,
+		metrics:    *metrics,
+		policyFile: *policyFile,
+		passesFile: *passesFile,
+		lineAddr:   *lineAddr,
+		webAddr:    *webAddr,
+	}
+
 	svcConfig := &service.Config{
 		Name:        "garagemirror",
 		DisplayName: "Garage Mirror",
 		Description: "Interface between the garage remote and device.",
 	}
+	// Run the installed service with the same flags.
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "service" {
+			return
+		}
+		v := f.Value.String()
+		switch f.Name {
+		case "cert", "key", "ca", "ca-key", "policy", "passes":
+			abs, err := filepath.Abs(v)
+			if err != nil {
+				log.Fatal(err)
+			}
+			v = abs
+		}
+		svcConfig.Arguments = append(svcConfig.Arguments, "-"+f.Name, v)
+	})
 
-	prg := &program{}
+	prg := &program{config: config}
 	s, err := service.New(prg, svcConfig)
 	if err != nil {
 		log.Fatal(err)
@@ -101,52 +277,479 @@ func main() {
 	err = s.Run()
 	if err != nil {
 		logger.Error(err)
+		os.Exit(1)
 	}
 }
 
+// commandTimeout is how long to wait for an opener to answer a command.
+const commandTimeout = 5 * time.Second
+
+// defaultDoor names the door of an opener that does not name its door.
+const defaultDoor = "garage"
+
 type mirror struct {
 	appCtx context.Context
 	sync.RWMutex
-	g map[comm.Garage_GarageServer]chan time.Time
+	doors map[string]*door
+
+	nextID  uint64
+	pending map[uint64]chan *comm.CommandResult
+
+	// stopping is set when the mirror shuts down, after which no new
+	// commands are sent. commands counts the commands in flight.
+	stopping bool
+	commands sync.WaitGroup
+	// serving counts the line and web servers and their connections.
+	serving sync.WaitGroup
+
+	events *eventLog
+	// enroll is nil when devices can not be paired.
+	enroll *enroller
+	policy *policyStore
+	passes *passStore
+}
+
+// door is a door the mirror has seen an opener for.
+type door struct {
+	name string
+	// opener is nil while no opener for the door is connected.
+	opener  *opener
+	info    *comm.OpenerInfo
+	status  *comm.DoorStatus
+	changed time.Time
+}
+
+// opener is a connected garage opener.
+type opener struct {
+	commands chan command
+}
+
+// command is sent to an opener, which answers with a CommandResult.
+type command struct {
+	id     uint64
+	action comm.Action
+	at     time.Time
+}
+
+// lookup finds the named door. An empty name selects the only door.
+// The mirror must be locked.
+func (m *mirror) lookup(name string) (*door, error) {
+	if len(name) != 0 {
+		d, found := m.doors[name]
+		if !found {
+			return nil, grpc.Errorf(codes.NotFound, "unknown door %q", name)
+		}
+		return d, nil
+	}
+	switch len(m.doors) {
+	case 0:
+		return nil, grpc.Errorf(codes.Unavailable, "Garage Not Registered")
+	case 1:
+		for _, d := range m.doors {
+			return d, nil
+		}
+	}
+	names := make([]string, 0, len(m.doors))
+	for name := range m.doors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, grpc.Errorf(codes.InvalidArgument, "more than one door, name one of %q", names)
+}
+
+// actor identifies who sent a request, by name if they sent a client
+// certificate, and by address.
+func actor(ctx context.Context) string {
+	addr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+	if id, ok := identify(ctx); ok {
+		return fmt.Sprintf("%s (%s)", id.name, addr)
+	}
+	return addr
 }
 
 func (m *mirror) Ping(ctx context.Context, _ *comm.PingReq) (*comm.PingResp, error) {
+	if err := m.allow(ctx, ""); err != nil {
+		return nil, err
+	}
 	m.RLock()
-	ct := len(m.g)
+	online := 0
+	for _, d := range m.doors {
+		if d.opener != nil {
+			online++
+		}
+	}
 	m.RUnlock()
 
-	if ct == 0 {
-		return nil, errors.New("Garage Not Registered")
+	if online == 0 {
+		return nil, grpc.Errorf(codes.Unavailable, "Garage Not Registered")
 	}
 
 	return &comm.PingResp{}, nil
 }
+
 func (m *mirror) Toggle(ctx context.Context, req *comm.ToggleReq) (*comm.ToggleResp, error) {
+	m.Lock()
+	if m.stopping {
+		m.Unlock()
+		return nil, grpc.Errorf(codes.Unavailable, "the mirror is shutting down, try again shortly")
+	}
+	m.commands.Add(1)
+	defer m.commands.Done()
+	d, err := m.lookup(req.Door)
+	if err != nil {
+		m.Unlock()
+		return nil, err
+	}
+	name := d.name
+	if err := m.allow(ctx, name); err != nil {
+		m.Unlock()
+		m.events.add(&comm.Event{
+			TimeUnix: time.Now().Unix(),
+			Kind:     comm.EventKind_COMMAND,
+			Door:     name,
+			Action:   req.Action,
+			Actor:    actor(ctx),
+			Message:  grpc.ErrorDesc(err),
+		})
+		return nil, err
+	}
+	if d.opener == nil {
+		m.Unlock()
+		return nil, grpc.Errorf(codes.Unavailable, "door %q is offline", d.name)
+	}
+	ev := &comm.Event{
+		TimeUnix: time.Now().Unix(),
+		Kind:     comm.EventKind_COMMAND,
+		Door:     name,
+		Action:   req.Action,
+		Actor:    actor(ctx),
+	}
+	// A use of a guest pass is counted before the command is sent, so the
+	// last use can not be spent twice. It is given back only if the command
+	// is never sent, as once sent the button may be pressed even if the
+	// answer never comes.
+	sender, _ := m.who(ctx)
+	used, err := m.usePass(sender)
+	if err != nil {
+		m.Unlock()
+		return nil, err
+	}
+	if len(sender.pass) != 0 {
+		ev.Actor = fmt.Sprintf("%s (%s)", sender.name, ev.Actor)
+	}
+	m.nextID++
+	id := m.nextID
+	results := make(chan *comm.CommandResult, 1)
 	sent := false
-	m.RLock()
-	for _, action := range m.g {
+	select {
+	case d.opener.commands <- command{id: id, action: req.Action, at: time.Unix(req.TimeUnix, 0)}:
+		m.pending[id] = results
 		sent = true
-		action <- time.Unix(req.TimeUnix, 0)
+	default:
+		results <- &comm.CommandResult{ID: id, Message: "opener busy"}
+	}
+	m.Unlock()
+
+	defer func() {
+		m.Lock()
+		delete(m.pending, id)
+		m.Unlock()
+	}()
+
+	if len(used.ID) != 0 {
+		if sent {
+			m.savePasses()
+			defer m.passUsed(used, ev)
+		} else {
+			m.unusePass(sender)
+		}
+	}
+	defer m.events.add(ev)
+
+	timeout := time.NewTimer(commandTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-ctx.Done():
+		ev.Message = ctx.Err().Error()
+		return nil, ctx.Err()
+	case <-timeout.C:
+		ev.Message = "opener did not answer"
+		return nil, grpc.Errorf(codes.DeadlineExceeded, "opener did not answer the %v command", req.Action)
+	case res := <-results:
+		ev.OK, ev.Message = res.OK, res.Message
+		if !res.OK {
+			return nil, grpc.Errorf(codes.FailedPrecondition, "%s", res.Message)
+		}
+		return &comm.ToggleResp{Message: res.Message}, nil
+	}
+}
+
+func (m *mirror) Status(ctx context.Context, req *comm.StatusReq) (*comm.StatusResp, error) {
+	if err := m.allow(ctx, req.Door); err != nil {
+		return nil, err
+	}
+	m.RLock()
+	defer m.RUnlock()
+
+	if len(m.doors) == 0 && len(req.Door) == 0 {
+		return &comm.StatusResp{}, nil
+	}
+	d, err := m.lookup(req.Door)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.allow(ctx, d.name); err != nil {
+		return nil, err
+	}
+	return &comm.StatusResp{Status: d.status, OpenerOnline: d.opener != nil, Door: d.name}, nil
+}
+
+func (m *mirror) Doors(ctx context.Context, _ *comm.DoorsReq) (*comm.DoorsResp, error) {
+	visible, err := m.visible(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.RLock()
+	defer m.RUnlock()
+
+	resp := &comm.DoorsResp{Doors: make([]*comm.Door, 0, len(m.doors))}
+	for _, d := range m.doors {
+		if !visible(d.name) {
+			continue
+		}
+		resp.Doors = append(resp.Doors, &comm.Door{
+			Name:        d.name,
+			Status:      d.status,
+			Info:        d.info,
+			Online:      d.opener != nil,
+			ChangedUnix: d.changed.Unix(),
+		})
+	}
+	sort.Slice(resp.Doors, func(i, j int) bool { return resp.Doors[i].Name < resp.Doors[j].Name })
+	return resp, nil
+}
+
+func (m *mirror) History(ctx context.Context, req *comm.HistoryReq) (*comm.HistoryResp, error) {
+	if req.Limit < 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "limit %d is negative", req.Limit)
+	}
+	if err := m.allow(ctx, req.Door); err != nil {
+		return nil, err
+	}
+	seen, err := m.seen(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Take all events when some may be hidden, then apply the limit.
+	events := m.events.list(req.Door, 0)
+	list := events[:0]
+	for _, ev := range events {
+		if seen(ev) {
+			list = append(list, ev)
+		}
+	}
+	if req.Limit > 0 && len(list) > int(req.Limit) {
+		list = list[len(list)-int(req.Limit):]
+	}
+	return &comm.HistoryResp{Events: list}, nil
+}
+
+func (m *mirror) Watch(req *comm.WatchReq, ws comm.Garage_WatchServer) error {
+	ctx := ws.Context()
+	if err := m.allow(ctx, req.Door); err != nil {
+		return err
+	}
+	visible, err := m.visible(ctx)
+	if err != nil {
+		return err
+	}
+	seen, err := m.seen(ctx)
+	if err != nil {
+		return err
+	}
+	events, cancel := m.events.watch(req.Door)
+	defer cancel()
+
+	// Send the current state of each door first.
+	m.RLock()
+	if _, found := m.doors[req.Door]; len(req.Door) != 0 && !found {
+		m.RUnlock()
+		return grpc.Errorf(codes.NotFound, "unknown door %q", req.Door)
+	}
+	var current []*comm.Event
+	now := time.Now().Unix()
+	for _, d := range m.doors {
+		if (len(req.Door) != 0 && d.name != req.Door) || !visible(d.name) {
+			continue
+		}
+		kind := comm.EventKind_DISCONNECTED
+		if d.opener != nil {
+			kind = comm.EventKind_CONNECTED
+		}
+		current = append(current, &comm.Event{TimeUnix: now, Kind: kind, Door: d.name, Status: d.status})
 	}
 	m.RUnlock()
+	sort.Slice(current, func(i, j int) bool { return current[i].Door < current[j].Door })
+	for _, ev := range current {
+		err := ws.Send(ev)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-m.appCtx.Done():
+			return nil
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return grpc.Errorf(codes.ResourceExhausted, "watch fell behind, watch again")
+			}
+			// End the watch when the sender may no longer use doors.
+			if err := m.allow(ctx, ""); err != nil {
+				return err
+			}
+			if !seen(ev) {
+				continue
+			}
+			err := ws.Send(ev)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
 
-	if !sent {
-		return nil, errors.New("Garage Not Registered")
+// drain stops new commands and waits up to timeout for the commands in
+// flight to be answered. Any still waiting are then failed.
+func (m *mirror) drain(timeout time.Duration) {
+	m.Lock()
+	m.stopping = true
+	m.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.commands.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-time.After(timeout):
+	}
+	m.Lock()
+	for id, results := range m.pending {
+		delete(m.pending, id)
+		results <- &comm.CommandResult{ID: id, Message: "the mirror is shutting down"}
 	}
+	m.Unlock()
+}
 
-	return &comm.ToggleResp{}, nil
+// notice records msg as a NOTICE event for the watchers of door, or of
+// all doors if door is empty.
+func (m *mirror) notice(door, msg string) {
+	m.events.add(&comm.Event{TimeUnix: time.Now().Unix(), Kind: comm.EventKind_NOTICE, Door: door, Message: msg})
 }
 
-func (m *mirror) Garage(ggs comm.Garage_GarageServer) error {
-	notify := make(chan time.Time, 6)
+// connect registers o as the opener of the door named in info.
+// Only an opener whose client certificate has the OPENER role and is
+// named for the door may replace a connected opener; any other is refused
+// while the door has one, so a caller cannot take over its commands.
+func (m *mirror) connect(ctx context.Context, o *opener, info *comm.OpenerInfo) (*door, error) {
+	name := defaultDoor
+	if info != nil && len(info.Door) != 0 {
+		name = info.Door
+	}
+	id, ok := identify(ctx)
+	owner := ok && id.role == comm.Role_OPENER && id.name == name
+	now := time.Now()
 	m.Lock()
-	m.g[ggs] = notify
+	d := m.doors[name]
+	if d == nil {
+		d = &door{name: name}
+		m.doors[name] = d
+	}
+	if d.opener != nil {
+		if !owner {
+			m.Unlock()
+			logger.Warningf("door %q: refused a second opener from %s", name, actor(ctx))
+			return nil, grpc.Errorf(codes.AlreadyExists, "door %q already has an opener, only its own certificate may replace it", name)
+		}
+		logger.Warningf("door %q: a second opener connected, replacing the first", name)
+	}
+	d.opener = o
+	d.info = info
+	d.changed = now
 	m.Unlock()
 
+	logger.Infof("opener connected to door %q: %v", name, info)
+	m.events.add(&comm.Event{TimeUnix: now.Unix(), Kind: comm.EventKind_CONNECTED, Door: name})
+	return d, nil
+}
+
+// disconnect marks the door offline if o is still its opener.
+func (m *mirror) disconnect(o *opener, d *door) {
+	now := time.Now()
+	m.Lock()
+	current := d.opener == o
+	if current {
+		d.opener = nil
+		d.changed = now
+	}
+	m.Unlock()
+
+	if current {
+		logger.Infof("opener disconnected from door %q", d.name)
+		m.events.add(&comm.Event{TimeUnix: now.Unix(), Kind: comm.EventKind_DISCONNECTED, Door: d.name})
+	}
+}
+
+// setStatus records a status reported by the opener of d, unless it
+// repeats the current status.
+func (m *mirror) setStatus(d *door, st *comm.DoorStatus, timeUnix int64) {
+	m.Lock()
+	repeated := proto.Equal(st, d.status)
+	if !repeated {
+		d.status = st
+	}
+	m.Unlock()
+	if repeated {
+		return
+	}
+	if st.Fault {
+		logger.Warningf("door %q fault %v: %s", d.name, st.State, st.Reason)
+	} else {
+		logger.Infof("door %q %v since %v: %s", d.name, st.State, time.Unix(st.SinceUnix, 0), st.Reason)
+	}
+	m.events.add(&comm.Event{TimeUnix: timeUnix, Kind: comm.EventKind_STATUS, Door: d.name, Status: st})
+}
+
+// finish passes the result of a command to the request waiting for it.
+func (m *mirror) finish(res *comm.CommandResult) {
+	m.Lock()
+	results := m.pending[res.ID]
+	delete(m.pending, res.ID)
+	m.Unlock()
+	if results != nil {
+		results <- res
+	}
+}
+
+func (m *mirror) Garage(ggs comm.Garage_GarageServer) error {
+	o := &opener{
+		commands: make(chan command, 6),
+	}
+	var d *door
 	defer func() {
-		m.Lock()
-		delete(m.g, ggs)
-		m.Unlock()
+		if d != nil {
+			m.disconnect(o, d)
+		}
 	}()
 
 	recv := make(chan *comm.FromGarage)
@@ -164,15 +767,32 @@ func (m *mirror) Garage(ggs comm.Garage_GarageServer) error {
 	for {
 		select {
 		case <-m.appCtx.Done():
+			// The opener connects again once a mirror is back.
+			ggs.Send(&comm.ToGarage{TimeUnix: time.Now().Unix(), Reconnect: true})
 			return nil
 		case <-ctx.Done():
 			return nil
-		case n := <-notify:
-			err := ggs.Send(&comm.ToGarage{TimeUnix: n.Unix(), Toggle: true})
+		case c := <-o.commands:
+			err := ggs.Send(&comm.ToGarage{TimeUnix: c.at.Unix(), Toggle: true, ID: c.id, Action: c.action})
 			if err != nil {
 				return fmt.Errorf("garage send %v", err)
 			}
 		case fg := <-recv:
+			// The opener sends its info first. Older openers send none
+			// and operate the default door.
+			if d == nil {
+				var err error
+				d, err = m.connect(ctx, o, fg.Info)
+				if err != nil {
+					return err
+				}
+			}
+			if fg.Status != nil {
+				m.setStatus(d, fg.Status, fg.TimeUnix)
+			}
+			if fg.Result != nil {
+				m.finish(fg.Result)
+			}
 			err := ggs.Send(&comm.ToGarage{TimeUnix: fg.TimeUnix})
 			if err != nil {
 				return fmt.Errorf("garage send %v", err)
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"testing"
+
+	"github.com/kardianos/garage/comm"
+	"github.com/kardianos/service"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestMain(m *testing.M) {
+	logger = service.ConsoleLogger
+	os.Exit(m.Run())
+}
+
+// certState returns the state of a connection with a client certificate
+// named name with the role ou.
+func certState(name, ou string) tls.ConnectionState {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: name, OrganizationalUnit: []string{ou}}}
+	return tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+}
+
+// certContext returns a request context with a client certificate
+// named name with the role ou, or none if name is empty.
+func certContext(name, ou string) context.Context {
+	ctx := context.Background()
+	if len(name) == 0 {
+		return ctx
+	}
+	return peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{State: certState(name, ou)}})
+}
+
+func TestConnectReplace(t *testing.T) {
+	list := []struct {
+		name string
+		// cn and ou name the certificate of the second opener.
+		cn, ou string
+		// live is set if the first opener is still connected.
+		live bool
+		code codes.Code
+	}{
+		{name: "key only, door free", code: codes.OK},
+		{name: "key only, door taken", live: true, code: codes.AlreadyExists},
+		{name: "opener of the door", cn: "north", ou: "opener", live: true, code: codes.OK},
+		{name: "opener of another door", cn: "south", ou: "opener", live: true, code: codes.AlreadyExists},
+		{name: "admin named for the door", cn: "north", ou: "admin", live: true, code: codes.AlreadyExists},
+	}
+	info := &comm.OpenerInfo{Door: "north"}
+	for _, item := range list {
+		m := &mirror{doors: make(map[string]*door), events: newEventLog(10)}
+		first := &opener{}
+		d, err := m.connect(context.Background(), first, info)
+		if err != nil {
+			t.Fatalf("%s: first opener: %v", item.name, err)
+		}
+		if !item.live {
+			m.disconnect(first, d)
+		}
+		second := &opener{}
+		_, err = m.connect(certContext(item.cn, item.ou), second, info)
+		if code := grpc.Code(err); code != item.code {
+			t.Errorf("%s: got %v (%v), want %v", item.name, code, err, item.code)
+		}
+		want := second
+		if item.code != codes.OK {
+			want = first
+		}
+		if got := m.doors["north"].opener; got != want {
+			t.Errorf("%s: the door has the wrong opener", item.name)
+		}
+	}
+}
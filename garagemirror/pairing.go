@@ -0,0 +1,282 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// pairTTL is how long a pairing code lasts unless another time is asked for.
+const pairTTL = 10 * time.Minute
+
+// maxPairTTL is the longest a pairing code may last.
+const maxPairTTL = 24 * time.Hour
+
+// enrollDays is how long an enrolled client certificate is valid.
+const enrollDays = 825
+
+// enrollFailDelay slows down guessing pairing codes.
+const enrollFailDelay = time.Second
+
+// enrollMaxFails is how many enrollments may fail in enrollFailWindow,
+// from all devices together, before further attempts are refused.
+const (
+	enrollMaxFails   = 10
+	enrollFailWindow = time.Minute
+)
+
+// pairAlphabet leaves out letters and digits that are easily confused.
+const pairAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// pairing is what a pairing code enrolls a device as.
+type pairing struct {
+	name    string
+	role    comm.Role
+	expires time.Time
+}
+
+// enroller signs client certificates for devices with a pairing code.
+type enroller struct {
+	caFile, caKeyFile string
+
+	mu    sync.Mutex
+	codes map[string]pairing
+	// fails are the times of the failed enrollments in the last
+	// enrollFailWindow.
+	fails []time.Time
+}
+
+func newEnroller(caFile, caKeyFile string) (*enroller, error) {
+	// The CA is read again for each enrollment, so it may be replaced,
+	// but is checked now so a bad key is found at start.
+	_, _, err := loadCAFiles(caFile, caKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &enroller{
+		caFile:    caFile,
+		caKeyFile: caKeyFile,
+		codes:     make(map[string]pairing),
+	}, nil
+}
+
+// create returns a new single use pairing code for p.
+func (e *enroller) create(p pairing) (string, error) {
+	b := make([]byte, 8)
+	max := big.NewInt(int64(len(pairAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = pairAlphabet[n.Int64()]
+	}
+	code := string(b[:4]) + "-" + string(b[4:])
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.expire(time.Now())
+	e.codes[normalizeCode(code)] = p
+	return code, nil
+}
+
+// find returns the pairing for code, which stays usable until taken.
+// An unknown code counts as a failed enrollment, and once too many have
+// failed every code is refused for a while.
+func (e *enroller) find(code string, now time.Time) (pairing, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.expire(now)
+	if len(e.fails) >= enrollMaxFails {
+		return pairing{}, grpc.Errorf(codes.Unavailable, "too many failed enrollments, try again in a minute")
+	}
+	p, found := e.codes[normalizeCode(code)]
+	if !found {
+		e.fails = append(e.fails, now)
+		return pairing{}, grpc.Errorf(codes.PermissionDenied, "unknown or expired pairing code")
+	}
+	return p, nil
+}
+
+// take removes code once it was used, reporting whether it was still
+// there, so each code enrolls one device.
+func (e *enroller) take(code string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	code = normalizeCode(code)
+	_, found := e.codes[code]
+	delete(e.codes, code)
+	return found
+}
+
+// expire removes expired codes and forgets old failures. The enroller
+// must be locked.
+func (e *enroller) expire(now time.Time) {
+	for code, p := range e.codes {
+		if !now.Before(p.expires) {
+			delete(e.codes, code)
+		}
+	}
+	i := 0
+	for i < len(e.fails) && now.Sub(e.fails[i]) >= enrollFailWindow {
+		i++
+	}
+	e.fails = e.fails[i:]
+}
+
+// normalizeCode allows a code to be typed in lower case and with or
+// without the dash.
+func normalizeCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+// parseCSR parses and checks a DER encoded certificate signing request.
+func parseCSR(der []byte) (*x509.CertificateRequest, error) {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid CSR: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "invalid CSR signature: %v", err)
+	}
+	return csr, nil
+}
+
+// sign issues a client certificate for the public key in csr.
+func (e *enroller) sign(csr *x509.CertificateRequest, p pairing) (certPEM, caPEM []byte, err error) {
+	caCert, caKey, err := loadCAFiles(e.caFile, e.caKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	caPEM, err = ioutil.ReadFile(e.caFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The subject comes from the pairing, not from the device.
+	tmpl, err := certTemplate(p.name, enrollDays)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl.Subject.OrganizationalUnit = []string{roleName(p.role)}
+	if tmpl.NotAfter.After(caCert.NotAfter) {
+		tmpl.NotAfter = caCert.NotAfter
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, csr.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), caPEM, nil
+}
+
+// roleName is how role is written in a certificate.
+func roleName(role comm.Role) string {
+	return strings.ToLower(role.String())
+}
+
+// identity is who sent a request, from their client certificate or
+// guest pass.
+type identity struct {
+	name string
+	role comm.Role
+	// pass is the ID of the guest pass used, if any, and doors are the
+	// doors it covers.
+	pass  string
+	doors []string
+}
+
+// identify returns the identity from the client certificate of the
+// request, if one was sent. The certificate was checked against the CA
+// during the handshake.
+func identify(ctx context.Context) (identity, bool) {
+	name, role, ok := comm.CertRole(ctx)
+	return identity{name: name, role: role}, ok
+}
+
+func (m *mirror) Pair(ctx context.Context, req *comm.PairReq) (*comm.PairResp, error) {
+	if m.enroll == nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "pairing needs the mirror to run with -ca and -ca-key")
+	}
+	if id, err := m.who(ctx); err != nil || id.role != comm.Role_ADMIN {
+		return nil, grpc.Errorf(codes.PermissionDenied, "only an admin may pair a device")
+	}
+	name := strings.TrimSpace(req.Name)
+	if len(name) == 0 || len(name) > 64 || strings.IndexFunc(name, func(r rune) bool { return r < ' ' }) >= 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "name %q must be 1 to 64 printable characters", req.Name)
+	}
+	if req.Role == comm.Role_NO_ROLE {
+		return nil, grpc.Errorf(codes.InvalidArgument, "a role is required")
+	}
+	if _, found := comm.Role_name[int32(req.Role)]; !found {
+		return nil, grpc.Errorf(codes.InvalidArgument, "unknown role %v", req.Role)
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = pairTTL
+	}
+	if ttl > maxPairTTL {
+		return nil, grpc.Errorf(codes.InvalidArgument, "a pairing code may last at most %v", maxPairTTL)
+	}
+
+	expires := time.Now().Add(ttl)
+	code, err := m.enroll.create(pairing{name: name, role: req.Role, expires: expires})
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("pairing code created by %s for %q as %s, expires %s", actor(ctx), name, roleName(req.Role), expires.Format(time.RFC3339))
+	return &comm.PairResp{Code: code, ExpiresUnix: expires.Unix()}, nil
+}
+
+func (m *mirror) Enroll(ctx context.Context, req *comm.EnrollReq) (*comm.EnrollResp, error) {
+	if m.enroll == nil {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "pairing is not enabled on this mirror")
+	}
+	// Check the CSR first so a bad one does not use up the code.
+	csr, err := parseCSR(req.CSR)
+	if err != nil {
+		return nil, err
+	}
+	p, err := m.enroll.find(req.Code, time.Now())
+	if err != nil {
+		logger.Warningf("enroll from %s: %s", actor(ctx), grpc.ErrorDesc(err))
+		time.Sleep(enrollFailDelay)
+		return nil, err
+	}
+	// The code is only used up once the certificate is signed, so a
+	// failure to sign leaves it for another try.
+	certPEM, caPEM, err := m.enroll.sign(csr, p)
+	if err != nil {
+		logger.Errorf("enroll %q: %v", p.name, err)
+		if grpc.Code(err) == codes.Unknown {
+			err = grpc.Errorf(codes.Internal, "failed to sign the certificate")
+		}
+		return nil, err
+	}
+	if !m.enroll.take(req.Code) {
+		// Another device enrolled with the code meanwhile.
+		return nil, grpc.Errorf(codes.PermissionDenied, "unknown or expired pairing code")
+	}
+	msg := fmt.Sprintf("%q enrolled as %s from %s", p.name, roleName(p.role), actor(ctx))
+	logger.Info(msg)
+	m.notice("", msg)
+	return &comm.EnrollResp{Cert: certPEM, CA: caPEM, Name: p.name, Role: p.role}, nil
+}
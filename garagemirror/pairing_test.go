@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// writeCA writes a CA certificate for key to dir and returns its file name.
+func writeCA(t *testing.T, dir string, key crypto.Signer) string {
+	tmpl, err := certTemplate("test CA", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLoadCAFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pairing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8 := func(key crypto.Signer) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := []struct {
+		name   string
+		caKey  crypto.Signer
+		keyPEM []byte
+		ok     bool
+	}{
+		{name: "rsa pkcs1", caKey: rsaKey, keyPEM: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), ok: true},
+		{name: "rsa pkcs8", caKey: rsaKey, keyPEM: pkcs8(rsaKey), ok: true},
+		{name: "ec", caKey: ecKey, keyPEM: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER}), ok: true},
+		{name: "ec pkcs8", caKey: ecKey, keyPEM: pkcs8(ecKey), ok: true},
+		{name: "other key", caKey: ecKey, keyPEM: pkcs8(otherKey)},
+		{name: "not a key", caKey: ecKey, keyPEM: []byte("garbage")},
+		{name: "certificate", caKey: ecKey, keyPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}})},
+	}
+	for _, item := range list {
+		certFile := writeCA(t, dir, item.caKey)
+		keyFile := filepath.Join(dir, "ca.key")
+		err = ioutil.WriteFile(keyFile, item.keyPEM, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err := newEnroller(certFile, keyFile)
+		if (err == nil) != item.ok {
+			t.Errorf("%s: got %v, want ok %t", item.name, err, item.ok)
+		}
+	}
+}
+
+func TestEnrollerSign(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pairing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := writeCA(t, dir, caKey)
+	der, err := x509.MarshalPKCS8PrivateKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "ca.key")
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := newEnroller(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	devKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{}, devKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := parseCSR(csrDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, _, err := e.sign(csr, pairing{name: "tablet", role: comm.Role_MEMBER})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := parseCert(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "tablet" || len(cert.Subject.OrganizationalUnit) != 1 || cert.Subject.OrganizationalUnit[0] != "member" {
+		t.Errorf("signed %v, want member tablet", cert.Subject)
+	}
+}
+
+func TestEnrollerFind(t *testing.T) {
+	e := &enroller{codes: make(map[string]pairing)}
+	now := time.Now()
+	code, err := e.create(pairing{name: "tablet", expires: now.Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Finding a code does not use it up, only taking it does.
+	for i := 0; i < 2; i++ {
+		if p, err := e.find(code, now); err != nil || p.name != "tablet" {
+			t.Fatalf("find %d: got %q, %v", i, p.name, err)
+		}
+	}
+	if !e.take(code) {
+		t.Error("take of a found code failed")
+	}
+	if e.take(code) {
+		t.Error("a code was taken twice")
+	}
+	if _, err := e.find(code, now); grpc.Code(err) != codes.PermissionDenied {
+		t.Errorf("find of a taken code got %v", err)
+	}
+}
+
+func TestEnrollerFailLimit(t *testing.T) {
+	e := &enroller{codes: make(map[string]pairing)}
+	now := time.Now()
+	code, err := e.create(pairing{name: "tablet", expires: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < enrollMaxFails; i++ {
+		if _, err := e.find("AAAA-AAA"+string(pairAlphabet[i]), now); grpc.Code(err) != codes.PermissionDenied {
+			t.Fatalf("guess %d: got %v", i, err)
+		}
+	}
+	// The limit is shared by all devices, and refuses good codes too.
+	if _, err := e.find(code, now); err != nil {
+		t.Fatalf("good code before the limit: %v", err)
+	}
+	if _, err := e.find("AAAA-AAAA", now); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("last guess: got %v", err)
+	}
+	if _, err := e.find(code, now.Add(time.Second)); grpc.Code(err) != codes.Unavailable {
+		t.Errorf("good code past the limit: got %v, want Unavailable", err)
+	}
+	if _, err := e.find(code, now.Add(enrollFailWindow)); err != nil {
+		t.Errorf("good code once the failures are old: %v", err)
+	}
+}
+
+func TestNormalizeCode(t *testing.T) {
+	list := []struct {
+		code, want string
+	}{
+		{"ABCD-EFGH", "ABCDEFGH"},
+		{"abcd-efgh", "ABCDEFGH"},
+		{"abcdefgh", "ABCDEFGH"},
+		{" abcd efgh ", "ABCDEFGH"},
+		{"ab-cd-ef-gh", "ABCDEFGH"},
+		{"", ""},
+	}
+	for _, item := range list {
+		if got := normalizeCode(item.code); got != item.want {
+			t.Errorf("%q: got %q, want %q", item.code, got, item.want)
+		}
+	}
+}
+
+func TestEnrollerExpire(t *testing.T) {
+	now := time.Now()
+	list := []struct {
+		name string
+		at   time.Duration // When the code is found, after now.
+		ok   bool
+	}{
+		{name: "fresh", ok: true},
+		{name: "just before expiry", at: pairTTL - time.Second, ok: true},
+		{name: "at expiry", at: pairTTL},
+		{name: "after expiry", at: pairTTL + time.Hour},
+	}
+	for _, item := range list {
+		e := &enroller{codes: make(map[string]pairing)}
+		code, err := e.create(pairing{name: "tablet", expires: now.Add(pairTTL)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = e.find(strings.ToLower(code), now.Add(item.at))
+		if (err == nil) != item.ok {
+			t.Errorf("%s: got %v, want ok %t", item.name, err, item.ok)
+		}
+		if !item.ok && e.take(code) {
+			t.Errorf("%s: an expired code was taken", item.name)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// passKeep is how long an ended pass is listed before it is forgotten.
+const passKeep = 30 * 24 * time.Hour
+
+// pass is a guest pass. Only a hash of its token is kept.
+type pass struct {
+	ID        string
+	TokenHash string
+	Name      string
+	Doors     []string
+	// Expires is zero if the pass does not expire.
+	Expires time.Time
+	// MaxUses is zero if the pass may be used any number of times.
+	MaxUses int
+	Uses    int
+	Creator string
+	Created time.Time
+	// Ended is when the pass was revoked or used up.
+	Ended time.Time
+	// Revoked is set only by an admin, a pass that is used up is not
+	// revoked.
+	Revoked bool
+}
+
+// usedUp reports if p has no uses left.
+func (p *pass) usedUp() bool {
+	return p.MaxUses != 0 && p.Uses >= p.MaxUses
+}
+
+// active returns an error if p may not be used at now.
+func (p *pass) active(now time.Time) error {
+	switch {
+	case p.Revoked:
+		return fmt.Errorf("pass %q was revoked", p.Name)
+	case p.usedUp():
+		return fmt.Errorf("pass %q is used up", p.Name)
+	case !p.Expires.IsZero() && !now.Before(p.Expires):
+		return fmt.Errorf("pass %q expired at %s", p.Name, p.Expires.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (p *pass) proto() *comm.Pass {
+	cp := &comm.Pass{
+		ID:          p.ID,
+		Name:        p.Name,
+		Doors:       p.Doors,
+		MaxUses:     int32(p.MaxUses),
+		Uses:        int32(p.Uses),
+		Creator:     p.Creator,
+		CreatedUnix: p.Created.Unix(),
+		Revoked:     p.Revoked,
+	}
+	if !p.Expires.IsZero() {
+		cp.ExpiresUnix = p.Expires.Unix()
+	}
+	return cp
+}
+
+// passStore keeps the guest passes, saving them to file if it is set.
+type passStore struct {
+	file string
+
+	mu     sync.Mutex
+	passes map[string]*pass
+}
+
+// loadPasses reads the passes saved in file. An empty file name keeps
+// passes in memory only.
+func loadPasses(file string) (*passStore, error) {
+	s := &passStore{file: file, passes: make(map[string]*pass)}
+	if len(file) == 0 {
+		return s, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []*pass
+	err = json.Unmarshal(b, &list)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for _, p := range list {
+		s.passes[p.ID] = p
+	}
+	return s, nil
+}
+
+// save writes the passes to the file, forgetting those that ended long
+// ago. The store must be locked.
+func (s *passStore) save(now time.Time) error {
+	list := make([]*pass, 0, len(s.passes))
+	for id, p := range s.passes {
+		end := p.Ended
+		if end.IsZero() {
+			end = p.Expires
+		}
+		if !end.IsZero() && now.Sub(end) > passKeep {
+			delete(s.passes, id)
+			continue
+		}
+		list = append(list, p)
+	}
+	if len(s.file) == 0 {
+		return nil
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+	b, err := json.MarshalIndent(list, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(s.file), "."+filepath.Base(s.file)+".tmp")
+	err = ioutil.WriteFile(tmp, b, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// flush saves the passes. It is called after a use, once the mirror is
+// unlocked, and when the mirror stops, so a file is left whole.
+func (s *passStore) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(time.Now())
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// create adds p and returns its token.
+func (s *passStore) create(p *pass) (string, error) {
+	// The ID is listed and logged, so it shares no bytes with the token.
+	b := make([]byte, 24+4)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b[:24])
+	p.ID = hex.EncodeToString(b[24:])
+	p.TokenHash = hashToken(token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.passes[p.ID]; found {
+		return "", fmt.Errorf("pass ID %s is in use, try again", p.ID)
+	}
+	s.passes[p.ID] = p
+	return token, s.save(p.Created)
+}
+
+// find returns a copy of the pass with token.
+func (s *passStore) find(token string) (pass, bool) {
+	hash := []byte(hashToken(token))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.passes {
+		if subtle.ConstantTimeCompare([]byte(p.TokenHash), hash) == 1 {
+			return *p, true
+		}
+	}
+	return pass{}, false
+}
+
+// use counts a use of the pass with id, ending the pass when it is
+// used up. It returns a copy of the pass after the use. The use is not
+// saved until flush.
+func (s *passStore) use(id string, now time.Time) (pass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, found := s.passes[id]
+	if !found {
+		return pass{}, fmt.Errorf("pass %s not found", id)
+	}
+	if err := p.active(now); err != nil {
+		return pass{}, err
+	}
+	p.Uses++
+	if p.usedUp() {
+		p.Ended = now
+	}
+	return *p, nil
+}
+
+// unuse gives back a use of the pass with id, after the command it
+// was counted for was never sent. A revoked pass stays revoked.
+func (s *passStore) unuse(id string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, found := s.passes[id]
+	if !found || p.Uses == 0 {
+		return nil
+	}
+	if p.usedUp() && !p.Revoked {
+		p.Ended = time.Time{}
+	}
+	p.Uses--
+	return s.save(now)
+}
+
+// revoke ends the pass with id.
+func (s *passStore) revoke(id string, now time.Time) (pass, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, found := s.passes[id]
+	if !found {
+		return pass{}, false, nil
+	}
+	if !p.Revoked {
+		p.Revoked = true
+		p.Ended = now
+	}
+	return *p, true, s.save(now)
+}
+
+// list returns copies of the passes, newest first.
+func (s *passStore) list() []pass {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]pass, 0, len(s.passes))
+	for _, p := range s.passes {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list
+}
+
+// passIdentity returns the identity of a request that sent a pass token.
+func (m *mirror) passIdentity(ctx context.Context) (identity, bool, error) {
+	token := comm.PassFromContext(ctx)
+	if len(token) == 0 {
+		return identity{}, false, nil
+	}
+	p, found := m.passes.find(token)
+	if !found {
+		return identity{}, true, grpc.Errorf(codes.Unauthenticated, "unknown pass")
+	}
+	if err := p.active(time.Now()); err != nil {
+		return identity{}, true, grpc.Errorf(codes.PermissionDenied, "%v", err)
+	}
+	return identity{name: "pass " + p.Name, role: comm.Role_GUEST, pass: p.ID, doors: p.Doors}, true, nil
+}
+
+// usePass counts a command by id toward its pass, if it used one. The
+// use is given back with unusePass if the command is never sent to the
+// opener, and saved with savePasses.
+func (m *mirror) usePass(id identity) (pass, error) {
+	if len(id.pass) == 0 {
+		return pass{}, nil
+	}
+	p, err := m.passes.use(id.pass, time.Now())
+	if err != nil {
+		return pass{}, grpc.Errorf(codes.PermissionDenied, "%v", err)
+	}
+	return p, nil
+}
+
+// savePasses saves the use counted by usePass. The mirror must not be
+// locked.
+func (m *mirror) savePasses() {
+	err := m.passes.flush()
+	if err != nil {
+		logger.Error("failed to save guest passes: ", err)
+	}
+}
+
+// passUsed tells the creator of p that it was used to command door, in ev.
+func (m *mirror) passUsed(p pass, ev *comm.Event) {
+	msg := fmt.Sprintf("pass %q was used to %v %q", p.Name, ev.Action, ev.Door)
+	if !ev.OK {
+		msg += fmt.Sprintf(" and failed (%s)", ev.Message)
+	}
+	switch {
+	case p.MaxUses == 0:
+	case p.usedUp():
+		msg += fmt.Sprintf(", it is used up after %d uses", p.Uses)
+	default:
+		msg += fmt.Sprintf(", %d of %d uses left", p.MaxUses-p.Uses, p.MaxUses)
+	}
+	logger.Info(msg)
+	m.events.add(&comm.Event{TimeUnix: time.Now().Unix(), Kind: comm.EventKind_NOTICE, Door: ev.Door, Message: msg, To: p.Creator})
+}
+
+// unusePass gives back the use counted by usePass when the command was
+// never sent.
+func (m *mirror) unusePass(id identity) {
+	if len(id.pass) == 0 {
+		return
+	}
+	err := m.passes.unuse(id.pass, time.Now())
+	if err != nil {
+		logger.Error("pass ", err)
+	}
+}
+
+func (m *mirror) CreatePass(ctx context.Context, req *comm.CreatePassReq) (*comm.Pass, error) {
+	creator, err := m.who(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if len(name) == 0 || len(name) > 64 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "name %q must be 1 to 64 characters", req.Name)
+	}
+	now := time.Now()
+	if req.ExpiresUnix == 0 && req.MaxUses == 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "a pass needs an expiry time, a use limit, or both")
+	}
+	if req.ExpiresUnix != 0 && req.ExpiresUnix <= now.Unix() {
+		return nil, grpc.Errorf(codes.InvalidArgument, "expiry time %s has passed", time.Unix(req.ExpiresUnix, 0).Format(time.RFC3339))
+	}
+	if req.MaxUses < 0 {
+		return nil, grpc.Errorf(codes.InvalidArgument, "use limit %d is negative", req.MaxUses)
+	}
+	m.RLock()
+	for _, door := range req.Doors {
+		if _, found := m.doors[door]; !found {
+			m.RUnlock()
+			return nil, grpc.Errorf(codes.NotFound, "unknown door %q", door)
+		}
+	}
+	m.RUnlock()
+
+	p := &pass{
+		Name:    name,
+		Doors:   req.Doors,
+		MaxUses: int(req.MaxUses),
+		Creator: creator.name,
+		Created: now,
+	}
+	if req.ExpiresUnix != 0 {
+		p.Expires = time.Unix(req.ExpiresUnix, 0)
+	}
+	token, err := m.passes.create(p)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("pass %q %s created by %s", p.Name, p.ID, actor(ctx))
+	cp := p.proto()
+	cp.Token = token
+	return cp, nil
+}
+
+func (m *mirror) ListPasses(ctx context.Context, _ *comm.ListPassesReq) (*comm.ListPassesResp, error) {
+	list := m.passes.list()
+	resp := &comm.ListPassesResp{Passes: make([]*comm.Pass, len(list))}
+	for i := range list {
+		resp.Passes[i] = list[i].proto()
+	}
+	return resp, nil
+}
+
+func (m *mirror) RevokePass(ctx context.Context, req *comm.RevokePassReq) (*comm.Pass, error) {
+	p, found, err := m.passes.revoke(req.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, grpc.Errorf(codes.NotFound, "unknown pass %q", req.ID)
+	}
+	logger.Infof("pass %q %s revoked by %s", p.Name, p.ID, actor(ctx))
+	return p.proto(), nil
+}
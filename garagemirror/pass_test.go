@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPassActive(t *testing.T) {
+	now := time.Date(2017, 1, 2, 12, 0, 0, 0, time.UTC)
+	list := []struct {
+		name string
+		p    pass
+		// err is part of the error, empty if the pass is active.
+		err string
+	}{
+		{name: "unlimited", p: pass{Name: "p"}},
+		{name: "uses left", p: pass{Name: "p", MaxUses: 2, Uses: 1}},
+		{name: "not yet expired", p: pass{Name: "p", Expires: now.Add(time.Second)}},
+		{name: "expired", p: pass{Name: "p", Expires: now}, err: "expired"},
+		{name: "used up", p: pass{Name: "p", MaxUses: 2, Uses: 2}, err: "used up"},
+		{name: "revoked", p: pass{Name: "p", Revoked: true}, err: "revoked"},
+		{name: "used up and expired", p: pass{Name: "p", MaxUses: 1, Uses: 1, Expires: now.Add(-time.Hour)}, err: "used up"},
+		{name: "revoked and used up", p: pass{Name: "p", MaxUses: 1, Uses: 1, Revoked: true}, err: "revoked"},
+		{name: "revoked and expired", p: pass{Name: "p", Expires: now.Add(-time.Hour), Revoked: true}, err: "revoked"},
+	}
+	for _, item := range list {
+		err := item.p.active(now)
+		switch {
+		case len(item.err) == 0 && err != nil:
+			t.Errorf("%s: got %v, want active", item.name, err)
+		case len(item.err) != 0 && (err == nil || !strings.Contains(err.Error(), item.err)):
+			t.Errorf("%s: got %v, want %q", item.name, err, item.err)
+		}
+	}
+}
+
+func TestPassUseUnuse(t *testing.T) {
+	now := time.Date(2017, 1, 2, 12, 0, 0, 0, time.UTC)
+	// Each step is "use", "unuse" or "revoke".
+	list := []struct {
+		name    string
+		maxUses int
+		steps   []string
+		// fail is the index of the one step that fails, or -1.
+		fail    int
+		uses    int
+		ended   bool
+		revoked bool
+	}{
+		{name: "use", maxUses: 2, steps: []string{"use"}, fail: -1, uses: 1},
+		{name: "use up", maxUses: 2, steps: []string{"use", "use"}, fail: -1, uses: 2, ended: true},
+		{name: "use after used up", maxUses: 1, steps: []string{"use", "use"}, fail: 1, uses: 1, ended: true},
+		{name: "unuse reopens", maxUses: 1, steps: []string{"use", "unuse"}, fail: -1},
+		{name: "unuse then use", maxUses: 1, steps: []string{"use", "unuse", "use"}, fail: -1, uses: 1, ended: true},
+		{name: "unuse unused", maxUses: 1, steps: []string{"unuse"}, fail: -1},
+		{name: "unlimited never ends", steps: []string{"use", "use", "use"}, fail: -1, uses: 3},
+		{name: "use after revoke", maxUses: 3, steps: []string{"revoke", "use"}, fail: 1, ended: true, revoked: true},
+		{name: "unuse keeps revoked", maxUses: 1, steps: []string{"use", "revoke", "unuse"}, fail: -1, ended: true, revoked: true},
+		{name: "unuse keeps revoked with uses left", maxUses: 3, steps: []string{"use", "revoke", "unuse"}, fail: -1, ended: true, revoked: true},
+	}
+	for _, item := range list {
+		s, err := loadPasses("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := &pass{Name: item.name, MaxUses: item.maxUses, Created: now}
+		if _, err = s.create(p); err != nil {
+			t.Fatalf("%s: %v", item.name, err)
+		}
+		for i, step := range item.steps {
+			switch step {
+			case "use":
+				_, err = s.use(p.ID, now)
+			case "unuse":
+				err = s.unuse(p.ID, now)
+			case "revoke":
+				_, _, err = s.revoke(p.ID, now)
+			}
+			if (err != nil) != (i == item.fail) {
+				t.Errorf("%s: step %d %s: got %v, want fail %t", item.name, i, step, err, i == item.fail)
+			}
+		}
+		got := s.list()[0]
+		if got.Uses != item.uses || got.Ended.IsZero() == item.ended || got.Revoked != item.revoked {
+			t.Errorf("%s: got uses %d ended %t revoked %t, want %d %t %t",
+				item.name, got.Uses, !got.Ended.IsZero(), got.Revoked, item.uses, item.ended, item.revoked)
+		}
+	}
+}
+
+func TestPassStoreFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "passes.json")
+	now := time.Date(2017, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	s, err := loadPasses(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := &pass{Name: "keep", MaxUses: 3, Created: now}
+	token, err := s.create(keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := &pass{Name: "old", Created: now.Add(-2 * passKeep), Expires: now.Add(-passKeep - time.Hour)}
+	if _, err = s.create(old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.use(keep.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = loadPasses(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := s.list()
+	if len(list) != 1 || list[0].ID != keep.ID || list[0].Uses != 1 {
+		t.Fatalf("got %+v, want only %q with one use", list, keep.Name)
+	}
+	if p, found := s.find(token); !found || p.ID != keep.ID {
+		t.Errorf("find: got %q %t, want %q", p.Name, found, keep.Name)
+	}
+	if _, found := s.find(token + "x"); found {
+		t.Errorf("find: found a pass for a wrong token")
+	}
+}
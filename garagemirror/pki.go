@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+)
+
+// The pki command replaces the steps in create_certs.txt:
+//
+//	garagemirror pki ca -dir pki
+//	garagemirror pki server -dir pki -host garage.example.com
+//	garagemirror pki client -dir pki -name kitchen-tablet -role member
+//	garagemirror pki gen -dir pki -host garage.example.com -port 8443 -o comm/key.go
+//	garagemirror pki list -dir pki
+//
+// Keys are written next to their certificates with a ".key" extension
+// and are only readable by the owner.
+
+func pkiUsage() {
+	fmt.Fprintf(os.Stderr, `usage: garagemirror pki <command> [flags]
+
+commands:
+  ca      create a certificate authority
+  server  issue the mirror certificate, signed by the CA
+  client  issue a device certificate, signed by the CA
+  gen     write the Go source embedded by package comm
+  list    list certificates and when they expire
+
+Run "garagemirror pki <command> -h" for the flags of a command.
+`)
+}
+
+// runPKI runs the pki command with args and returns the exit code.
+func runPKI(args []string) int {
+	if len(args) == 0 {
+		pkiUsage()
+		return 2
+	}
+	cmds := map[string]func([]string) error{
+		"ca":     pkiCA,
+		"server": pkiServer,
+		"client": pkiClient,
+		"gen":    pkiGen,
+		"list":   pkiList,
+	}
+	run, found := cmds[args[0]]
+	if !found {
+		pkiUsage()
+		return 2
+	}
+	err := run(args[1:])
+	if err == flag.ErrHelp {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "garagemirror pki %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// pkiFlags returns a flag set for the named pki command with the
+// flags every command shares.
+func pkiFlags(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet("garagemirror pki "+name, flag.ContinueOnError)
+	dir := fs.String("dir", "pki", "directory holding the CA and issued certificates")
+	return fs, dir
+}
+
+func pkiCA(args []string) error {
+	fs, dir := pkiFlags("ca")
+	cn := fs.String("cn", "Garage CA", "common name of the CA")
+	days := fs.Int("days", 3650, "days the CA is valid")
+	bits := fs.Int("bits", 4096, "RSA key size")
+	force := fs.Bool("force", false, "replace an existing CA, which invalidates every issued certificate")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	certFile := filepath.Join(*dir, "ca.pem")
+	if _, err := os.Stat(certFile); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to replace it", certFile)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, *bits)
+	if err != nil {
+		return err
+	}
+	tmpl, err := certTemplate(*cn, *days)
+	if err != nil {
+		return err
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.MaxPathLenZero = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	return writePair(*dir, "ca", der, key)
+}
+
+func pkiServer(args []string) error {
+	fs, dir := pkiFlags("server")
+	hosts := fs.String("host", "", "comma separated host names and IP addresses the mirror is reached at")
+	name := fs.String("name", "server", "base name of the certificate and key files")
+	days := fs.Int("days", 825, "days the certificate is valid")
+	bits := fs.Int("bits", 2048, "RSA key size")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*hosts) == 0 {
+		return errors.New("-host is required")
+	}
+	list := strings.Split(*hosts, ",")
+	tmpl, err := certTemplate(strings.TrimSpace(list[0]), *days)
+	if err != nil {
+		return err
+	}
+	for _, h := range list {
+		h = strings.TrimSpace(h)
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if len(h) != 0 {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	return issue(*dir, *name, tmpl, *bits)
+}
+
+func pkiClient(args []string) error {
+	fs, dir := pkiFlags("client")
+	name := fs.String("name", "", "device name, used as the common name and file name")
+	roleFlag := fs.String("role", "", "role of the device: admin, member, guest or opener")
+	days := fs.Int("days", 825, "days the certificate is valid")
+	bits := fs.Int("bits", 2048, "RSA key size")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*name) == 0 {
+		return errors.New("-name is required")
+	}
+	// The role is kept in the certificate as pairing does, a device
+	// without one is refused by the mirror.
+	role := comm.Role(comm.Role_value[strings.ToUpper(*roleFlag)])
+	if role == comm.Role_NO_ROLE {
+		return fmt.Errorf("-role must be admin, member, guest or opener, got %q", *roleFlag)
+	}
+	if strings.ContainsAny(*name, `/\`) || *name == "ca" || *name == "server" {
+		return fmt.Errorf("%q may not be used as a device name", *name)
+	}
+	tmpl, err := certTemplate(*name, *days)
+	if err != nil {
+		return err
+	}
+	tmpl.Subject.OrganizationalUnit = []string{roleName(role)}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	return issue(*dir, *name, tmpl, *bits)
+}
+
+func pkiGen(args []string) error {
+	fs, dir := pkiFlags("gen")
+	host := fs.String("host", "", "host name clients dial")
+	port := fs.Int("port", 0, "port clients dial")
+	name := fs.String("name", "server", "base name of the server certificate and key files")
+	authKey := fs.String("authkey", "", "auth key compiled in, a random key if empty")
+	tag := fs.String("tag", "", "build tag the file requires, if any")
+	out := fs.String("o", "", "file to write, standard output if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*host) == 0 || *port <= 0 {
+		return errors.New("-host and -port are required")
+	}
+	caPEM, err := ioutil.ReadFile(filepath.Join(*dir, "ca.pem"))
+	if err != nil {
+		return err
+	}
+	certPEM, err := ioutil.ReadFile(filepath.Join(*dir, *name+".pem"))
+	if err != nil {
+		return err
+	}
+	keyPEM, err := ioutil.ReadFile(filepath.Join(*dir, *name+".key"))
+	if err != nil {
+		return err
+	}
+	cert, err := parseCert(certPEM)
+	if err != nil {
+		return err
+	}
+	if err := cert.VerifyHostname(*host); err != nil {
+		return err
+	}
+	if len(*authKey) == 0 {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		*authKey = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by \"garagemirror pki gen\". DO NOT EDIT.\n\n")
+	if len(*tag) != 0 {
+		fmt.Fprintf(buf, "//go:build %s\n\n", *tag)
+	}
+	fmt.Fprintf(buf, "package comm\n\n")
+	fmt.Fprintf(buf, "var port = %d\n\n", *port)
+	fmt.Fprintf(buf, "var host = %q\n\n", *host)
+	fmt.Fprintf(buf, "var authKey = %q\n\n", *authKey)
+	fmt.Fprintf(buf, "var ca = `%s`\n\n", caPEM)
+	fmt.Fprintf(buf, "var key = `%s`\n\n", keyPEM)
+	fmt.Fprintf(buf, "var cert = `%s`\n", certPEM)
+
+	if len(*out) == 0 {
+		_, err = buf.WriteTo(os.Stdout)
+		return err
+	}
+	return ioutil.WriteFile(*out, buf.Bytes(), 0600)
+}
+
+func pkiList(args []string) error {
+	fs, dir := pkiFlags("list")
+	warn := fs.Duration("warn", 30*24*time.Hour, "mark certificates that expire within this time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	type entry struct {
+		source string
+		cert   *x509.Certificate
+	}
+	var list []entry
+	for _, c := range []struct {
+		source string
+		pem    []byte
+	}{
+		{"compiled in CA", comm.CA()},
+		{"compiled in server", comm.Cert()},
+	} {
+		cert, err := parseCert(c.pem)
+		if err == nil {
+			list = append(list, entry{c.source, cert})
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(*dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		cert, err := parseCert(b)
+		if err != nil {
+			return fmt.Errorf("%s: %v", f, err)
+		}
+		list = append(list, entry{f, cert})
+	}
+
+	now := time.Now()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tSUBJECT\tUSE\tNAMES\tEXPIRES\t")
+	for _, e := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", e.source, e.cert.Subject.CommonName, certUse(e.cert), certNames(e.cert), expiry(e.cert, now, *warn))
+	}
+	return tw.Flush()
+}
+
+// certTemplate returns a template for a certificate valid for days from now.
+func certTemplate(cn string, days int) (*x509.Certificate, error) {
+	if days <= 0 {
+		return nil, fmt.Errorf("days must be positive, got %d", days)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		// Allow for clocks that are a little behind.
+		NotBefore: now.Add(-time.Hour),
+		NotAfter:  now.AddDate(0, 0, days),
+	}, nil
+}
+
+// issue creates a key and a certificate from tmpl signed by the CA in dir.
+func issue(dir, name string, tmpl *x509.Certificate, bits int) error {
+	caCert, caKey, err := loadCA(dir)
+	if err != nil {
+		return err
+	}
+	if tmpl.NotAfter.After(caCert.NotAfter) {
+		return fmt.Errorf("certificate would outlive the CA, which expires %s", caCert.NotAfter.Format("2006-01-02"))
+	}
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	return writePair(dir, name, der, key)
+}
+
+// loadCA reads the CA certificate and key from dir.
+func loadCA(dir string) (*x509.Certificate, crypto.Signer, error) {
+	return loadCAFiles(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca.key"))
+}
+
+// loadCAFiles reads the CA certificate and key, and checks they belong
+// together. The key may be PKCS #8, as OpenSSL 3 writes, or an RSA or EC
+// key in its own format.
+func loadCAFiles(certFile, keyFile string) (*x509.Certificate, crypto.Signer, error) {
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%v, create a CA with \"garagemirror pki ca\"", err)
+	}
+	cert, err := parseCert(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := parseKey(keyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", keyFile, err)
+	}
+	certPub, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", certFile, err)
+	}
+	keyPub, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", keyFile, err)
+	}
+	if !bytes.Equal(certPub, keyPub) {
+		return nil, nil, fmt.Errorf("%s is not the key of %s", keyFile, certFile)
+	}
+	return cert, key, nil
+}
+
+// parseKey parses the first private key in a PEM block.
+func parseKey(b []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%T keys can not sign certificates", key)
+		}
+		return signer, nil
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("%q is not a private key", block.Type)
+}
+
+// writePair writes name.pem and name.key to dir.
+func writePair(dir, name string, der []byte, key *rsa.PrivateKey) error {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+".key")
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s and %s\n", certFile, keyFile)
+	return nil
+}
+
+// parseCert parses the first certificate in a PEM block.
+func parseCert(b []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return nil, errors.New("no certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+func certUse(c *x509.Certificate) string {
+	if c.IsCA {
+		return "ca"
+	}
+	var use []string
+	for _, u := range c.ExtKeyUsage {
+		switch u {
+		case x509.ExtKeyUsageServerAuth:
+			use = append(use, "server")
+		case x509.ExtKeyUsageClientAuth:
+			use = append(use, "client")
+		}
+	}
+	if len(use) == 0 {
+		return "-"
+	}
+	return strings.Join(use, ",")
+}
+
+func certNames(c *x509.Certificate) string {
+	names := append([]string{}, c.DNSNames...)
+	for _, ip := range c.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ",")
+}
+
+// expiry describes when c expires, flagging it if that is within warn.
+func expiry(c *x509.Certificate, now time.Time, warn time.Duration) string {
+	date := c.NotAfter.Format("2006-01-02")
+	left := c.NotAfter.Sub(now)
+	switch {
+	case left <= 0:
+		return date + " EXPIRED"
+	case left < warn:
+		return fmt.Sprintf("%s in %d days, RENEW", date, int(left.Hours()/24))
+	}
+	return fmt.Sprintf("%s in %d days", date, int(left.Hours()/24))
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Roles come from the client certificate of a request. A request with the
+// compiled in auth key and no certificate acts as an admin while there is
+// no policy file, so existing clients and the first admin keep working.
+// The auth key ships in every build of the clients and openers, so once a
+// policy file is set such requests are refused, unless the policy gives
+// them a role with KeyRole. They are then limited as the user "auth key".
+//
+//	admin   everything, including pairing devices
+//	member  every door at any time, unless limited by the policy
+//	guest   only the doors and times the policy allows
+//	opener  only the Garage stream of a door opener
+//
+// The policy is read from the -policy file, and again on SIGHUP:
+//
+//	{
+//		"TimeZone": "America/Los_Angeles",
+//		"KeyRole": "guest",
+//		"Users": {
+//			"auth key": {
+//				"Doors": ["north"],
+//				"Windows": [{"Days": ["sat", "sun"], "From": "09:00", "To": "18:00"}]
+//			},
+//			"dog-walker": {
+//				"Doors": ["north"],
+//				"Windows": [{"Days": ["mon-fri"], "From": "11:00", "To": "14:00"}]
+//			}
+//		}
+//	}
+//
+// A window whose To is not after From ends the next day.
+
+// policy limits members and guests by name.
+type policy struct {
+	// TimeZone is the IANA time zone of the windows. The local time zone
+	// is used when it is empty.
+	TimeZone string
+	// KeyRole is the role of a request with the auth key and no client
+	// certificate: "admin", "member", "guest" or "opener". Such requests
+	// are refused when it is empty.
+	KeyRole string
+	Users   map[string]*limits
+
+	loc     *time.Location
+	keyRole comm.Role
+}
+
+// keyUser is the name a request with only the auth key is limited as.
+const keyUser = "auth key"
+
+// limits are the doors and times a user may use. A user with no doors
+// listed may use every door, and one with no windows may use them at any time.
+type limits struct {
+	Doors   []string
+	Windows []*window
+}
+
+// window is a weekly time window.
+type window struct {
+	// Days lists the days the window starts on, as "mon" to "sun",
+	// or as a range such as "mon-fri".
+	Days []string
+	// From and To are the start and end times, as "15:04".
+	From, To string
+
+	days     [7]bool
+	from, to int // Minutes from midnight.
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// loadPolicy reads the policy file. An empty name returns an empty policy.
+func loadPolicy(name string) (*policy, error) {
+	p := &policy{loc: time.Local, keyRole: comm.Role_ADMIN}
+	if len(name) == 0 {
+		return p, nil
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(p.TimeZone) != 0 {
+		p.loc, err = time.LoadLocation(p.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("%s: TimeZone: %v", name, err)
+		}
+	}
+	p.keyRole = comm.Role(comm.Role_value[strings.ToUpper(p.KeyRole)])
+	if len(p.KeyRole) != 0 && p.keyRole == comm.Role_NO_ROLE {
+		return nil, fmt.Errorf("%s: KeyRole: %q is not admin, member, guest or opener", name, p.KeyRole)
+	}
+	for user, l := range p.Users {
+		if l == nil {
+			return nil, fmt.Errorf("%s: Users[%q]: no limits", name, user)
+		}
+		for i, w := range l.Windows {
+			err = w.parse()
+			if err != nil {
+				return nil, fmt.Errorf("%s: Users[%q].Windows[%d]: %v", name, user, i, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (w *window) parse() error {
+	if len(w.Days) == 0 {
+		return fmt.Errorf("Days: no days listed")
+	}
+	for _, d := range w.Days {
+		parts := strings.SplitN(strings.ToLower(d), "-", 2)
+		first, found := dayNames[parts[0]]
+		if !found {
+			return fmt.Errorf("Days: unknown day %q", parts[0])
+		}
+		last := first
+		if len(parts) == 2 {
+			last, found = dayNames[parts[1]]
+			if !found {
+				return fmt.Errorf("Days: unknown day %q", parts[1])
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			w.days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	var err error
+	w.from, err = parseClock(w.From)
+	if err != nil {
+		return fmt.Errorf("From: %v", err)
+	}
+	w.to, err = parseClock(w.To)
+	if err != nil {
+		return fmt.Errorf("To: %v", err)
+	}
+	return nil
+}
+
+// parseClock returns the minutes from midnight of a "15:04" time.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time such as 15:04", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether t, in the policy time zone, is in the window.
+func (w *window) contains(t time.Time) bool {
+	day, min := t.Weekday(), t.Hour()*60+t.Minute()
+	if w.from < w.to {
+		return w.days[day] && min >= w.from && min < w.to
+	}
+	// The window ends the next day.
+	return (w.days[day] && min >= w.from) || (w.days[(day+6)%7] && min < w.to)
+}
+
+func (w *window) String() string {
+	return fmt.Sprintf("%s %s-%s", strings.Join(w.Days, ","), w.From, w.To)
+}
+
+// check returns a PermissionDenied error unless id may use door at now.
+// An empty door checks only the time.
+func (p *policy) check(id identity, door string, now time.Time) error {
+	switch id.role {
+	case comm.Role_ADMIN:
+		return nil
+	case comm.Role_MEMBER, comm.Role_GUEST:
+	default:
+		return grpc.Errorf(codes.PermissionDenied, "%s %q may not use doors", roleName(id.role), id.name)
+	}
+	l := p.Users[id.name]
+	if len(id.pass) != 0 {
+		// A guest pass is limited by its own doors and expiry.
+		l = &limits{Doors: id.doors}
+	}
+	if l == nil {
+		if id.role == comm.Role_GUEST {
+			return grpc.Errorf(codes.PermissionDenied, "guest %q has no access granted", id.name)
+		}
+		return nil
+	}
+	if len(door) != 0 && !l.door(door) {
+		return grpc.Errorf(codes.PermissionDenied, "%s %q may only use %s", roleName(id.role), id.name, quoteList(l.Doors))
+	}
+	if len(l.Windows) == 0 {
+		return nil
+	}
+	local := now.In(p.loc)
+	for _, w := range l.Windows {
+		if w.contains(local) {
+			return nil
+		}
+	}
+	allowed := make([]string, len(l.Windows))
+	for i, w := range l.Windows {
+		allowed[i] = w.String()
+	}
+	return grpc.Errorf(codes.PermissionDenied, "%s %q may only use doors %s, it is now %s", roleName(id.role), id.name, strings.Join(allowed, " or "), local.Format("Mon 15:04 MST"))
+}
+
+// door reports whether door is one of the allowed doors.
+func (l *limits) door(door string) bool {
+	if len(l.Doors) == 0 {
+		return true
+	}
+	for _, d := range l.Doors {
+		if d == door {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteList(list []string) string {
+	q := make([]string, len(list))
+	for i, s := range list {
+		q[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(q, ", ")
+}
+
+// policyStore holds the policy, which may be replaced on SIGHUP.
+type policyStore struct {
+	file string
+
+	mu sync.RWMutex
+	p  *policy
+}
+
+func (s *policyStore) get() *policy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.p
+}
+
+// reload reads the policy file again, keeping the current policy on error.
+func (s *policyStore) reload() {
+	if len(s.file) == 0 {
+		return
+	}
+	p, err := loadPolicy(s.file)
+	if err != nil {
+		logger.Errorf("policy not reloaded, keeping the current one: %v", err)
+		return
+	}
+	s.mu.Lock()
+	s.p = p
+	s.mu.Unlock()
+	logger.Infof("policy reloaded with limits for %d users", len(p.Users))
+}
+
+// run reloads the policy on SIGHUP until ctx is done.
+func (s *policyStore) run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			s.reload()
+		}
+	}
+}
+
+// who returns the identity of the sender of a request. A guest pass is
+// checked first, so a device may use a pass without its own certificate
+// taking over.
+func (m *mirror) who(ctx context.Context) (identity, error) {
+	if id, ok, err := m.passIdentity(ctx); ok {
+		return id, err
+	}
+	if id, ok := identify(ctx); ok {
+		return id, nil
+	}
+	if comm.CheckKey(ctx, comm.AuthKey()) == nil {
+		role := m.policy.get().keyRole
+		if role == comm.Role_NO_ROLE {
+			return identity{}, grpc.Errorf(codes.PermissionDenied, "the policy does not accept the auth key alone, send a client certificate")
+		}
+		return identity{name: keyUser, role: role}, nil
+	}
+	return identity{}, grpc.Errorf(codes.Unauthenticated, "send a client certificate, a guest pass or the auth key")
+}
+
+// authorize checks the role of the sender may call method. Door and time
+// limits are checked by each method.
+func (m *mirror) authorize(ctx context.Context, method string) error {
+	method = method[strings.LastIndex(method, "/")+1:]
+	if method == "Enroll" {
+		return nil
+	}
+	id, err := m.who(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case id.role == comm.Role_ADMIN:
+		return nil
+	case method == "Garage":
+		if id.role != comm.Role_OPENER {
+			return grpc.Errorf(codes.PermissionDenied, "%s %q is not a door opener", roleName(id.role), id.name)
+		}
+		return nil
+	case method == "Pair":
+		return grpc.Errorf(codes.PermissionDenied, "only an admin may pair a device")
+	case method == "CreatePass", method == "ListPasses", method == "RevokePass":
+		return grpc.Errorf(codes.PermissionDenied, "only an admin may manage guest passes")
+	case id.role == comm.Role_MEMBER, id.role == comm.Role_GUEST:
+		return nil
+	}
+	return grpc.Errorf(codes.PermissionDenied, "%s %q may not call %s", roleName(id.role), id.name, method)
+}
+
+// authInterceptors return server options that authorize every request.
+func (m *mirror) authInterceptors() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := m.authorize(ctx, info.FullMethod); err != nil {
+				logger.Warningf("%s %s: %v", actor(ctx), info.FullMethod, grpc.ErrorDesc(err))
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := m.authorize(ss.Context(), info.FullMethod); err != nil {
+				logger.Warningf("%s %s: %v", actor(ss.Context()), info.FullMethod, grpc.ErrorDesc(err))
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// allow checks the sender of a request may use door now.
+// An empty door checks only the time.
+func (m *mirror) allow(ctx context.Context, door string) error {
+	id, err := m.who(ctx)
+	if err != nil {
+		return err
+	}
+	return m.policy.get().check(id, door, time.Now())
+}
+
+// visible returns whether the sender of a request may see door, or an
+// error if they may not use any door now. Events for no door are visible
+// to all.
+func (m *mirror) visible(ctx context.Context) (func(door string) bool, error) {
+	err := m.allow(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return func(door string) bool {
+		return len(door) == 0 || m.allow(ctx, door) == nil
+	}, nil
+}
+
+// seen returns whether the sender of a request may see ev, or an error
+// if they may not use any door now. Events sent to someone else are
+// not seen.
+func (m *mirror) seen(ctx context.Context) (func(ev *comm.Event) bool, error) {
+	id, err := m.who(ctx)
+	if err != nil {
+		return nil, err
+	}
+	visible, err := m.visible(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func(ev *comm.Event) bool {
+		return (len(ev.To) == 0 || ev.To == id.name) && visible(ev.Door)
+	}, nil
+}
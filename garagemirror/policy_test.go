@@ -0,0 +1,183 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// testPolicy loads a policy file with content.
+func testPolicy(t *testing.T, content string) *policy {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "policy.json")
+	err = ioutil.WriteFile(name, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadPolicy(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestKeyOnlyCaller(t *testing.T) {
+	ctx := comm.NewContext(context.Background(), comm.AuthKey(), "")
+	// Mon 2017-01-02 and Sat 2017-01-07, in UTC as the policies are.
+	weekday := time.Date(2017, 1, 2, 12, 0, 0, 0, time.UTC)
+	weekend := time.Date(2017, 1, 7, 12, 0, 0, 0, time.UTC)
+
+	noFile, err := loadPolicy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := []struct {
+		name string
+		p    *policy
+		// role is the role the caller gets, NO_ROLE if refused.
+		role comm.Role
+		// weekday and weekend report whether north may be opened then.
+		weekday, weekend bool
+	}{
+		{name: "no policy file", p: noFile, role: comm.Role_ADMIN, weekday: true, weekend: true},
+		{
+			name: "policy without KeyRole",
+			p:    testPolicy(t, `{"TimeZone": "UTC"}`),
+			role: comm.Role_NO_ROLE,
+		},
+		{
+			name: "guest key without access",
+			p:    testPolicy(t, `{"TimeZone": "UTC", "KeyRole": "guest"}`),
+			role: comm.Role_GUEST,
+		},
+		{
+			name: "guest key in a window",
+			p: testPolicy(t, `{"TimeZone": "UTC", "KeyRole": "guest", "Users": {"auth key": {
+				"Doors": ["north"], "Windows": [{"Days": ["sat", "sun"], "From": "09:00", "To": "18:00"}]}}}`),
+			role:    comm.Role_GUEST,
+			weekend: true,
+		},
+		{
+			name: "member key in a window",
+			p: testPolicy(t, `{"TimeZone": "UTC", "KeyRole": "member", "Users": {"auth key": {
+				"Windows": [{"Days": ["mon-fri"], "From": "08:00", "To": "17:00"}]}}}`),
+			role:    comm.Role_MEMBER,
+			weekday: true,
+		},
+	}
+	for _, item := range list {
+		m := &mirror{policy: &policyStore{p: item.p}}
+		id, err := m.who(ctx)
+		if item.role == comm.Role_NO_ROLE {
+			if grpc.Code(err) != codes.PermissionDenied {
+				t.Errorf("%s: got %v %v, want refused", item.name, id.role, err)
+			}
+			continue
+		}
+		if err != nil || id.role != item.role {
+			t.Errorf("%s: got %v %v, want %v", item.name, id.role, err, item.role)
+			continue
+		}
+		for _, at := range []struct {
+			when time.Time
+			want bool
+		}{{weekday, item.weekday}, {weekend, item.weekend}} {
+			err := item.p.check(id, "north", at.when)
+			if (err == nil) != at.want {
+				t.Errorf("%s: north at %s got %v, want allowed %t", item.name, at.when.Format("Mon 15:04"), err, at.want)
+			}
+		}
+	}
+}
+
+func TestLoadPolicyKeyRole(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "policy.json")
+	err = ioutil.WriteFile(name, []byte(`{"KeyRole": "owner"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPolicy(name); err == nil {
+		t.Error("an unknown KeyRole was accepted")
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	// 2017-01-02 is a Monday.
+	at := func(day int, clock string) time.Time {
+		c, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Date(2017, 1, day, c.Hour(), c.Minute(), 0, 0, time.UTC)
+	}
+	list := []struct {
+		name string
+		w    window
+		at   time.Time
+		want bool
+	}{
+		{name: "weekday inside", w: window{Days: []string{"mon-fri"}, From: "08:00", To: "17:00"}, at: at(2, "12:00"), want: true},
+		{name: "weekday at start", w: window{Days: []string{"mon-fri"}, From: "08:00", To: "17:00"}, at: at(2, "08:00"), want: true},
+		{name: "weekday at end", w: window{Days: []string{"mon-fri"}, From: "08:00", To: "17:00"}, at: at(2, "17:00")},
+		{name: "weekday before", w: window{Days: []string{"mon-fri"}, From: "08:00", To: "17:00"}, at: at(2, "07:59")},
+		{name: "weekday on saturday", w: window{Days: []string{"mon-fri"}, From: "08:00", To: "17:00"}, at: at(7, "12:00")},
+		{name: "range over the week end", w: window{Days: []string{"fri-mon"}, From: "08:00", To: "17:00"}, at: at(8, "12:00"), want: true},
+		{name: "range over the week end, midweek", w: window{Days: []string{"fri-mon"}, From: "08:00", To: "17:00"}, at: at(4, "12:00")},
+		{name: "overnight evening", w: window{Days: []string{"fri"}, From: "22:00", To: "06:00"}, at: at(6, "23:30"), want: true},
+		{name: "overnight next morning", w: window{Days: []string{"fri"}, From: "22:00", To: "06:00"}, at: at(7, "05:59"), want: true},
+		{name: "overnight next morning at end", w: window{Days: []string{"fri"}, From: "22:00", To: "06:00"}, at: at(7, "06:00")},
+		{name: "overnight morning of the start day", w: window{Days: []string{"fri"}, From: "22:00", To: "06:00"}, at: at(6, "05:00")},
+		{name: "overnight afternoon", w: window{Days: []string{"fri"}, From: "22:00", To: "06:00"}, at: at(6, "12:00")},
+		{name: "overnight from saturday into sunday", w: window{Days: []string{"sat"}, From: "20:00", To: "02:00"}, at: at(8, "01:00"), want: true},
+		{name: "overnight from sunday into monday", w: window{Days: []string{"sun"}, From: "20:00", To: "02:00"}, at: at(9, "01:00"), want: true},
+	}
+	for _, item := range list {
+		w := item.w
+		if err := w.parse(); err != nil {
+			t.Fatalf("%s: %v", item.name, err)
+		}
+		if got := w.contains(item.at); got != item.want {
+			t.Errorf("%s: %s at %s got %t, want %t", item.name, w.String(), item.at.Format("Mon 15:04"), got, item.want)
+		}
+	}
+}
+
+func TestWindowParse(t *testing.T) {
+	list := []struct {
+		name string
+		w    window
+		ok   bool
+	}{
+		{name: "day", w: window{Days: []string{"mon"}, From: "08:00", To: "17:00"}, ok: true},
+		{name: "upper case range", w: window{Days: []string{"Sat-Sun"}, From: "08:00", To: "17:00"}, ok: true},
+		{name: "no days", w: window{From: "08:00", To: "17:00"}},
+		{name: "unknown day", w: window{Days: []string{"monday"}, From: "08:00", To: "17:00"}},
+		{name: "unknown range end", w: window{Days: []string{"mon-xyz"}, From: "08:00", To: "17:00"}},
+		{name: "bad from", w: window{Days: []string{"mon"}, From: "8am", To: "17:00"}},
+		{name: "bad to", w: window{Days: []string{"mon"}, From: "08:00", To: "25:00"}},
+	}
+	for _, item := range list {
+		w := item.w
+		err := w.parse()
+		if (err == nil) != item.ok {
+			t.Errorf("%s: got %v, want ok %t", item.name, err, item.ok)
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// The web UI is served over HTTPS on the -web address, with the same
+// certificates as gRPC. A browser with an enrolled client certificate is
+// known by it. Otherwise the user logs in with the auth key or a guest
+// pass, which is kept in a session on the mirror and sent with each
+// request as a gRPC client would send it. Every request is authorized
+// and handled by the same methods as gRPC.
+
+const (
+	// webSessionTTL is how long a login lasts.
+	webSessionTTL = 30 * 24 * time.Hour
+	// webSessionCookie names the session cookie.
+	webSessionCookie = "garage_session"
+	// webKeepAlive is how often an idle event stream is written to, so
+	// proxies do not close it.
+	webKeepAlive = 30 * time.Second
+	// webLoginFailDelay slows down guessing the auth key or a pass.
+	webLoginFailDelay = time.Second
+)
+
+// webSession is a login. It holds what a gRPC client would send.
+type webSession struct {
+	key, pass string
+	expires   time.Time
+}
+
+// webServer serves the web UI for m.
+type webServer struct {
+	m *mirror
+
+	mu       sync.Mutex
+	sessions map[string]webSession
+}
+
+// serveWeb serves the web UI on addr until the mirror stops.
+func (m *mirror) serveWeb(addr string, config *tls.Config) error {
+	ws := &webServer{m: m, sessions: make(map[string]webSession)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", ws.page)
+	mux.HandleFunc("/app.js", ws.script)
+	mux.HandleFunc("/api/login", ws.login)
+	mux.HandleFunc("/api/logout", ws.logout)
+	mux.HandleFunc("/api/me", ws.me)
+	mux.HandleFunc("/api/doors", ws.doors)
+	mux.HandleFunc("/api/history", ws.history)
+	mux.HandleFunc("/api/toggle", ws.toggle)
+	mux.HandleFunc("/api/events", ws.events)
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %v", addr, err)
+	}
+	srv := &http.Server{Handler: mux, TLSConfig: config}
+	m.serving.Add(1)
+	go func() {
+		defer m.serving.Done()
+		<-m.appCtx.Done()
+		// Event streams end with the context, so Shutdown only waits
+		// for other requests.
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			srv.Close()
+		}
+	}()
+	go func() {
+		err := srv.ServeTLS(l, "", "")
+		if err != nil && m.appCtx.Err() == nil {
+			logger.Error("web UI stopped: ", err)
+		}
+	}()
+	return nil
+}
+
+// webAddr is the address of a browser.
+type webAddr string
+
+func (a webAddr) Network() string { return "tcp" }
+func (a webAddr) String() string  { return string(a) }
+
+// context returns the context of r as a gRPC method would see it, with
+// the client certificate of the browser and the credentials of its session.
+func (ws *webServer) context(r *http.Request) context.Context {
+	ctx := context.Context(r.Context())
+	p := &peer.Peer{Addr: webAddr(r.RemoteAddr)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	ctx = peer.NewContext(ctx, p)
+	if s, ok := ws.session(r); ok {
+		ctx = comm.NewContext(ctx, s.key, s.pass)
+	}
+	return ctx
+}
+
+// session returns the session of r, if it has one that has not expired.
+func (ws *webServer) session(r *http.Request) (webSession, bool) {
+	c, err := r.Cookie(webSessionCookie)
+	if err != nil {
+		return webSession{}, false
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	s, found := ws.sessions[c.Value]
+	if !found || !time.Now().Before(s.expires) {
+		delete(ws.sessions, c.Value)
+		return webSession{}, false
+	}
+	return s, true
+}
+
+// begin authorizes r to call method, answering it with an error if not.
+// Requests that change something must be POSTed by the page's script.
+func (ws *webServer) begin(w http.ResponseWriter, r *http.Request, method string) (context.Context, bool) {
+	if r.Method == "POST" && r.Header.Get("X-Garage") != "1" {
+		http.Error(w, "missing X-Garage header", http.StatusForbidden)
+		return nil, false
+	}
+	ctx := ws.context(r)
+	if err := ws.m.authorize(ctx, method); err != nil {
+		if grpc.Code(err) != codes.Unauthenticated {
+			logger.Warningf("web %s %s: %v", actor(ctx), method, grpc.ErrorDesc(err))
+		}
+		writeWebError(w, err)
+		return nil, false
+	}
+	return ctx, true
+}
+
+// webStatus maps the code of an RPC error to an HTTP status.
+var webStatus = map[codes.Code]int{
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.NotFound:           http.StatusNotFound,
+	codes.FailedPrecondition: http.StatusConflict,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+}
+
+func writeWebError(w http.ResponseWriter, err error) {
+	status, found := webStatus[grpc.Code(err)]
+	if !found {
+		status = http.StatusInternalServerError
+	}
+	writeWebJSON(w, status, struct{ Error string }{grpc.ErrorDesc(err)})
+}
+
+func writeWebJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// webHeaders sets the headers of the page and script.
+func webHeaders(w http.ResponseWriter, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' 'unsafe-inline'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+}
+
+func (ws *webServer) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	webHeaders(w, "text/html; charset=utf-8")
+	fmt.Fprint(w, webPage)
+}
+
+func (ws *webServer) script(w http.ResponseWriter, r *http.Request) {
+	webHeaders(w, "application/javascript; charset=utf-8")
+	fmt.Fprint(w, webScript)
+}
+
+// webIdentity is who is logged in.
+type webIdentity struct {
+	Name string
+	Role string
+}
+
+// login starts a session with the auth key or a guest pass.
+func (ws *webServer) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.Header.Get("X-Garage") != "1" {
+		http.Error(w, "POST with the X-Garage header", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct{ Secret string }
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || len(req.Secret) == 0 {
+		writeWebError(w, grpc.Errorf(codes.InvalidArgument, "enter the auth key or a guest pass"))
+		return
+	}
+	s := webSession{key: req.Secret, expires: time.Now().Add(webSessionTTL)}
+	if _, found := ws.m.passes.find(req.Secret); found {
+		s = webSession{pass: req.Secret, expires: s.expires}
+	}
+	ctx := comm.NewContext(peer.NewContext(r.Context(), &peer.Peer{Addr: webAddr(r.RemoteAddr)}), s.key, s.pass)
+	id, err := ws.m.who(ctx)
+	if err != nil {
+		logger.Warningf("web login from %s: %v", r.RemoteAddr, grpc.ErrorDesc(err))
+		time.Sleep(webLoginFailDelay)
+		writeWebError(w, grpc.Errorf(grpc.Code(err), "wrong auth key or pass: %s", grpc.ErrorDesc(err)))
+		return
+	}
+	b := make([]byte, 24)
+	_, err = rand.Read(b)
+	if err != nil {
+		writeWebError(w, err)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	ws.mu.Lock()
+	now := time.Now()
+	for t, old := range ws.sessions {
+		if !now.Before(old.expires) {
+			delete(ws.sessions, t)
+		}
+	}
+	ws.sessions[token] = s
+	ws.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     webSessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  s.expires,
+		Secure:   true,
+		HttpOnly: true,
+	})
+	logger.Infof("web login as %s %q from %s", roleName(id.role), id.name, r.RemoteAddr)
+	writeWebJSON(w, http.StatusOK, webIdentity{id.name, roleName(id.role)})
+}
+
+func (ws *webServer) logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.Header.Get("X-Garage") != "1" {
+		http.Error(w, "POST with the X-Garage header", http.StatusMethodNotAllowed)
+		return
+	}
+	if c, err := r.Cookie(webSessionCookie); err == nil {
+		ws.mu.Lock()
+		delete(ws.sessions, c.Value)
+		ws.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: webSessionCookie, Path: "/", MaxAge: -1, Secure: true, HttpOnly: true})
+	writeWebJSON(w, http.StatusOK, struct{}{})
+}
+
+func (ws *webServer) me(w http.ResponseWriter, r *http.Request) {
+	id, err := ws.m.who(ws.context(r))
+	if err != nil {
+		writeWebError(w, err)
+		return
+	}
+	writeWebJSON(w, http.StatusOK, webIdentity{id.name, roleName(id.role)})
+}
+
+// webDoor is the JSON form of a door.
+type webDoor struct {
+	Name   string
+	Online bool
+	State  string
+	Since  int64
+	Reason string
+	Fault  bool
+}
+
+func newWebDoor(name string, online bool, st *comm.DoorStatus) webDoor {
+	if st == nil {
+		st = &comm.DoorStatus{}
+	}
+	return webDoor{name, online, st.State.String(), st.SinceUnix, st.Reason, st.Fault}
+}
+
+// webEvent is the JSON form of an event.
+type webEvent struct {
+	Time    int64
+	Kind    string
+	Door    string
+	State   string `json:",omitempty"`
+	Reason  string `json:",omitempty"`
+	Fault   bool   `json:",omitempty"`
+	Action  string `json:",omitempty"`
+	Actor   string `json:",omitempty"`
+	OK      bool
+	Message string `json:",omitempty"`
+}
+
+func newWebEvent(ev *comm.Event) webEvent {
+	we := webEvent{Time: ev.TimeUnix, Kind: ev.Kind.String(), Door: ev.Door, OK: ev.OK, Message: ev.Message}
+	if st := ev.Status; st != nil {
+		we.State, we.Reason, we.Fault = st.State.String(), st.Reason, st.Fault
+	}
+	if ev.Kind == comm.EventKind_COMMAND {
+		we.Action, we.Actor = ev.Action.String(), ev.Actor
+	}
+	return we
+}
+
+func (ws *webServer) doors(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := ws.begin(w, r, "Doors")
+	if !ok {
+		return
+	}
+	resp, err := ws.m.Doors(ctx, &comm.DoorsReq{})
+	if err != nil {
+		writeWebError(w, err)
+		return
+	}
+	list := make([]webDoor, len(resp.Doors))
+	for i, d := range resp.Doors {
+		list[i] = newWebDoor(d.Name, d.Online, d.Status)
+	}
+	writeWebJSON(w, http.StatusOK, list)
+}
+
+func (ws *webServer) history(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := ws.begin(w, r, "History")
+	if !ok {
+		return
+	}
+	limit := 20
+	if n := r.FormValue("n"); len(n) != 0 {
+		var err error
+		limit, err = strconv.Atoi(n)
+		if err != nil {
+			writeWebError(w, grpc.Errorf(codes.InvalidArgument, "n %q is not a number", n))
+			return
+		}
+	}
+	resp, err := ws.m.History(ctx, &comm.HistoryReq{Door: r.FormValue("door"), Limit: int32(limit)})
+	if err != nil {
+		writeWebError(w, err)
+		return
+	}
+	// Newest first.
+	list := make([]webEvent, len(resp.Events))
+	for i, ev := range resp.Events {
+		list[len(list)-1-i] = newWebEvent(ev)
+	}
+	writeWebJSON(w, http.StatusOK, list)
+}
+
+func (ws *webServer) toggle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST a door and action", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, ok := ws.begin(w, r, "Toggle")
+	if !ok {
+		return
+	}
+	var req struct{ Door, Action string }
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeWebError(w, grpc.Errorf(codes.InvalidArgument, "invalid request: %v", err))
+		return
+	}
+	action, found := comm.Action_value[req.Action]
+	if !found {
+		writeWebError(w, grpc.Errorf(codes.InvalidArgument, "unknown action %q", req.Action))
+		return
+	}
+	resp, err := ws.m.Toggle(ctx, &comm.ToggleReq{TimeUnix: time.Now().Unix(), Action: comm.Action(action), Door: req.Door})
+	if err != nil {
+		writeWebError(w, err)
+		return
+	}
+	writeWebJSON(w, http.StatusOK, struct{ Message string }{resp.Message})
+}
+
+// webWatch sends watched events to a browser as server-sent events.
+type webWatch struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	mu sync.Mutex
+	w  http.ResponseWriter
+	f  http.Flusher
+}
+
+func (ww *webWatch) Context() context.Context {
+	return ww.ctx
+}
+
+func (ww *webWatch) Send(ev *comm.Event) error {
+	return ww.send("event", newWebEvent(ev))
+}
+
+// send writes v as an event named name. An empty name writes a comment
+// that keeps the stream open.
+func (ww *webWatch) send(name string, v interface{}) error {
+	ww.mu.Lock()
+	defer ww.mu.Unlock()
+	var err error
+	if len(name) == 0 {
+		_, err = fmt.Fprint(ww.w, ":\n\n")
+	} else {
+		var b []byte
+		b, err = json.Marshal(v)
+		if err == nil {
+			_, err = fmt.Fprintf(ww.w, "event: %s\ndata: %s\n\n", name, b)
+		}
+	}
+	ww.f.Flush()
+	return err
+}
+
+// events streams door events until the browser leaves or may no longer
+// watch. The first events are the current state of each door.
+func (ws *webServer) events(w http.ResponseWriter, r *http.Request) {
+	ctx, ok := ws.begin(w, r, "Watch")
+	if !ok {
+		return
+	}
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	ww := &webWatch{ctx: ctx, w: w, f: f}
+	ww.send("", nil)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		tick := time.NewTicker(webKeepAlive)
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+				ww.send("", nil)
+			}
+		}
+	}()
+
+	err := ws.m.Watch(&comm.WatchReq{Door: r.FormValue("door")}, ww)
+	if err != nil {
+		status, found := webStatus[grpc.Code(err)]
+		if !found {
+			status = http.StatusInternalServerError
+		}
+		ww.send("failed", struct {
+			Error  string
+			Status int
+		}{grpc.ErrorDesc(err), status})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+)
+
+func TestWebAuthorize(t *testing.T) {
+	p := testPolicy(t, `{"TimeZone": "UTC", "KeyRole": "admin", "Users": {
+		"neighbour": {"Doors": ["south"]},
+		"visitor": {"Doors": ["north"]}}}`)
+	passes, err := loadPasses("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	token, err := passes.create(&pass{Name: "plumber", Doors: []string{"north"}, Created: now, Expires: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mirror{
+		appCtx:  context.Background(),
+		doors:   map[string]*door{"north": {name: "north"}, "south": {name: "south"}},
+		pending: make(map[uint64]chan *comm.CommandResult),
+		events:  newEventLog(10),
+		policy:  &policyStore{p: p},
+		passes:  passes,
+	}
+	ws := &webServer{m: m, sessions: map[string]webSession{
+		"key":     {key: comm.AuthKey(), expires: now.Add(time.Hour)},
+		"badkey":  {key: "wrong", expires: now.Add(time.Hour)},
+		"pass":    {pass: token, expires: now.Add(time.Hour)},
+		"expired": {key: comm.AuthKey(), expires: now.Add(-time.Second)},
+	}}
+
+	list := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		noCheck bool // Leave out the X-Garage header on a POST.
+		// cn and ou name the client certificate, none is sent if cn
+		// is empty. session is the session cookie, if any.
+		cn, ou  string
+		session string
+		status  int
+		// doors are the doors listed, for a 200 from /api/doors.
+		doors []string
+	}{
+		{name: "no credentials", path: "/api/doors", status: http.StatusUnauthorized},
+		{name: "admin certificate", path: "/api/doors", cn: "phone", ou: "admin", status: http.StatusOK, doors: []string{"north", "south"}},
+		{name: "member certificate", path: "/api/doors", cn: "neighbour", ou: "member", status: http.StatusOK, doors: []string{"south"}},
+		{name: "guest certificate", path: "/api/doors", cn: "visitor", ou: "guest", status: http.StatusOK, doors: []string{"north"}},
+		{name: "guest without access", path: "/api/doors", cn: "stranger", ou: "guest", status: http.StatusForbidden},
+		{name: "opener certificate", path: "/api/doors", cn: "north", ou: "opener", status: http.StatusForbidden},
+		{name: "no role", path: "/api/doors", cn: "phone", ou: "", status: http.StatusForbidden},
+		{name: "key session", path: "/api/doors", session: "key", status: http.StatusOK, doors: []string{"north", "south"}},
+		{name: "wrong key session", path: "/api/doors", session: "badkey", status: http.StatusUnauthorized},
+		{name: "expired session", path: "/api/doors", session: "expired", status: http.StatusUnauthorized},
+		{name: "unknown session", path: "/api/doors", session: "other", status: http.StatusUnauthorized},
+		{name: "pass session", path: "/api/doors", session: "pass", status: http.StatusOK, doors: []string{"north"}},
+		{name: "me with pass", path: "/api/me", session: "pass", status: http.StatusOK},
+		{name: "me without credentials", path: "/api/me", status: http.StatusUnauthorized},
+		{name: "history of another door", path: "/api/history?door=south", session: "pass", status: http.StatusForbidden},
+		{name: "history of own door", path: "/api/history?door=north", session: "pass", status: http.StatusOK},
+		{name: "toggle without header", method: "POST", path: "/api/toggle", body: `{"Door":"north","Action":"TOGGLE"}`, noCheck: true, session: "key", status: http.StatusForbidden},
+		{name: "toggle by GET", path: "/api/toggle", session: "key", status: http.StatusMethodNotAllowed},
+		{name: "toggle without credentials", method: "POST", path: "/api/toggle", body: `{"Door":"north","Action":"TOGGLE"}`, status: http.StatusUnauthorized},
+		{name: "toggle another door", method: "POST", path: "/api/toggle", body: `{"Door":"south","Action":"TOGGLE"}`, session: "pass", status: http.StatusForbidden},
+		// The door is offline, so a toggle that is allowed is unavailable.
+		{name: "toggle own door", method: "POST", path: "/api/toggle", body: `{"Door":"north","Action":"TOGGLE"}`, session: "pass", status: http.StatusServiceUnavailable},
+		{name: "toggle as opener", method: "POST", path: "/api/toggle", body: `{"Door":"north","Action":"TOGGLE"}`, cn: "north", ou: "opener", status: http.StatusForbidden},
+		{name: "toggle unknown action", method: "POST", path: "/api/toggle", body: `{"Door":"north","Action":"PUSH"}`, session: "key", status: http.StatusBadRequest},
+		{name: "logout without header", method: "POST", path: "/api/logout", noCheck: true, status: http.StatusMethodNotAllowed},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/me", ws.me)
+	mux.HandleFunc("/api/doors", ws.doors)
+	mux.HandleFunc("/api/history", ws.history)
+	mux.HandleFunc("/api/toggle", ws.toggle)
+	mux.HandleFunc("/api/logout", ws.logout)
+	for _, item := range list {
+		method := item.method
+		if len(method) == 0 {
+			method = "GET"
+		}
+		r := httptest.NewRequest(method, "https://garage"+item.path, strings.NewReader(item.body))
+		if method == "POST" && !item.noCheck {
+			r.Header.Set("X-Garage", "1")
+		}
+		r.TLS = &tls.ConnectionState{}
+		if len(item.cn) != 0 {
+			*r.TLS = certState(item.cn, item.ou)
+		}
+		if len(item.session) != 0 {
+			r.AddCookie(&http.Cookie{Name: webSessionCookie, Value: item.session})
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != item.status {
+			t.Errorf("%s: got %d %s, want %d", item.name, w.Code, strings.TrimSpace(w.Body.String()), item.status)
+			continue
+		}
+		if w.Code != http.StatusOK || item.path != "/api/doors" {
+			continue
+		}
+		var doors []webDoor
+		if err := json.Unmarshal(w.Body.Bytes(), &doors); err != nil {
+			t.Errorf("%s: %v", item.name, err)
+			continue
+		}
+		var got []string
+		for _, d := range doors {
+			got = append(got, d.Name)
+		}
+		if !reflect.DeepEqual(got, item.doors) {
+			t.Errorf("%s: got doors %v, want %v", item.name, got, item.doors)
+		}
+	}
+}
+
+func TestWebLogin(t *testing.T) {
+	passes, err := loadPasses("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mirror{
+		appCtx: context.Background(),
+		doors:  make(map[string]*door),
+		events: newEventLog(10),
+		policy: &policyStore{p: &policy{loc: time.UTC, keyRole: comm.Role_ADMIN}},
+		passes: passes,
+	}
+	ws := &webServer{m: m, sessions: make(map[string]webSession)}
+
+	post := func(path, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "https://garage"+path, strings.NewReader(body))
+		r.Header.Set("X-Garage", "1")
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		map[string]http.HandlerFunc{"/api/login": ws.login, "/api/logout": ws.logout}[path](w, r)
+		return w
+	}
+	me := func(cookie *http.Cookie) int {
+		r := httptest.NewRequest("GET", "https://garage/api/me", nil)
+		r.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		ws.me(w, r)
+		return w.Code
+	}
+
+	if w := post("/api/login", `{"Secret":""}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("empty secret: got %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	w := post("/api/login", `{"Secret":"`+comm.AuthKey()+`"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: got %d %s", w.Code, w.Body.String())
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == webSessionCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.Secure || !cookie.HttpOnly {
+		t.Fatalf("login: got cookie %v, want a secure, HTTP only session", cookie)
+	}
+	if code := me(cookie); code != http.StatusOK {
+		t.Errorf("me after login: got %d", code)
+	}
+	post("/api/logout", "", cookie)
+	if code := me(cookie); code != http.StatusUnauthorized {
+		t.Errorf("me after logout: got %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+// webPage is the web UI. The door list and history are filled in by webScript.
+const webPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Garage</title>
+<style>
+body { font-family: sans-serif; margin: 0 auto; max-width: 40em; padding: 1em; background: #f4f4f4; color: #222; }
+h1 { font-size: 1.4em; display: flex; justify-content: space-between; align-items: baseline; }
+h1 small { font-size: 0.6em; font-weight: normal; }
+.door { background: #fff; border-radius: 6px; padding: 1em; margin-bottom: 1em; display: flex; justify-content: space-between; align-items: center; }
+.door .name { font-weight: bold; font-size: 1.2em; }
+.door .state { font-size: 1.1em; }
+.door .reason { color: #666; font-size: 0.9em; }
+.door.offline { opacity: 0.6; }
+.door.fault .state, .error { color: #b00; }
+button { font-size: 1em; padding: 0.7em 1.2em; border: 0; border-radius: 6px; background: #2a6ebb; color: #fff; }
+button:disabled { background: #999; }
+button.link { background: none; color: #2a6ebb; padding: 0; }
+#login { background: #fff; border-radius: 6px; padding: 1em; }
+#login input { font-size: 1em; padding: 0.5em; width: 100%; box-sizing: border-box; margin: 0.5em 0 1em; }
+#history { list-style: none; padding: 0; font-size: 0.9em; }
+#history li { padding: 0.3em 0; border-bottom: 1px solid #ddd; }
+#history .time { color: #666; margin-right: 0.5em; }
+.hidden { display: none; }
+</style>
+</head>
+<body>
+<h1>Garage <small id="who"></small></h1>
+<p id="notice" class="error"></p>
+<form id="login" class="hidden">
+<label for="secret">Auth key or guest pass</label>
+<input id="secret" type="password" autocomplete="current-password" required>
+<button type="submit">Log in</button>
+</form>
+<div id="main" class="hidden">
+<div id="doors"></div>
+<h2>Recent events</h2>
+<ul id="history"></ul>
+<button id="logout" class="link">Log out</button>
+</div>
+<script src="/app.js"></script>
+</body>
+</html>
+`
+
+// webScript runs the web UI. It only sets text, never HTML, from what the
+// mirror sends.
+const webScript = `"use strict";
+var doors = {};
+var stream = null;
+var historySize = 20;
+
+function $(id) { return document.getElementById(id); }
+
+function api(method, path, body) {
+	var opts = {method: method, credentials: "same-origin", headers: {"X-Garage": "1"}};
+	if (body) {
+		opts.headers["Content-Type"] = "application/json";
+		opts.body = JSON.stringify(body);
+	}
+	return fetch(path, opts).then(function(resp) {
+		return resp.json().then(function(v) {
+			if (!resp.ok) {
+				var err = new Error(v.Error || resp.statusText);
+				err.status = resp.status;
+				throw err;
+			}
+			return v;
+		});
+	});
+}
+
+function notice(msg) { $("notice").textContent = msg || ""; }
+
+function fail(err) {
+	if (err.status === 401) {
+		showLogin();
+	}
+	notice(err.message);
+}
+
+function showLogin() {
+	if (stream) { stream.close(); stream = null; }
+	$("main").classList.add("hidden");
+	$("login").classList.remove("hidden");
+	$("who").textContent = "";
+	$("secret").focus();
+}
+
+function start() {
+	api("GET", "/api/me").then(function(me) {
+		$("who").textContent = me.Name + " (" + me.Role + ")";
+		$("login").classList.add("hidden");
+		$("main").classList.remove("hidden");
+		notice("");
+		watch();
+	}, fail);
+}
+
+function formatTime(unix) {
+	if (!unix) { return "-"; }
+	return new Date(unix * 1000).toLocaleString();
+}
+
+function loadDoors() {
+	return api("GET", "/api/doors").then(function(list) {
+		doors = {};
+		list.forEach(function(d) { doors[d.Name] = d; });
+		drawDoors();
+	}, fail);
+}
+
+function loadHistory() {
+	return api("GET", "/api/history?n=" + historySize).then(function(list) {
+		var ul = $("history");
+		ul.textContent = "";
+		list.forEach(function(ev) { ul.appendChild(eventItem(ev)); });
+	}, fail);
+}
+
+// action returns the action and label of the button for door d.
+function action(d) {
+	switch (d.State) {
+	case "CLOSED": return ["OPEN_DOOR", "Open"];
+	case "OPEN": return ["CLOSE_DOOR", "Close"];
+	}
+	return ["TOGGLE", "Press button"];
+}
+
+function drawDoors() {
+	var div = $("doors");
+	div.textContent = "";
+	var names = Object.keys(doors).sort();
+	if (names.length === 0) {
+		div.textContent = "No doors.";
+		return;
+	}
+	names.forEach(function(name) {
+		var d = doors[name];
+		var row = document.createElement("div");
+		row.className = "door" + (d.Online ? "" : " offline") + (d.Fault ? " fault" : "");
+		var text = document.createElement("div");
+		var title = document.createElement("div");
+		title.className = "name";
+		title.textContent = name;
+		var state = document.createElement("div");
+		state.className = "state";
+		state.textContent = d.State + (d.Fault ? " (fault)" : "") + (d.Online ? "" : " (offline)");
+		var reason = document.createElement("div");
+		reason.className = "reason";
+		reason.textContent = (d.Reason ? d.Reason + ", " : "") + "since " + formatTime(d.Since);
+		text.appendChild(title);
+		text.appendChild(state);
+		text.appendChild(reason);
+
+		var a = action(d);
+		var btn = document.createElement("button");
+		btn.textContent = a[1];
+		btn.disabled = !d.Online;
+		btn.onclick = function() {
+			if (!confirm(a[1] + " " + name + "?")) { return; }
+			btn.disabled = true;
+			api("POST", "/api/toggle", {Door: name, Action: a[0]}).then(function(resp) {
+				notice(resp.Message);
+			}, fail).then(function() { btn.disabled = !doors[name] || !doors[name].Online; });
+		};
+		row.appendChild(text);
+		row.appendChild(btn);
+		div.appendChild(row);
+	});
+}
+
+function eventItem(ev) {
+	var li = document.createElement("li");
+	var time = document.createElement("span");
+	time.className = "time";
+	time.textContent = formatTime(ev.Time);
+	var text = (ev.Door || "mirror") + " ";
+	switch (ev.Kind) {
+	case "COMMAND":
+		text += ev.Action + " by " + ev.Actor + ": " + (ev.OK ? "ok" : "failed");
+		if (ev.Message) { text += ", " + ev.Message; }
+		break;
+	case "NOTICE":
+		text += ev.Message;
+		break;
+	case "STATUS":
+		text += ev.State + (ev.Reason ? ", " + ev.Reason : "") + (ev.Fault ? " (fault)" : "");
+		break;
+	default:
+		text += ev.Kind;
+	}
+	li.appendChild(time);
+	li.appendChild(document.createTextNode(text));
+	return li;
+}
+
+function addEvent(ev) {
+	var ul = $("history");
+	ul.insertBefore(eventItem(ev), ul.firstChild);
+	while (ul.children.length > historySize) {
+		ul.removeChild(ul.lastChild);
+	}
+}
+
+// watch follows events, loading the doors and history again each time
+// the stream starts so nothing is missed while it was closed.
+function watch() {
+	if (stream) { stream.close(); }
+	stream = new EventSource("/api/events");
+	stream.onopen = function() {
+		loadDoors();
+		loadHistory();
+	};
+	stream.addEventListener("event", function(msg) {
+		var ev = JSON.parse(msg.data);
+		var d = doors[ev.Door];
+		switch (ev.Kind) {
+		case "CONNECTED":
+		case "DISCONNECTED":
+			if (!d) { loadDoors(); return; }
+			d.Online = ev.Kind === "CONNECTED";
+			if (ev.State) {
+				d.State = ev.State;
+				d.Reason = ev.Reason;
+				d.Fault = ev.Fault;
+			}
+			drawDoors();
+			return;
+		case "STATUS":
+			if (!d) { loadDoors(); } else {
+				d.State = ev.State;
+				d.Reason = ev.Reason;
+				d.Fault = ev.Fault;
+				d.Since = ev.Time;
+				drawDoors();
+			}
+			break;
+		}
+		addEvent(ev);
+	});
+	stream.addEventListener("failed", function(msg) {
+		var v = JSON.parse(msg.data);
+		stream.close();
+		stream = null;
+		notice(v.Error);
+		// Check the login is still good before watching again.
+		setTimeout(start, 3000);
+	});
+}
+
+$("login").onsubmit = function(e) {
+	e.preventDefault();
+	api("POST", "/api/login", {Secret: $("secret").value}).then(function() {
+		$("secret").value = "";
+		start();
+	}, function(err) { notice(err.message); });
+};
+
+$("logout").onclick = function() {
+	api("POST", "/api/logout").then(showLogin, fail);
+};
+
+start();
+`
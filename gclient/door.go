@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+)
+
+// doorView is what the app knows about a door.
+type doorView struct {
+	name   string
+	online bool
+	status *comm.DoorStatus
+
+	// lastActor is who last sent the door a command that succeeded.
+	lastActor   string
+	lastActorAt time.Time
+	lastAction  comm.Action
+}
+
+// doorViews tracks each door from the events sent by the mirror.
+type doorViews map[string]*doorView
+
+func (dv doorViews) get(name string) *doorView {
+	d := dv[name]
+	if d == nil {
+		d = &doorView{name: name}
+		dv[name] = d
+	}
+	return d
+}
+
+// apply updates the doors from an event.
+func (dv doorViews) apply(ev *comm.Event) {
+	if ev.Kind == comm.EventKind_NOTICE {
+		return
+	}
+	d := dv.get(ev.Door)
+	switch ev.Kind {
+	case comm.EventKind_CONNECTED:
+		d.online = true
+	case comm.EventKind_DISCONNECTED:
+		d.online = false
+	case comm.EventKind_COMMAND:
+		if ev.OK {
+			d.lastActor = ev.Actor
+			d.lastActorAt = time.Unix(ev.TimeUnix, 0)
+			d.lastAction = ev.Action
+		}
+	}
+	if ev.Status != nil {
+		d.status = ev.Status
+	}
+}
+
+// names returns the door names in order.
+func (dv doorViews) names() []string {
+	names := make([]string, 0, len(dv))
+	for name := range dv {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// title is the door name and state, such as "North: CLOSED".
+func (d *doorView) title() string {
+	name := d.name
+	if len(name) != 0 {
+		name = strings.ToUpper(name[:1]) + name[1:]
+	}
+	if d.status == nil {
+		return name + ": unknown"
+	}
+	return fmt.Sprintf("%s: %v", name, d.status.State)
+}
+
+// sinceTime returns when the door entered its state, or zero if unknown.
+func (d *doorView) sinceTime() time.Time {
+	if d.status == nil || d.status.SinceUnix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(d.status.SinceUnix, 0)
+}
+
+// since describes how long the door has been in its state.
+func (d *doorView) since(now time.Time) string {
+	if d.status == nil || d.status.SinceUnix == 0 {
+		return "state not reported yet"
+	}
+	s := fmt.Sprintf("%s for %s", strings.ToLower(d.status.State.String()), formatAgo(now.Sub(time.Unix(d.status.SinceUnix, 0))))
+	if d.status.Fault {
+		s += ", fault: " + d.status.Reason
+	}
+	return s
+}
+
+// actor describes the last command sent to the door.
+func (d *doorView) actor(now time.Time) string {
+	if len(d.lastActor) == 0 {
+		return "no recent commands"
+	}
+	return fmt.Sprintf("%s by %s %s ago", strings.ToLower(d.lastAction.String()), d.lastActor, formatAgo(now.Sub(d.lastActorAt)))
+}
+
+// formatAgo rounds d for display, such as "5m" or "2h 3m".
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return fmt.Sprintf("%dd %dh", int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour))
+}
+
+// untilChange returns how long until formatAgo(d) shows a different value.
+func untilChange(d time.Duration) time.Duration {
+	var unit time.Duration
+	switch {
+	case d < time.Minute:
+		unit = time.Second
+	case d < 24*time.Hour:
+		unit = time.Minute
+	default:
+		unit = time.Hour
+	}
+	return unit - d%unit
+}
+
+// nextChange returns how long until the text shown for the door changes
+// with the passing of time, or zero if it does not.
+func (d *doorView) nextChange(now time.Time) time.Duration {
+	var next time.Duration
+	for _, at := range []time.Time{d.lastActorAt, d.sinceTime()} {
+		if at.IsZero() {
+			continue
+		}
+		if u := untilChange(now.Sub(at)); next == 0 || u < next {
+			next = u
+		}
+	}
+	return next
+}
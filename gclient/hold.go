@@ -0,0 +1,70 @@
+package main
+
+import (
+	"time"
+
+	"golang.org/x/mobile/event/touch"
+)
+
+// holdDuration is how long the screen must be held to toggle the door.
+const holdDuration = 1500 * time.Millisecond
+
+// holdSlop is how far, in pixels, a finger may move and still be holding.
+const holdSlop = 60
+
+// hold tracks a press and hold gesture. Only the first finger down is
+// followed; the hold is cancelled if it lifts or moves away.
+type hold struct {
+	active bool
+	seq    touch.Sequence
+	x, y   float32
+	start  time.Time
+
+	// cancelled is when the last hold was cancelled before completing.
+	cancelled time.Time
+}
+
+// event updates the hold from a touch event.
+func (h *hold) event(t touch.Event, now time.Time) {
+	switch t.Type {
+	case touch.TypeBegin:
+		if h.active {
+			return
+		}
+		h.active = true
+		h.seq = t.Sequence
+		h.x, h.y = t.X, t.Y
+		h.start = now
+	case touch.TypeMove:
+		if !h.active || t.Sequence != h.seq {
+			return
+		}
+		dx, dy := t.X-h.x, t.Y-h.y
+		if dx*dx+dy*dy > holdSlop*holdSlop {
+			h.cancel(now)
+		}
+	case touch.TypeEnd:
+		if h.active && t.Sequence == h.seq {
+			h.cancel(now)
+		}
+	}
+}
+
+func (h *hold) cancel(now time.Time) {
+	h.active = false
+	h.cancelled = now
+}
+
+// progress returns how much of the hold is done, from 0 to 1. Once the
+// hold completes done is true and a new press is needed to hold again.
+func (h *hold) progress(now time.Time) (p float32, done bool) {
+	if !h.active {
+		return 0, false
+	}
+	p = float32(now.Sub(h.start)) / float32(holdDuration)
+	if p < 1 {
+		return p, false
+	}
+	h.active = false
+	return 1, true
+}
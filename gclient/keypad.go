@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/kardianos/garage/comm"
+)
+
+// The app has no text entry, so text is tapped in on a keypad drawn in
+// place of the settings list: a pairing code from an admin, which the
+// active profile enrolls with, or the server address of the profile.
+
+// entryMode is what the keypad is entering.
+type entryMode int
+
+const (
+	entryNone entryMode = iota
+	entryPairCode
+	entryAddr
+)
+
+// pairCodeLen is how many letters and digits a pairing code has.
+const pairCodeLen = 8
+
+// addrMaxLen is the longest server address that may be entered.
+const addrMaxLen = 64
+
+const (
+	keyDelete = "Del"
+	keyPair   = "Pair"
+	keySave   = "Save"
+	keyCancel = "Cancel"
+)
+
+var (
+	// pairKeys are the letters and digits pairing codes are made of,
+	// leaving out those easily confused, then the editing keys.
+	pairKeys = append(strings.Split("ABCDEFGHJKLMNPQRSTUVWXYZ23456789", ""), keyDelete, keyPair, keyCancel)
+	// addrKeys are the characters of a host name, IP address and port.
+	addrKeys = append(strings.Split("abcdefghijklmnopqrstuvwxyz0123456789.-:", ""), keyDelete, keySave, keyCancel)
+)
+
+// keys returns the keys of the keypad for mode.
+func (mode entryMode) keys() []string {
+	if mode == entryAddr {
+		return addrKeys
+	}
+	return pairKeys
+}
+
+// showEntry shows the keypad for mode on the settings screen, starting
+// with text, or hides it for entryNone.
+func (vs *viewState) showEntry(mode entryMode, text string) {
+	vs.mu.Lock()
+	vs.entry = mode
+	vs.entered = text
+	vs.notice = ""
+	vs.mu.Unlock()
+	vs.redraw()
+}
+
+// editAddr shows the keypad to enter the server address of the active
+// profile.
+func (vs *viewState) editAddr() {
+	vs.mu.RLock()
+	addr := vs.prefs.active().Addr
+	vs.mu.RUnlock()
+	vs.showEntry(entryAddr, addr)
+}
+
+// entryText describes the text entered so far. The view must be locked.
+func (vs *viewState) entryText() (title string, lines []string) {
+	p := vs.prefs.active()
+	notice := vs.notice
+	if vs.entry == entryAddr {
+		if len(notice) == 0 {
+			notice = fmt.Sprintf("Enter host:port, the port is %d if left out, or nothing for the built in server", comm.Port())
+		}
+		return "Server " + vs.entered + "_", []string{"Profile " + p.Name, "Now " + profileAddr(p), notice}
+	}
+	code := vs.entered + strings.Repeat("_", pairCodeLen-len(vs.entered))
+	if len(notice) == 0 {
+		notice = "Ask an admin for a code, then tap Pair"
+	}
+	return "Pairing code " + code[:4] + "-" + code[4:], []string{"Profile " + p.Name, "Server " + profileAddr(p), notice}
+}
+
+// pressKey handles a tap on the keypad.
+func (vs *viewState) pressKey(key string) {
+	vs.mu.Lock()
+	vs.notice = ""
+	max := pairCodeLen
+	if vs.entry == entryAddr {
+		max = addrMaxLen
+	}
+	switch key {
+	case keyCancel:
+		vs.entry = entryNone
+		vs.entered = ""
+	case keyDelete:
+		if n := len(vs.entered); n != 0 {
+			vs.entered = vs.entered[:n-1]
+		}
+	case keyPair:
+		if len(vs.entered) != pairCodeLen {
+			vs.notice = fmt.Sprintf("Enter all %d letters and digits of the code", pairCodeLen)
+			break
+		}
+		code := vs.entered[:4] + "-" + vs.entered[4:]
+		p := vs.prefs.active()
+		p.PairCode = code
+		vs.entry = entryNone
+		vs.entered = ""
+		vs.notice = "Enrolling " + p.Name + " with " + code
+		vs.savePrefs()
+		vs.mu.Unlock()
+		vs.startConn(vs.appCtx)
+		return
+	case keySave:
+		addr, err := parseAddr(vs.entered)
+		if err != nil {
+			vs.notice = err.Error()
+			break
+		}
+		p := vs.prefs.active()
+		p.Addr = addr
+		vs.entry = entryNone
+		vs.entered = ""
+		vs.notice = "Connecting " + p.Name + " to " + profileAddr(p)
+		vs.savePrefs()
+		vs.mu.Unlock()
+		vs.startConn(vs.appCtx)
+		return
+	default:
+		if len(vs.entered) < max {
+			vs.entered += key
+		}
+	}
+	vs.mu.Unlock()
+	vs.redraw()
+}
+
+// parseAddr checks an entered server address, adding the default port
+// if there is none. An empty address selects the built in server.
+func parseAddr(s string) (string, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		host, port = s, strconv.Itoa(comm.Port())
+	}
+	if len(host) == 0 || strings.Contains(host, ":") {
+		return "", fmt.Errorf("%q is not a host name or IPv4 address", s)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("port %q is not a number from 1 to 65535", port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
@@ -2,15 +2,15 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/kardianos/garage/comm"
+	"github.com/kardianos/garage/comm/client"
 
 	"golang.org/x/mobile/app"
 	"golang.org/x/mobile/event/lifecycle"
@@ -19,7 +19,6 @@ import (
 	"golang.org/x/mobile/event/touch"
 	"golang.org/x/mobile/gl"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 )
 
 func main() {
@@ -29,7 +28,15 @@ func main() {
 
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
-		vs := newViewState()
+		// paintPending is set while a paint event is queued, so requests
+		// to paint before it is handled result in a single paint.
+		var paintPending int32
+		requestPaint := func() {
+			if atomic.CompareAndSwapInt32(&paintPending, 0, 1) {
+				a.Send(paint.Event{})
+			}
+		}
+		vs := newViewState(requestPaint)
 		vs.startConn(ctx)
 
 		for e := range a.Events() {
@@ -46,117 +53,286 @@ func main() {
 				case lifecycle.CrossOn:
 					glctx, _ = e.DrawContext.(gl.Context)
 					vs.start(glctx)
-					a.Send(paint.Event{})
+					requestPaint()
 				case lifecycle.CrossOff:
 					vs.end(glctx)
 					glctx = nil
 				}
 			case size.Event:
 				sz = e
+				requestPaint()
 			case paint.Event:
-				if glctx == nil || e.External {
-					// As we are actively painting as fast as
-					// we can (usually 60 FPS), skip any paint
-					// events sent by the system.
+				if !e.External {
+					atomic.StoreInt32(&paintPending, 0)
+				}
+				if glctx == nil {
 					continue
 				}
 
-				vs.draw(glctx, sz)
+				animating := vs.draw(glctx, sz)
 				a.Publish()
-				// Drive the animation by preparing to paint the next frame
-				// after this one is shown.
-				a.Send(paint.Event{})
+				// Only paint the next frame right away while something
+				// is moving. Otherwise wait for the state to change.
+				if animating {
+					requestPaint()
+				}
 			case touch.Event:
 				vs.touch(e)
+				requestPaint()
 			}
 		}
 	})
 }
 
 type viewState struct {
-	touchChange  touch.Event
 	tc           chan touch.Event
+	hold         hold
 	percentColor float32
 	lastDraw     time.Time
-	lastTouch    time.Time
 
 	sq *Square
 
-	toggle chan time.Time
+	// redraw asks for the view to be drawn again.
+	redraw func()
+	// tick redraws when the shown text changes with the time.
+	tick *time.Timer
+
+	toggle chan toggleReq
+
+	// listTouch is the list row touched down on, or -1.
+	listTouch int
 
-	mu      sync.RWMutex
-	pingOk  bool
-	connErr error
+	mu    sync.RWMutex
+	prefs prefs
+	// unsaved is the settings waiting for runSaver, signalled on saves.
+	unsaved *prefs
+	saves   chan struct{}
+	// settings is set while the settings screen is shown.
+	settings bool
+	// listPage is the page of the list shown when it has too many rows.
+	listPage int
+	// entry is what the keypad shown on the settings screen is entering,
+	// and entered is the text so far.
+	entry   entryMode
+	entered string
+	// notice is a message shown on the settings screen.
+	notice string
+
+	// appCtx is done when the app ends.
+	appCtx context.Context
+	// connCancel stops the connection for the active profile.
+	connCancel func()
+	// mirror is the state of the connection to the mirror,
+	// separate from whether the door opener is online.
+	mirror    client.State
+	mirrorErr error
+	doors     doorViews
+	// cmdErr is the error from the last command, shown for a while.
+	cmdErr   error
+	cmdErrAt time.Time
+}
+
+// cmdErrShown is how long a command error is shown.
+const cmdErrShown = 10 * time.Second
+
+// toggleReq asks for the door to be toggled.
+type toggleReq struct {
+	at   time.Time
+	door string
 }
 
-func newViewState() *viewState {
-	return &viewState{
-		tc:          make(chan touch.Event, 100),
-		lastDraw:    time.Now(),
-		touchChange: touch.Event{Type: 200},
+func newViewState(redraw func()) *viewState {
+	p, err := loadPrefs()
+	if err != nil {
+		log.Println("load prefs", err)
+	}
+	p.active()
+	vs := &viewState{
+		tc:        make(chan touch.Event, 100),
+		lastDraw:  time.Now(),
+		doors:     make(doorViews),
+		listTouch: -1,
+		redraw:    redraw,
+		prefs:     p,
+		saves:     make(chan struct{}, 1),
 	}
+	go vs.runSaver()
+	return vs
 }
 
-func (vs *viewState) runConn(ctx context.Context) {
-	certpool := x509.NewCertPool()
-	if !certpool.AppendCertsFromPEM(comm.CA()) { // Add CA public cert.
-		panic("failed to add cert")
+// door returns the selected door, or the first door if none is selected.
+// The view must be locked.
+func (vs *viewState) door() *doorView {
+	if d, found := vs.doors[vs.prefs.active().Door]; found {
+		return d
 	}
-	creds := credentials.NewTLS(&tls.Config{
-		RootCAs: certpool,
-	})
-	dnsName := fmt.Sprintf("%s:%d", comm.Host(), comm.Port())
-	conn, err := grpc.DialContext(ctx, dnsName,
-		grpc.WithTransportCredentials(creds),
-	)
+	if names := vs.doors.names(); len(names) != 0 {
+		return vs.doors[names[0]]
+	}
+	return nil
+}
+
+// selectDoor sends commands to the named door from now on,
+// remembering it for the next launch.
+func (vs *viewState) selectDoor(name string) {
+	vs.mu.Lock()
+	vs.prefs.active().Door = name
+	vs.savePrefs()
+	vs.mu.Unlock()
+	vs.redraw()
+}
+
+// runConn connects with the profile p until ctx is done.
+func (vs *viewState) runConn(ctx context.Context, p profile) {
+	if len(p.PairCode) != 0 {
+		enrolled, err := vs.enroll(ctx, p)
+		switch {
+		case err == nil:
+			p = enrolled
+		case len(p.Cert) == 0 || ctx.Err() != nil:
+			vs.setMirror(client.Disconnected, fmt.Errorf("enroll: %v", grpc.ErrorDesc(err)))
+			return
+		default:
+			// Keep using the certificate enrolled before.
+			vs.setCmdErr(fmt.Errorf("enroll: %v", grpc.ErrorDesc(err)))
+		}
+	}
+	config := p.config()
+	config.OnState = func(s client.State, err error) {
+		// A replaced connection may still report as it closes.
+		if ctx.Err() == nil {
+			vs.setMirror(s, err)
+		}
+	}
+	cl, err := client.New(config)
 	if err != nil {
-		vs.setPing(err)
+		vs.setMirror(client.Disconnected, err)
 		return
 	}
-	defer conn.Close()
+	defer cl.Close()
 
-	gc := comm.NewGarageClient(conn)
-	_, err = gc.Ping(ctx, &comm.PingReq{})
-	vs.setPing(err)
+	go vs.runWatch(ctx, cl)
 
 	for {
-		ticker := time.NewTicker(2500 * time.Millisecond)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case tm := <-vs.toggle:
-				if tm.Add(time.Second * 10).Before(time.Now()) {
-					continue
-				}
-				_, err = gc.Toggle(ctx, &comm.ToggleReq{TimeUnix: tm.Unix()})
-				vs.setPing(err)
-
-			case tm := <-ticker.C:
-				_, err = gc.Ping(ctx, &comm.PingReq{TimeUnix: tm.Unix()})
-				vs.setPing(err)
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-vs.toggle:
+			if req.at.Add(time.Second * 10).Before(time.Now()) {
+				continue
 			}
+			_, err = cl.Toggle(ctx, req.door)
+			vs.setCmdErr(err)
 		}
 	}
 }
-func (vs *viewState) setPing(err error) {
+
+// runWatch follows the door events from the mirror, watching again
+// whenever the watch ends.
+func (vs *viewState) runWatch(ctx context.Context, cl *client.Client) {
+	for {
+		err := vs.watch(ctx, cl)
+		if err != nil {
+			log.Println("watch", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (vs *viewState) watch(ctx context.Context, cl *client.Client) error {
+	doors, err := cl.Doors(ctx)
+	if err != nil {
+		return err
+	}
+	// The history has the last command sent to each door.
+	events, err := cl.History(ctx, "", 50)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wc, err := cl.Watch(ctx, "")
+	if err != nil {
+		return err
+	}
+
 	vs.mu.Lock()
-	vs.pingOk = err == nil
-	vs.connErr = err
+	if ctx.Err() != nil {
+		vs.mu.Unlock()
+		return ctx.Err()
+	}
+	vs.doors = make(doorViews)
+	for _, d := range doors {
+		dv := vs.doors.get(d.Name)
+		dv.online, dv.status = d.Online, d.Status
+	}
+	for _, ev := range events {
+		vs.doors.apply(ev)
+	}
+	vs.mu.Unlock()
+	vs.redraw()
+
+	for {
+		ev, err := wc.Recv()
+		if err != nil {
+			return err
+		}
+		vs.mu.Lock()
+		if ctx.Err() == nil {
+			vs.doors.apply(ev)
+		}
+		vs.mu.Unlock()
+		vs.redraw()
+	}
+}
+
+func (vs *viewState) setMirror(s client.State, err error) {
+	vs.mu.Lock()
+	vs.mirror = s
+	vs.mirrorErr = err
 	vs.mu.Unlock()
+	vs.redraw()
+	if err != nil {
+		log.Println(s, err)
+	}
+}
+
+func (vs *viewState) setCmdErr(err error) {
+	vs.mu.Lock()
+	vs.cmdErr = err
+	vs.cmdErrAt = time.Now()
+	vs.mu.Unlock()
+	vs.redraw()
 	if err != nil {
 		log.Println(err)
 	}
 }
 
+// startConn connects with the active profile until ctx is done,
+// replacing any earlier connection.
 func (vs *viewState) startConn(ctx context.Context) {
 	vs.mu.Lock()
-	vs.toggle = make(chan time.Time)
-	vs.pingOk = false
-	vs.connErr = nil
+	if vs.connCancel != nil {
+		vs.connCancel()
+	}
+	vs.appCtx = ctx
+	connCtx, cancel := context.WithCancel(ctx)
+	vs.connCancel = cancel
+	if vs.toggle == nil {
+		vs.toggle = make(chan toggleReq, 1)
+	}
+	vs.mirror = client.Connecting
+	vs.mirrorErr = nil
+	vs.doors = make(doorViews)
+	p := *vs.prefs.active()
 	vs.mu.Unlock()
+	vs.redraw()
 
-	go vs.runConn(ctx)
+	go vs.runConn(connCtx, p)
 }
 
 func (vs *viewState) start(glctx gl.Context) {
@@ -178,7 +354,9 @@ func (vs *viewState) touch(t touch.Event) {
 	vs.tc <- t
 }
 
-func (vs *viewState) draw(glctx gl.Context, sz size.Event) {
+// draw draws the view. It returns true while an animation needs
+// the next frame drawn right away.
+func (vs *viewState) draw(glctx gl.Context, sz size.Event) (animating bool) {
 	now := time.Now()
 	diff := now.Sub(vs.lastDraw)
 	vs.percentColor -= float32(diff.Seconds() * 0.5)
@@ -187,48 +365,189 @@ func (vs *viewState) draw(glctx gl.Context, sz size.Event) {
 	}
 	vs.lastDraw = now
 
-	select {
-	case change := <-vs.tc:
-		vs.touchChange = change
-	default:
+	for drained := false; !drained; {
+		select {
+		case t := <-vs.tc:
+			vs.touchList(t, sz)
+		default:
+			drained = true
+		}
 	}
-
-	switch vs.touchChange.Type {
-	case touch.TypeMove:
-	case touch.TypeBegin:
-		now := time.Now()
-		if vs.lastTouch.Add(time.Millisecond * 1000).Before(now) {
-			vs.lastTouch = now
-			vs.percentColor = 0.5
-			select {
-			default:
-			case vs.toggle <- now:
-			}
+	progress, done := vs.hold.progress(now)
+	if done {
+		vs.percentColor = 0.5
+		vs.mu.RLock()
+		req := toggleReq{at: now}
+		if d := vs.door(); d != nil {
+			req.door = d.name
+		}
+		vs.mu.RUnlock()
+		// One toggle waits while the last is sent. A hold beyond that
+		// is refused rather than silently dropped.
+		select {
+		case vs.toggle <- req:
+		default:
+			vs.setCmdErr(errors.New("Busy sending the last toggle, try again"))
 		}
-	case touch.TypeEnd:
 	}
+	vs.sq.SetProgress(progress)
 
-	var pingOk bool
-	var connErr error
 	vs.mu.RLock()
-	pingOk = vs.pingOk
-	connErr = vs.connErr
+	mirror, mirrorErr := vs.mirror, vs.mirrorErr
+	cmdErr := vs.cmdErr
+	if now.Sub(vs.cmdErrAt) > cmdErrShown {
+		cmdErr = nil
+	}
+	var door *doorView
+	if d := vs.door(); d != nil {
+		c := *d
+		door = &c
+	}
+	var rows []string
+	selected := -1
+	for i, item := range vs.shownItems() {
+		rows = append(rows, item.label)
+		if item.selected {
+			selected = i
+		}
+	}
+	settings, entry := vs.settings, vs.entry
+	settingsTitle, settingsLines := vs.settingsText()
+	if entry != entryNone {
+		settingsTitle, settingsLines = vs.entryText()
+	}
 	vs.mu.RUnlock()
 
+	// Green when the door can be operated, yellow when the mirror is
+	// reachable but the opener is not, red when the mirror is unreachable.
 	var r, g, b = vs.percentColor, vs.percentColor, vs.percentColor
-	if pingOk {
+	online := door != nil && door.online
+	switch {
+	case mirror == client.Connected && online:
 		r, b = 0, 0
-	} else {
+	case mirror == client.Connected || mirror == client.NoOpener:
+		b = 0
+	default:
 		g, b = 0, 0
 	}
 
 	glctx.ClearColor(r, g, b, 1)
 	glctx.Clear(gl.COLOR_BUFFER_BIT)
 
-	msg := "Tap to toggle garage door"
-	if connErr != nil {
-		msg = grpc.ErrorDesc(connErr)
+	title := "Garage door opener"
+	var lines []string
+	if door != nil {
+		title = door.title()
+		lines = append(lines, door.since(now), door.actor(now))
+	}
+	switch {
+	case mirror == client.Connecting:
+		lines = append(lines, "Connecting to the mirror")
+	case mirror == client.Disconnected:
+		lines = append(lines, "Mirror unreachable: "+grpc.ErrorDesc(mirrorErr))
+	case !online:
+		lines = append(lines, "Door opener offline")
+	case progress > 0:
+		lines = append(lines, "Keep holding to toggle")
+	case cmdErr != nil:
+		lines = append(lines, grpc.ErrorDesc(cmdErr))
+	case now.Sub(vs.hold.cancelled) < 2*time.Second:
+		lines = append(lines, "Released too soon, press and hold to toggle")
+	default:
+		lines = append(lines, "Press and hold to toggle garage door")
+	}
+
+	if settings {
+		title, lines = settingsTitle, settingsLines
+	}
+
+	vs.sq.Draw(glctx, sz, title, lines...)
+	if entry != entryNone {
+		vs.sq.DrawKeys(glctx, sz, entry.keys())
+	} else {
+		vs.sq.DrawList(glctx, sz, rows, selected)
+	}
+
+	// Draw again when a message expires or a shown time changes.
+	var next time.Duration
+	soonest := func(at time.Time) {
+		if d := at.Sub(now); d > 0 && (next == 0 || d < next) {
+			next = d
+		}
+	}
+	if cmdErr != nil {
+		soonest(vs.cmdErrAt.Add(cmdErrShown))
+	}
+	soonest(vs.hold.cancelled.Add(2 * time.Second))
+	if door != nil {
+		if d := door.nextChange(now); d > 0 {
+			soonest(now.Add(d))
+		}
+	}
+	if vs.tick != nil {
+		vs.tick.Stop()
+		vs.tick = nil
+	}
+	if next > 0 {
+		vs.tick = time.AfterFunc(next, vs.redraw)
+	}
+
+	return progress > 0 || vs.percentColor > .25
+}
+
+// touchList runs the action of a tapped list row or key. Other touches
+// go to the hold gesture on the main screen.
+func (vs *viewState) touchList(t touch.Event, sz size.Event) {
+	row := vs.sq.ListRow(sz, t.X, t.Y)
+	vs.mu.RLock()
+	items := vs.shownItems()
+	settings, entry := vs.settings, vs.entry
+	vs.mu.RUnlock()
+	if entry != entryNone {
+		vs.touchKeys(t, sz, entry.keys())
+		return
+	}
+	if row >= len(items) {
+		row = -1
 	}
 
-	vs.sq.Draw(glctx, sz, "Garage door opener", msg)
+	switch t.Type {
+	case touch.TypeBegin:
+		if row >= 0 {
+			vs.listTouch = row
+			return
+		}
+	case touch.TypeMove:
+		if vs.listTouch >= 0 {
+			return
+		}
+	case touch.TypeEnd:
+		if vs.listTouch >= 0 {
+			if row == vs.listTouch {
+				items[row].tap()
+			}
+			vs.listTouch = -1
+			return
+		}
+	}
+	if !settings {
+		vs.hold.event(t, time.Now())
+	}
+}
+
+// touchKeys presses a tapped key of the keypad.
+func (vs *viewState) touchKeys(t touch.Event, sz size.Event, keys []string) {
+	key := vs.sq.KeyAt(sz, t.X, t.Y)
+	if key >= len(keys) {
+		key = -1
+	}
+	switch t.Type {
+	case touch.TypeBegin:
+		vs.listTouch = key
+	case touch.TypeEnd:
+		if key >= 0 && key == vs.listTouch {
+			vs.pressKey(keys[key])
+		}
+		vs.listTouch = -1
+	}
 }
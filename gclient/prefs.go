@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/kardianos/garage/comm"
+	"github.com/kardianos/garage/comm/client"
+)
+
+// profile is a server the app can connect to.
+type profile struct {
+	Name string
+	// Addr is the server host:port. The compiled in mirror is used when empty.
+	Addr string
+	// CA is a PEM encoded CA certificate that signed the server certificate.
+	CA string
+	// Pin is the SHA-256 fingerprint of the server certificate. It is used
+	// instead of CA when set. The compiled in CA is used when neither is set.
+	Pin string
+	// Key is the auth key sent with every request.
+	Key string
+	// Cert and CertKey are the PEM encoded client certificate and its key.
+	Cert, CertKey string
+	// PairCode is a pairing code from an admin. The app enrolls with it,
+	// replacing any client certificate, then forgets it.
+	PairCode string
+	// Pass is a guest pass from an admin, sent instead of a certificate.
+	Pass string
+
+	// Door is the door last used on this server.
+	Door string
+}
+
+// config returns the client configuration for the profile.
+func (p *profile) config() client.Config {
+	return client.Config{
+		Addr:    p.Addr,
+		CA:      []byte(p.CA),
+		Pin:     p.Pin,
+		Key:     p.Key,
+		Cert:    []byte(p.Cert),
+		CertKey: []byte(p.CertKey),
+		Pass:    p.Pass,
+	}
+}
+
+// defaultProfile connects to the compiled in mirror.
+func defaultProfile() profile {
+	return profile{Name: "default", Key: comm.AuthKey()}
+}
+
+// prefs are the app settings kept between launches.
+type prefs struct {
+	// Profile is the name of the profile in use.
+	Profile  string
+	Profiles []profile
+}
+
+// active returns the profile in use, adding the default profile
+// if there are none.
+func (p *prefs) active() *profile {
+	if len(p.Profiles) == 0 {
+		p.Profiles = []profile{defaultProfile()}
+	}
+	for i := range p.Profiles {
+		if p.Profiles[i].Name == p.Profile {
+			return &p.Profiles[i]
+		}
+	}
+	p.Profile = p.Profiles[0].Name
+	return &p.Profiles[0]
+}
+
+// importProfiles adds or replaces the profiles in the import file,
+// matching them by name. It returns how many profiles were imported.
+// The file holds keys, so it is removed once imported. A file that can
+// not be imported is left to be fixed.
+func (p *prefs) importProfiles() (int, error) {
+	b, err := ioutil.ReadFile(importPath())
+	if err != nil {
+		return 0, err
+	}
+	var list []profile
+	err = json.Unmarshal(b, &list)
+	if err != nil {
+		return 0, err
+	}
+	for _, np := range list {
+		if len(np.Name) == 0 {
+			return 0, errors.New("every profile needs a Name")
+		}
+		if _, err := np.config().DialOptions(); err != nil {
+			return 0, err
+		}
+	}
+	err = os.Remove(importPath())
+	if err != nil {
+		return 0, err
+	}
+	p.active()
+	for _, np := range list {
+		found := false
+		for i := range p.Profiles {
+			if p.Profiles[i].Name == np.Name {
+				np.Door = p.Profiles[i].Door
+				if len(np.Cert) == 0 && len(np.PairCode) == 0 {
+					np.Cert, np.CertKey = p.Profiles[i].Cert, p.Profiles[i].CertKey
+				}
+				p.Profiles[i] = np
+				found = true
+				break
+			}
+		}
+		if !found {
+			p.Profiles = append(p.Profiles, np)
+		}
+	}
+	return len(list), nil
+}
+
+// prefsDir returns the directory the app keeps its settings in.
+func prefsDir() string {
+	if runtime.GOOS == "android" {
+		// TMPDIR is the app cache directory, next to its files directory.
+		return filepath.Join(filepath.Dir(os.TempDir()), "files")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config", "garage")
+}
+
+func prefsPath() string {
+	return filepath.Join(prefsDir(), "prefs.json")
+}
+
+// importPath is where profiles are imported from, as a JSON list of
+// profiles. It is for servers that need more than an address and a
+// pairing code, such as another CA or a pinned certificate, and is
+// written there with adb.
+func importPath() string {
+	return filepath.Join(prefsDir(), "profiles.json")
+}
+
+// loadPrefs reads the saved settings. Missing settings are left empty.
+func loadPrefs() (prefs, error) {
+	var p prefs
+	b, err := ioutil.ReadFile(prefsPath())
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(b, &p)
+	return p, err
+}
+
+// save writes the settings, replacing the file only once fully written.
+func (p prefs) save() error {
+	err := os.MkdirAll(prefsDir(), 0700)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := prefsPath() + ".tmp"
+	err = ioutil.WriteFile(tmp, b, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, prefsPath())
+}
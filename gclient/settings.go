@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+	"github.com/kardianos/garage/comm/client"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// listItem is a row of the list below the square.
+type listItem struct {
+	label    string
+	selected bool
+	// tap is called when the row is tapped.
+	tap func()
+}
+
+// listItems returns the rows of the list for the screen shown.
+// The view must be locked.
+func (vs *viewState) listItems() []listItem {
+	var items []listItem
+	if vs.settings {
+		active := vs.prefs.active().Name
+		for _, p := range vs.prefs.Profiles {
+			name := p.Name
+			items = append(items, listItem{
+				label:    "Profile " + name,
+				selected: name == active,
+				tap:      func() { vs.switchProfile(name) },
+			})
+		}
+		return append(items,
+			listItem{label: "New profile", tap: vs.newProfile},
+			listItem{label: "Edit server address", tap: vs.editAddr},
+			listItem{label: "Enter pairing code", tap: func() { vs.showEntry(entryPairCode, "") }},
+			listItem{label: "Import profiles file", tap: vs.importProfiles},
+			listItem{label: "Back", tap: func() { vs.showSettings(false) }},
+		)
+	}
+
+	// The door list is shown once there is a choice.
+	if names := vs.doors.names(); len(names) > 1 {
+		current := vs.door()
+		for _, name := range names {
+			d := vs.doors[name]
+			label := d.title()
+			if !d.online {
+				label += " (offline)"
+			}
+			items = append(items, listItem{
+				label:    label,
+				selected: current != nil && current.name == name,
+				tap:      func() { vs.selectDoor(name) },
+			})
+		}
+	}
+	return append(items, listItem{label: "Settings", tap: func() { vs.showSettings(true) }})
+}
+
+// shownItems returns the rows of the list that fit below the square.
+// When there are too many, the last row, Settings or Back, is kept on
+// every page and a More row turns to the next page. The view must be
+// locked.
+func (vs *viewState) shownItems() []listItem {
+	items := vs.listItems()
+	if len(items) <= listRows {
+		return items
+	}
+	last := items[len(items)-1]
+	items = items[:len(items)-1]
+	per := listRows - 2
+	pages := (len(items) + per - 1) / per
+	page := vs.listPage % pages
+	items = items[page*per:]
+	if len(items) > per {
+		items = items[:per]
+	}
+	more := listItem{
+		label: fmt.Sprintf("More (page %d of %d)", page+1, pages),
+		tap: func() {
+			vs.mu.Lock()
+			vs.listPage = page + 1
+			vs.mu.Unlock()
+			vs.redraw()
+		},
+	}
+	return append(items, more, last)
+}
+
+// profileAddr returns the server address of p for display.
+func profileAddr(p *profile) string {
+	if len(p.Addr) == 0 {
+		return fmt.Sprintf("%s:%d (built in)", comm.Host(), comm.Port())
+	}
+	return p.Addr
+}
+
+// settingsText describes the active profile. The view must be locked.
+func (vs *viewState) settingsText() (title string, lines []string) {
+	p := vs.prefs.active()
+	trust := "built in CA"
+	switch {
+	case len(p.Pin) != 0:
+		trust = "pinned certificate " + p.Pin
+	case len(p.CA) != 0:
+		trust = "profile CA"
+	}
+	key := "no auth key"
+	if len(p.Key) != 0 {
+		key = "auth key set"
+	}
+	switch {
+	case len(p.PairCode) != 0:
+		key += ", enrolling"
+	case len(p.Cert) != 0:
+		key += ", enrolled"
+	}
+	if len(p.Pass) != 0 {
+		key += ", guest pass"
+	}
+	notice := vs.notice
+	if len(notice) == 0 {
+		notice = "Enter a pairing code from an admin to enroll"
+	}
+	return "Settings: " + p.Name, []string{"Server " + profileAddr(p), "Trust " + trust + ", " + key, notice}
+}
+
+func (vs *viewState) showSettings(show bool) {
+	vs.mu.Lock()
+	vs.settings = show
+	vs.entry = entryNone
+	vs.entered = ""
+	vs.listPage = 0
+	vs.notice = ""
+	vs.mu.Unlock()
+	vs.redraw()
+}
+
+// savePrefs queues a copy of the settings to be written by runSaver.
+// The view must be locked.
+func (vs *viewState) savePrefs() {
+	p := vs.prefs
+	p.Profiles = append([]profile(nil), p.Profiles...)
+	vs.unsaved = &p
+	select {
+	case vs.saves <- struct{}{}:
+	default:
+	}
+}
+
+// runSaver writes the settings each time they are saved. Only the latest
+// settings are written, one save at a time, so an older save never
+// replaces a newer one.
+func (vs *viewState) runSaver() {
+	for range vs.saves {
+		vs.mu.Lock()
+		p := vs.unsaved
+		vs.unsaved = nil
+		vs.mu.Unlock()
+		if p == nil {
+			continue
+		}
+		err := p.save()
+		if err != nil {
+			log.Println("save prefs", err)
+		}
+	}
+}
+
+// enroll exchanges the pairing code of p for a client certificate and
+// saves it in the profile. It retries while the mirror is unreachable.
+// A code the mirror refuses is forgotten, as it will not work again.
+func (vs *viewState) enroll(ctx context.Context, p profile) (profile, error) {
+	vs.setMirror(client.Connecting, nil)
+	addr, code, err := client.ParsePairing(p.PairCode)
+	if err != nil {
+		return p, err
+	}
+	config := p.config()
+	if len(addr) != 0 {
+		config.Addr = addr
+	}
+	wait := time.Second
+	for {
+		e, err := client.Enroll(ctx, config, code)
+		if err == nil {
+			p.Cert, p.CertKey, p.PairCode = string(e.Cert), string(e.CertKey), ""
+			break
+		}
+		switch grpc.Code(err) {
+		default:
+			vs.forgetPairCode(p.Name)
+			return p, err
+		case codes.Unavailable, codes.DeadlineExceeded:
+		}
+		log.Println("enroll", err)
+		select {
+		case <-ctx.Done():
+			return p, ctx.Err()
+		case <-time.After(wait):
+		}
+		if wait < time.Minute {
+			wait *= 2
+		}
+	}
+
+	vs.mu.Lock()
+	for i := range vs.prefs.Profiles {
+		if vs.prefs.Profiles[i].Name == p.Name {
+			vs.prefs.Profiles[i] = p
+		}
+	}
+	vs.savePrefs()
+	vs.mu.Unlock()
+	return p, nil
+}
+
+// forgetPairCode removes the pairing code from the named profile.
+func (vs *viewState) forgetPairCode(name string) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	for i := range vs.prefs.Profiles {
+		if vs.prefs.Profiles[i].Name == name {
+			vs.prefs.Profiles[i].PairCode = ""
+		}
+	}
+	vs.savePrefs()
+}
+
+// switchProfile connects with the named profile.
+func (vs *viewState) switchProfile(name string) {
+	vs.mu.Lock()
+	if vs.prefs.active().Name == name {
+		vs.mu.Unlock()
+		return
+	}
+	vs.prefs.Profile = name
+	vs.notice = "Switched to " + name
+	vs.savePrefs()
+	vs.mu.Unlock()
+
+	vs.startConn(vs.appCtx)
+}
+
+// newProfile adds a profile for the built in server, switches to it and
+// asks for its server address.
+func (vs *viewState) newProfile() {
+	vs.mu.Lock()
+	p := defaultProfile()
+	taken := make(map[string]bool, len(vs.prefs.Profiles))
+	for _, o := range vs.prefs.Profiles {
+		taken[o.Name] = true
+	}
+	for i := len(vs.prefs.Profiles) + 1; ; i++ {
+		p.Name = fmt.Sprintf("server%d", i)
+		if !taken[p.Name] {
+			break
+		}
+	}
+	vs.prefs.active()
+	vs.prefs.Profiles = append(vs.prefs.Profiles, p)
+	vs.prefs.Profile = p.Name
+	vs.savePrefs()
+	vs.mu.Unlock()
+
+	vs.startConn(vs.appCtx)
+	vs.showEntry(entryAddr, "")
+}
+
+// importProfiles adds the profiles from the import file, which is then
+// removed, and reconnects in case the active profile changed.
+func (vs *viewState) importProfiles() {
+	vs.mu.Lock()
+	n, err := vs.prefs.importProfiles()
+	if err != nil {
+		vs.notice = "Import failed: " + err.Error()
+		vs.mu.Unlock()
+		vs.redraw()
+		return
+	}
+	vs.notice = fmt.Sprintf("Imported %d profiles", n)
+	vs.savePrefs()
+	vs.mu.Unlock()
+
+	vs.startConn(vs.appCtx)
+}
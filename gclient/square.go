@@ -5,7 +5,9 @@
 package main
 
 import (
+	"fmt"
 	"image"
+	"strings"
 
 	"golang.org/x/mobile/event/size"
 	"golang.org/x/mobile/exp/font"
@@ -21,17 +23,28 @@ import (
 )
 
 type Square struct {
+	// percent of the progress bar to fill, from 0 to 1.
 	percent float32
 
 	images *glutil.Images
 	img1   *glutil.Image
+	// list is drawn below img1 for the door picker.
+	list *glutil.Image
+
+	// text holds the rasterized strings of img1 until they change.
+	text    *image.RGBA
+	textKey string
+	// uploaded and listKey describe what was last uploaded to img1 and list.
+	uploaded string
+	listKey  string
 
 	ftctx *freetype.Context
 }
 
 func NewSquare(glctx gl.Context, inc, x, y float32) (*Square, error) {
 	images := glutil.NewImages(glctx)
-	img1 := images.NewImage(1600, 200)
+	img1 := images.NewImage(1600, 400)
+	list := images.NewImage(1600, listRows*listRowHeight)
 
 	ftctx := freetype.NewContext()
 	ftFont, err := freetype.ParseFont(font.Default())
@@ -49,6 +62,8 @@ func NewSquare(glctx gl.Context, inc, x, y float32) (*Square, error) {
 	sq := &Square{
 		images: images,
 		img1:   img1,
+		list:   list,
+		text:   image.NewRGBA(img1.RGBA.Bounds()),
 		ftctx:  ftctx,
 	}
 	return sq, nil
@@ -60,28 +75,171 @@ func (sq *Square) Close(glctx gl.Context) {
 	}
 
 	sq.img1.Release()
+	sq.list.Release()
 	sq.images.Release()
 }
 
-func (sq *Square) Draw(glctx gl.Context, sz size.Event, major, minor string) {
+// Draw draws the major and minor strings. The strings are only
+// rasterized again when they change.
+func (sq *Square) Draw(glctx gl.Context, sz size.Event, major string, minor ...string) {
 	if sq == nil {
 		return
 	}
-	draw.Copy(sq.img1.RGBA, image.ZP, image.NewUniform(colornames.Map["deepskyblue"]), sq.img1.RGBA.Bounds(), draw.Src, nil)
+	b := sq.img1.RGBA.Bounds()
 
-	ftctx := sq.ftctx
+	key := strings.Join(append([]string{major}, minor...), "\n")
+	if key != sq.textKey {
+		sq.textKey = key
+		draw.Copy(sq.text, image.ZP, image.NewUniform(colornames.Map["deepskyblue"]), b, draw.Src, nil)
 
-	ftctx.SetFontSize(12)
-	ftctx.DrawString(major, fixed.Point26_6{X: 100, Y: 7000})
+		ftctx := sq.ftctx
+		ftctx.SetDst(sq.text)
+		ftctx.SetClip(b)
 
-	ftctx.SetFontSize(6)
-	ftctx.DrawString(minor, fixed.Point26_6{X: 100, Y: 11000})
+		ftctx.SetFontSize(12)
+		ftctx.DrawString(major, fixed.Point26_6{X: 100, Y: 7000})
 
-	b := sq.img1.RGBA.Bounds()
+		ftctx.SetFontSize(6)
+		for i, line := range minor {
+			ftctx.DrawString(line, fixed.Point26_6{X: 100, Y: fixed.Int26_6(11000 + i*4000)})
+		}
+	}
 
-	sq.img1.Upload()
+	uploaded := fmt.Sprintf("%s\n%.3f", key, sq.percent)
+	if uploaded != sq.uploaded {
+		sq.uploaded = uploaded
+		draw.Copy(sq.img1.RGBA, image.ZP, sq.text, b, draw.Src, nil)
+		if sq.percent > 0 {
+			bar := image.Rect(0, b.Max.Y-40, int(float32(b.Max.X)*sq.percent), b.Max.Y)
+			draw.Copy(sq.img1.RGBA, bar.Min, image.NewUniform(colornames.Map["orange"]), bar, draw.Src, nil)
+		}
+		sq.img1.Upload()
+	}
 	sq.img1.Draw(sz, geom.Point{X: 0, Y: 0}, geom.Point{X: geom.Pt(b.Max.X) / 4, Y: 0}, geom.Point{X: 0, Y: geom.Pt(b.Max.Y) / 4}, sq.img1.RGBA.Bounds())
 }
+
+// listRows is the most rows the list shows. Longer lists are paged by
+// the view.
+const listRows = 6
+
+// listRowHeight is the height of a list row in image pixels.
+const listRowHeight = 160
+
+// DrawList draws rows below the square, highlighting the selected row.
+// Nothing is drawn when rows is empty.
+func (sq *Square) DrawList(glctx gl.Context, sz size.Event, rows []string, selected int) {
+	if sq == nil || len(rows) == 0 {
+		return
+	}
+	if len(rows) > listRows {
+		rows = rows[:listRows]
+	}
+	img := sq.list.RGBA
+	b := img.Bounds()
+	top := geom.Pt(sq.img1.RGBA.Bounds().Max.Y) / 4
+	defer sq.list.Draw(sz, geom.Point{X: 0, Y: top}, geom.Point{X: geom.Pt(b.Max.X) / 4, Y: top}, geom.Point{X: 0, Y: top + geom.Pt(b.Max.Y)/4}, b)
+
+	key := fmt.Sprintf("%d\n%s", selected, strings.Join(rows, "\n"))
+	if key == sq.listKey {
+		return
+	}
+	sq.listKey = key
+	draw.Copy(img, image.ZP, image.NewUniform(colornames.Map["lightgray"]), b, draw.Src, nil)
+
+	ftctx := sq.ftctx
+	ftctx.SetDst(img)
+	ftctx.SetClip(b)
+	ftctx.SetFontSize(7)
+	for i, row := range rows {
+		r := image.Rect(0, i*listRowHeight, b.Max.X, (i+1)*listRowHeight-8)
+		bg := "white"
+		if i == selected {
+			bg = "deepskyblue"
+		}
+		draw.Copy(img, r.Min, image.NewUniform(colornames.Map[bg]), r, draw.Src, nil)
+		ftctx.DrawString(row, fixed.Point26_6{X: 100, Y: fixed.Int26_6((i*listRowHeight + 110) * 64)})
+	}
+
+	sq.list.Upload()
+}
+
+// keyColumns is how many keys a row of the keypad has.
+const keyColumns = 8
+
+// DrawKeys draws a keypad in place of the list, keyColumns keys to a row.
+func (sq *Square) DrawKeys(glctx gl.Context, sz size.Event, keys []string) {
+	if sq == nil {
+		return
+	}
+	img := sq.list.RGBA
+	b := img.Bounds()
+	top := geom.Pt(sq.img1.RGBA.Bounds().Max.Y) / 4
+	defer sq.list.Draw(sz, geom.Point{X: 0, Y: top}, geom.Point{X: geom.Pt(b.Max.X) / 4, Y: top}, geom.Point{X: 0, Y: top + geom.Pt(b.Max.Y)/4}, b)
+
+	key := "keys\n" + strings.Join(keys, "\n")
+	if key == sq.listKey {
+		return
+	}
+	sq.listKey = key
+	draw.Copy(img, image.ZP, image.NewUniform(colornames.Map["lightgray"]), b, draw.Src, nil)
+
+	ftctx := sq.ftctx
+	ftctx.SetDst(img)
+	ftctx.SetClip(b)
+	ftctx.SetFontSize(7)
+	w := b.Max.X / keyColumns
+	for i, k := range keys {
+		x, y := i%keyColumns*w, i/keyColumns*listRowHeight
+		r := image.Rect(x, y, x+w-8, y+listRowHeight-8)
+		draw.Copy(img, r.Min, image.NewUniform(colornames.Map["white"]), r, draw.Src, nil)
+		ftctx.DrawString(k, fixed.Point26_6{X: fixed.Int26_6((x + 40) * 64), Y: fixed.Int26_6((y + 110) * 64)})
+	}
+
+	sq.list.Upload()
+}
+
+// KeyAt returns the index of the keypad key at the touch location x, y in
+// pixels, or -1 if the location is not on the keypad.
+func (sq *Square) KeyAt(sz size.Event, x, y float32) int {
+	row := sq.ListRow(sz, x, y)
+	if row < 0 {
+		return -1
+	}
+	w := geom.Pt(sq.list.RGBA.Bounds().Max.X/keyColumns) / 4
+	col := int(geom.Pt(x/sz.PixelsPerPt) / w)
+	if col >= keyColumns {
+		col = keyColumns - 1
+	}
+	return row*keyColumns + col
+}
+
+// ListRow returns the list row at the touch location x, y in pixels,
+// or -1 if the location is not on the list.
+func (sq *Square) ListRow(sz size.Event, x, y float32) int {
+	if sq == nil || sz.PixelsPerPt == 0 {
+		return -1
+	}
+	xPt, yPt := geom.Pt(x/sz.PixelsPerPt), geom.Pt(y/sz.PixelsPerPt)
+	top := geom.Pt(sq.img1.RGBA.Bounds().Max.Y) / 4
+	b := sq.list.RGBA.Bounds()
+	if xPt < 0 || xPt > geom.Pt(b.Max.X)/4 || yPt < top || yPt >= top+geom.Pt(b.Max.Y)/4 {
+		return -1
+	}
+	return int((yPt - top) / (listRowHeight / 4))
+}
+
+// SetProgress sets how much of the progress bar is filled, from 0 to 1.
+// The bar is hidden at 0.
+func (sq *Square) SetProgress(p float32) {
+	if sq == nil {
+		return
+	}
+	if p > 1 {
+		p = 1
+	}
+	sq.percent = p
+}
+
 func (sq *Square) SetLocation(x, y float32) {
 	// sq.x, sq.y = x, y
 }
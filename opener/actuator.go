@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Actuator drives the output connected to the door button.
+type Actuator interface {
+	// Set drives the output active (button pressed) or inactive.
+	Set(active bool) error
+	Close() error
+}
+
+type actuatorFactory func(c *Config) (Actuator, error)
+
+var actuators = map[string]actuatorFactory{}
+
+// registerActuator makes an actuator driver available by name.
+// It should be called from an init function.
+func registerActuator(name string, f actuatorFactory) {
+	if _, found := actuators[name]; found {
+		panic("actuator driver registered twice: " + name)
+	}
+	actuators[name] = f
+}
+
+// openActuator opens the actuator driver selected in the configuration.
+func openActuator(c *Config) (Actuator, error) {
+	f, found := actuators[c.Actuator.Driver]
+	if !found {
+		return nil, fmt.Errorf("unknown actuator driver %q, available drivers: %q", c.Actuator.Driver, actuatorNames())
+	}
+	act, err := f(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s actuator: %v", c.Actuator.Driver, err)
+	}
+	return act, nil
+}
+
+func actuatorNames() []string {
+	names := make([]string, 0, len(actuators))
+	for name := range actuators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
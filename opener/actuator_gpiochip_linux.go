@@ -0,0 +1,57 @@
+package main
+
+func init() {
+	registerActuator("gpiochip", openGPIOChipActuator)
+}
+
+// gpiochipActuator drives the relay through a GPIO character device line.
+// Polarity is handled by the kernel, so active is always a logical 1.
+type gpiochipActuator struct {
+	lines gpioLines
+}
+
+func openGPIOChipActuator(c *Config) (Actuator, error) {
+	ac := c.Actuator
+	bias, err := gpioBiasFlags(ac.Bias)
+	if err != nil {
+		return nil, err
+	}
+	flags := gpioV2LineFlagOutput | bias
+	if ac.ActiveLow {
+		flags |= gpioV2LineFlagActiveLow
+	}
+	req := newLineRequest(ac.Pin, flags)
+	var initial uint64
+	if ac.Initial {
+		initial = 1
+	}
+	err = req.Config.addAttr(gpioV2LineAttrIDValues, initial, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	chip, err := openGPIOChip(ac.Chip)
+	if err != nil {
+		return nil, err
+	}
+	// The requested lines stay valid after the chip is closed.
+	defer chip.Close()
+
+	lines, err := chip.RequestLines(req)
+	if err != nil {
+		return nil, err
+	}
+	return &gpiochipActuator{lines: lines}, nil
+}
+
+func (a *gpiochipActuator) Set(active bool) error {
+	v := &gpioV2LineValues{Mask: 1}
+	if active {
+		v.Bits = 1
+	}
+	return a.lines.SetValues(v)
+}
+
+func (a *gpiochipActuator) Close() error {
+	return a.lines.Close()
+}
@@ -0,0 +1,21 @@
+package main
+
+func init() {
+	registerActuator("log", func(c *Config) (Actuator, error) {
+		return logActuator{}, nil
+	})
+}
+
+// logActuator logs door presses and drives no hardware.
+type logActuator struct{}
+
+func (logActuator) Set(active bool) error {
+	if active {
+		logger.Info("toggle door")
+	}
+	return nil
+}
+
+func (logActuator) Close() error {
+	return nil
+}
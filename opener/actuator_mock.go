@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+func init() {
+	registerActuator("mock", func(c *Config) (Actuator, error) {
+		return &mockActuator{}, nil
+	})
+}
+
+// actuation is a single output change recorded by mockActuator.
+type actuation struct {
+	At     time.Time
+	Active bool
+}
+
+// mockActuator records every output change so the toggle pipeline
+// can be inspected without hardware.
+type mockActuator struct {
+	mu     sync.Mutex
+	closed bool
+	events []actuation
+}
+
+func (a *mockActuator) Set(active bool) error {
+	a.mu.Lock()
+	a.events = append(a.events, actuation{At: time.Now(), Active: active})
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *mockActuator) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+	return nil
+}
+
+// Events returns a copy of the recorded output changes.
+func (a *mockActuator) Events() []actuation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]actuation(nil), a.events...)
+}
+
+// Presses returns the number of times the output was driven active.
+func (a *mockActuator) Presses() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	for _, e := range a.events {
+		if e.Active {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/davecheney/gpio"
+)
+
+func init() {
+	registerActuator("sysfs", openSysfsActuator)
+}
+
+// sysfsActuator drives the relay through the sysfs GPIO interface.
+type sysfsActuator struct {
+	pin       gpio.Pin
+	activeLow bool
+}
+
+func openSysfsActuator(c *Config) (Actuator, error) {
+	pin, err := gpio.OpenPin(c.Actuator.Pin, gpio.ModeOutput)
+	if err != nil {
+		return nil, err
+	}
+	a := &sysfsActuator{pin: pin, activeLow: c.Actuator.ActiveLow}
+	if err = a.Set(false); err != nil {
+		pin.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *sysfsActuator) Set(active bool) error {
+	if active != a.activeLow {
+		a.pin.Set()
+	} else {
+		a.pin.Clear()
+	}
+	return a.pin.Err()
+}
+
+func (a *sysfsActuator) Close() error {
+	return a.pin.Close()
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+)
+
+// Config is the opener configuration, read from a JSON file.
+type Config struct {
+	Actuator ActuatorConfig
+	Sensors  SensorConfig
+	Door     DoorConfig
+
+	// Safety are inputs that block closing the door while active, such as
+	// a photo-eye or a switch for a person in the garage. They are read
+	// with the sensor driver.
+	Safety []SafetyConfig
+
+	Mirror   MirrorConfig
+	Local    LocalConfig
+	Watchdog WatchdogConfig
+
+	// Sim configures the simulated door used by the "sim" drivers.
+	Sim SimConfig
+}
+
+// ActuatorConfig selects and configures the relay driver.
+type ActuatorConfig struct {
+	// Driver is the name of a registered actuator driver,
+	// such as "sysfs", "gpiochip", "log" or "mock".
+	Driver string
+
+	// Pin is the GPIO number for the sysfs driver and
+	// the line offset on Chip for the gpiochip driver.
+	Pin int
+	// ActiveLow is set when the relay is energized by a low output.
+	ActiveLow bool
+
+	// PulseWidth is how long the button is held down.
+	PulseWidth Duration
+	// Gap is how long to wait after releasing the button before
+	// it may be pressed again.
+	Gap Duration
+
+	// The following settings are only used by the gpiochip driver.
+
+	// Chip is the GPIO character device, such as "gpiochip0".
+	Chip string
+	// Bias is one of "as-is", "pull-up", "pull-down" or "disabled".
+	Bias string
+	// Initial is the logical value the line is set to when requested.
+	// It must be false: an active relay presses the door button.
+	Initial bool
+}
+
+// SensorConfig configures the door position switches.
+type SensorConfig struct {
+	// Driver is the name of a registered input driver, such as "sysfs",
+	// "gpiochip" or "mock". Leave it empty when no switches are fitted.
+	Driver string
+	// Chip is the GPIO character device used by the gpiochip driver.
+	Chip string
+	// Debounce is how long an input must be stable before a change is reported.
+	Debounce Duration
+
+	// Closed is the switch that is active when the door is fully closed.
+	Closed *InputConfig
+	// Open is an optional switch that is active when the door is fully open.
+	Open *InputConfig
+}
+
+// InputConfig configures a single input pin.
+type InputConfig struct {
+	Pin       int
+	ActiveLow bool
+	// Bias is one of "as-is", "pull-up", "pull-down" or "disabled".
+	// Only the gpiochip driver can set a bias.
+	Bias string
+}
+
+// SafetyConfig configures a safety input.
+type SafetyConfig struct {
+	// Name identifies the input in refusals, such as "photo-eye".
+	Name string
+	InputConfig
+}
+
+// MirrorConfig configures the connection to the mirror.
+type MirrorConfig struct {
+	// Cert and Key are PEM files for the client certificate of the opener.
+	// No client certificate is sent when they are empty.
+	Cert, Key string
+	// PairCode is a pairing code, or pairing URI, from an admin. When Cert
+	// does not exist yet the opener enrolls with it and writes Cert and Key.
+	PairCode string
+}
+
+// LocalConfig configures the local API, which serves the door on the
+// local network for when the mirror is unreachable.
+type LocalConfig struct {
+	// Listen is the address to serve on, such as ":8443".
+	// The local API is disabled when it is empty.
+	Listen string
+	// Cert and Key are PEM files for the server certificate.
+	// The compiled in certificate is used when they are empty.
+	Cert, Key string
+	// AuthKey must be sent by clients with every request.
+	// The compiled in auth key is used when it is empty.
+	AuthKey string
+	// CA is a PEM file for the CA that signs client certificates, which
+	// clients must send. The compiled in CA is used when it is empty.
+	CA string
+}
+
+// WatchdogConfig configures the hardware watchdog device. The systemd
+// watchdog is used whenever systemd asks for it and needs no configuration.
+type WatchdogConfig struct {
+	// Device is the watchdog device, such as "/dev/watchdog".
+	// The hardware watchdog is not used when it is empty.
+	Device string
+	// Interval is how often the device is written to while the opener is
+	// healthy. It must be shorter than the device timeout.
+	Interval Duration
+	// StreamTimeout is how long the mirror stream may go without a
+	// successful send before it is reported as down in the systemd status
+	// and the watchdogs are no longer fed, restarting the device.
+	StreamTimeout Duration
+}
+
+// SimConfig configures a simulated door for development without a garage.
+// Select it with the "sim" actuator and sensor drivers, for example:
+//
+//	{
+//		"Actuator": {"Driver": "sim"},
+//		"Sensors": {"Driver": "sim", "Closed": {"Pin": 1}, "Open": {"Pin": 2}},
+//		"Safety": [{"Name": "photo-eye", "Pin": 3}],
+//		"Sim": {"TravelTime": "12s", "Obstruction": 0.2, "SensorFailure": 0.1}
+//	}
+type SimConfig struct {
+	// TravelTime is how long the simulated door takes to fully open or close.
+	TravelTime Duration
+	// Bounce is how long a switch bounces after it changes.
+	Bounce Duration
+	// Obstruction is the chance, from 0 to 1, that closing is obstructed.
+	// The door then reverses and the first safety input is active for
+	// ObstructionTime.
+	Obstruction     float64
+	ObstructionTime Duration
+	// SensorFailure is the chance, from 0 to 1, that the switch at the
+	// end of a travel fails to report.
+	SensorFailure float64
+	// Open starts the door open rather than closed.
+	Open bool
+	// Seed makes the random events repeatable. Zero uses the time.
+	Seed int64
+}
+
+// DoorConfig describes the door itself.
+type DoorConfig struct {
+	// Name identifies the door to the mirror and its clients.
+	Name string
+	// TravelTime is the longest time the door takes to fully open or close.
+	// It is used to infer the door motion. Zero reports the switch
+	// levels only.
+	TravelTime Duration
+}
+
+// Duration is a time.Duration written as a string such as "300ms" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return fmt.Errorf("duration must be a string such as \"300ms\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// defaultActuatorDriver returns the sysfs driver on Linux, where the
+// opener drives a relay, and the log driver elsewhere.
+func defaultActuatorDriver() string {
+	if runtime.GOOS == "linux" {
+		return "sysfs"
+	}
+	return "log"
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Actuator: ActuatorConfig{
+			Driver:     defaultActuatorDriver(),
+			Pin:        17,
+			ActiveLow:  true,
+			PulseWidth: Duration(300 * time.Millisecond),
+			Gap:        Duration(300 * time.Millisecond),
+			Chip:       "gpiochip0",
+		},
+		Sensors: SensorConfig{
+			Chip:     "gpiochip0",
+			Debounce: Duration(50 * time.Millisecond),
+		},
+		Door: DoorConfig{
+			Name:       "garage",
+			TravelTime: Duration(15 * time.Second),
+		},
+		Sim: SimConfig{
+			TravelTime:      Duration(12 * time.Second),
+			Bounce:          Duration(20 * time.Millisecond),
+			ObstructionTime: Duration(3 * time.Second),
+		},
+		Watchdog: WatchdogConfig{
+			Interval:      Duration(5 * time.Second),
+			StreamTimeout: Duration(5 * time.Minute),
+		},
+	}
+}
+
+// loadConfig reads the configuration file at name on top of the defaults
+// and validates the result. An empty name uses only the defaults.
+func loadConfig(name string) (*Config, error) {
+	c := defaultConfig()
+	if len(name) != 0 {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		err = json.NewDecoder(f).Decode(c)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode config %q: %v", name, err)
+		}
+	}
+	err := c.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %q: %v", name, err)
+	}
+	return c, nil
+}
+
+func (c *Config) validate() error {
+	ac := c.Actuator
+	if _, found := actuators[ac.Driver]; !found {
+		return fmt.Errorf("Actuator.Driver: unknown driver %q, available drivers: %q", ac.Driver, actuatorNames())
+	}
+	if ac.Pin < 0 {
+		return fmt.Errorf("Actuator.Pin: %d is negative", ac.Pin)
+	}
+	if d := time.Duration(ac.PulseWidth); d < 50*time.Millisecond || d > 5*time.Second {
+		return fmt.Errorf("Actuator.PulseWidth: %v is not between 50ms and 5s", d)
+	}
+	if d := time.Duration(ac.Gap); d < 0 || d > 10*time.Second {
+		return fmt.Errorf("Actuator.Gap: %v is not between 0s and 10s", d)
+	}
+	err := validateBias("Actuator.Bias", ac.Bias)
+	if err != nil {
+		return err
+	}
+	if ac.Initial {
+		return fmt.Errorf("Actuator.Initial: an active relay would press the door button each time the opener starts")
+	}
+	if len(c.Door.Name) == 0 {
+		return fmt.Errorf("Door.Name: missing")
+	}
+	if d := time.Duration(c.Door.TravelTime); d < 0 || d > 2*time.Minute {
+		return fmt.Errorf("Door.TravelTime: %v is not between 0s and 2m", d)
+	}
+
+	if lc := c.Local; len(lc.Listen) != 0 {
+		if (len(lc.Cert) == 0) != (len(lc.Key) == 0) {
+			return fmt.Errorf("Local.Cert, Local.Key: both or neither must be set")
+		}
+		if len(lc.AuthKey) == 0 && len(comm.AuthKey()) == 0 {
+			return fmt.Errorf("Local.AuthKey: required when no auth key is compiled in")
+		}
+	}
+
+	if ac.Driver == "sim" || c.Sensors.Driver == "sim" {
+		err = c.Sim.validate()
+		if err != nil {
+			return err
+		}
+		if c.Sensors.Driver == "sim" && ac.Driver != "sim" {
+			return fmt.Errorf("Sensors.Driver: the sim driver needs the sim actuator to move the door")
+		}
+	}
+
+	if mc := c.Mirror; (len(mc.Cert) == 0) != (len(mc.Key) == 0) {
+		return fmt.Errorf("Mirror.Cert and Mirror.Key: must be set together")
+	} else if len(mc.PairCode) != 0 && len(mc.Cert) == 0 {
+		return fmt.Errorf("Mirror.PairCode: needs Mirror.Cert and Mirror.Key to write the certificate to")
+	}
+
+	if wc := c.Watchdog; len(wc.Device) != 0 {
+		if d := time.Duration(wc.Interval); d < 100*time.Millisecond || d > time.Minute {
+			return fmt.Errorf("Watchdog.Interval: %v is not between 100ms and 1m", d)
+		}
+	}
+	if d := time.Duration(c.Watchdog.StreamTimeout); d < 20*time.Second {
+		return fmt.Errorf("Watchdog.StreamTimeout: %v is less than the 20s heartbeat allows", d)
+	}
+
+	sc := c.Sensors
+	if len(sc.Driver) == 0 {
+		if sc.Closed != nil || sc.Open != nil || len(c.Safety) != 0 {
+			return fmt.Errorf("Sensors.Driver: must be set when switches or safety inputs are configured")
+		}
+		return nil
+	}
+	if _, found := inputs[sc.Driver]; !found {
+		return fmt.Errorf("Sensors.Driver: unknown driver %q, available drivers: %q", sc.Driver, inputNames())
+	}
+	if d := time.Duration(sc.Debounce); d < 0 || d > time.Second {
+		return fmt.Errorf("Sensors.Debounce: %v is not between 0s and 1s", d)
+	}
+	if sc.Open != nil && sc.Closed == nil {
+		return fmt.Errorf("Sensors.Closed: the closed switch is required when the open switch is used")
+	}
+
+	type namedInput struct {
+		name string
+		ic   *InputConfig
+	}
+	all := []namedInput{{"Sensors.Closed", sc.Closed}, {"Sensors.Open", sc.Open}}
+	names := make(map[string]bool, len(c.Safety))
+	for i := range c.Safety {
+		sf := &c.Safety[i]
+		field := fmt.Sprintf("Safety[%d]", i)
+		if len(sf.Name) == 0 {
+			return fmt.Errorf("%s.Name: missing", field)
+		}
+		if names[sf.Name] {
+			return fmt.Errorf("%s.Name: %q is used twice", field, sf.Name)
+		}
+		names[sf.Name] = true
+		all = append(all, namedInput{field, &sf.InputConfig})
+	}
+
+	used := make(map[int]string, len(all)+1)
+	if c.Actuator.Driver == sc.Driver {
+		used[c.Actuator.Pin] = "the actuator"
+	}
+	for _, in := range all {
+		if in.ic == nil {
+			continue
+		}
+		if in.ic.Pin < 0 {
+			return fmt.Errorf("%s.Pin: %d is negative", in.name, in.ic.Pin)
+		}
+		if other, found := used[in.ic.Pin]; found {
+			return fmt.Errorf("%s.Pin: %d is already used by %s", in.name, in.ic.Pin, other)
+		}
+		used[in.ic.Pin] = in.name
+		err = validateBias(in.name+".Bias", in.ic.Bias)
+		if err != nil {
+			return err
+		}
+		if sc.Driver == "sysfs" && in.ic.Bias != "" && in.ic.Bias != "as-is" {
+			return fmt.Errorf("%s.Bias: the sysfs driver can not set a bias, use the gpiochip driver", in.name)
+		}
+	}
+	return nil
+}
+
+func (sc SimConfig) validate() error {
+	if d := time.Duration(sc.TravelTime); d < time.Second || d > 2*time.Minute {
+		return fmt.Errorf("Sim.TravelTime: %v is not between 1s and 2m", d)
+	}
+	if d := time.Duration(sc.Bounce); d < 0 || d > 500*time.Millisecond {
+		return fmt.Errorf("Sim.Bounce: %v is not between 0s and 500ms", d)
+	}
+	if d := time.Duration(sc.ObstructionTime); d < 0 || d > time.Minute {
+		return fmt.Errorf("Sim.ObstructionTime: %v is not between 0s and 1m", d)
+	}
+	if sc.Obstruction < 0 || sc.Obstruction > 1 {
+		return fmt.Errorf("Sim.Obstruction: %v is not between 0 and 1", sc.Obstruction)
+	}
+	if sc.SensorFailure < 0 || sc.SensorFailure > 1 {
+		return fmt.Errorf("Sim.SensorFailure: %v is not between 0 and 1", sc.SensorFailure)
+	}
+	return nil
+}
+
+func validateBias(name, bias string) error {
+	switch bias {
+	default:
+		return fmt.Errorf("%s: unknown bias %q, want one of as-is, pull-up, pull-down or disabled", name, bias)
+	case "", "as-is", "pull-up", "pull-down", "disabled":
+	}
+	return nil
+}
+
+// info describes the relay configuration to the mirror.
+func (c *Config) info() *comm.OpenerInfo {
+	ac := c.Actuator
+	return &comm.OpenerInfo{
+		Driver:      ac.Driver,
+		Chip:        ac.Chip,
+		Pin:         int32(ac.Pin),
+		ActiveLow:   ac.ActiveLow,
+		PulseMillis: int64(time.Duration(ac.PulseWidth) / time.Millisecond),
+		GapMillis:   int64(time.Duration(ac.Gap) / time.Millisecond),
+		Door:        c.Door.Name,
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+)
+
+// doorState holds the latest door status and signals when it changes.
+type doorState struct {
+	mu     sync.Mutex
+	status comm.DoorStatus
+	// lastDir is the last direction of travel, OPENING or CLOSING.
+	lastDir comm.DoorState
+
+	// changed receives a value when the status changes. It is buffered
+	// so a change is never lost while nobody is listening.
+	changed chan struct{}
+}
+
+func newDoorState() *doorState {
+	return &doorState{
+		status: comm.DoorStatus{
+			State:     comm.DoorState_UNKNOWN,
+			SinceUnix: time.Now().Unix(),
+			Reason:    "no sensors",
+		},
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// set updates the door state. The reason explains what caused the change.
+// A fault is always reported, even if the state is unchanged.
+func (d *doorState) set(state comm.DoorState, reason string, fault bool) {
+	d.mu.Lock()
+	if d.status.State == state && !fault {
+		d.mu.Unlock()
+		return
+	}
+	d.status = comm.DoorStatus{
+		State:     state,
+		SinceUnix: time.Now().Unix(),
+		Reason:    reason,
+		Fault:     fault,
+	}
+	if state == comm.DoorState_OPENING || state == comm.DoorState_CLOSING {
+		d.lastDir = state
+	}
+	d.mu.Unlock()
+
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+// motion returns the current state and the last direction of travel.
+func (d *doorState) motion() (state, lastDir comm.DoorState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status.State, d.lastDir
+}
+
+// get returns a copy of the current status.
+func (d *doorState) get() *comm.DoorStatus {
+	d.mu.Lock()
+	st := d.status
+	d.mu.Unlock()
+	return &st
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kardianos/garage/comm"
+	"github.com/kardianos/garage/comm/client"
+
+	"golang.org/x/net/context"
+)
+
+// mirrorClientConfig returns the configuration to connect to the mirror,
+// first enrolling with the pairing code if there is no certificate yet.
+func mirrorClientConfig(ctx context.Context, mc MirrorConfig) (client.Config, error) {
+	// The auth key is used until the opener has a certificate.
+	cc := client.Config{Key: comm.AuthKey()}
+	if len(mc.Cert) == 0 {
+		return cc, nil
+	}
+	_, err := os.Stat(mc.Cert)
+	if os.IsNotExist(err) && len(mc.PairCode) != 0 {
+		err = enroll(ctx, mc)
+	}
+	if err != nil {
+		return cc, err
+	}
+	cc.Cert, err = ioutil.ReadFile(mc.Cert)
+	if err != nil {
+		return cc, err
+	}
+	cc.CertKey, err = ioutil.ReadFile(mc.Key)
+	if err != nil {
+		return cc, err
+	}
+	return cc, nil
+}
+
+// enroll exchanges the pairing code for a certificate and writes it.
+func enroll(ctx context.Context, mc MirrorConfig) error {
+	addr, code, err := client.ParsePairing(mc.PairCode)
+	if err != nil {
+		return fmt.Errorf("Mirror.PairCode: %v", err)
+	}
+	e, err := client.Enroll(ctx, client.Config{Addr: addr}, code)
+	if err != nil {
+		return fmt.Errorf("failed to enroll with the pairing code: %v", err)
+	}
+	err = ioutil.WriteFile(mc.Key, e.CertKey, 0600)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(mc.Cert, e.Cert, 0644)
+	if err != nil {
+		return err
+	}
+	logger.Infof("enrolled with the mirror as %q, wrote %s", e.Name, mc.Cert)
+	return nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Definitions from the Linux GPIO character device uAPI, version 2
+// (include/uapi/linux/gpio.h).
+const (
+	gpioMaxNameSize          = 32
+	gpioV2LinesMax           = 64
+	gpioV2LineNumAttrsMax    = 10
+	gpioV2LineAttrIDFlags    = 1
+	gpioV2LineAttrIDValues   = 2
+	gpioV2LineAttrIDDebounce = 3
+)
+
+const (
+	gpioV2LineFlagUsed         = 1 << 0
+	gpioV2LineFlagActiveLow    = 1 << 1
+	gpioV2LineFlagInput        = 1 << 2
+	gpioV2LineFlagOutput       = 1 << 3
+	gpioV2LineFlagEdgeRising   = 1 << 4
+	gpioV2LineFlagEdgeFalling  = 1 << 5
+	gpioV2LineFlagOpenDrain    = 1 << 6
+	gpioV2LineFlagOpenSource   = 1 << 7
+	gpioV2LineFlagBiasPullUp   = 1 << 8
+	gpioV2LineFlagBiasPullDown = 1 << 9
+	gpioV2LineFlagBiasDisabled = 1 << 10
+)
+
+type gpioV2LineValues struct {
+	Bits uint64
+	Mask uint64
+}
+
+type gpioV2LineAttribute struct {
+	ID      uint32
+	Padding uint32
+	// Value holds the flags, output values or debounce period,
+	// depending on ID.
+	Value uint64
+}
+
+type gpioV2LineConfigAttribute struct {
+	Attr gpioV2LineAttribute
+	Mask uint64
+}
+
+type gpioV2LineConfig struct {
+	Flags    uint64
+	NumAttrs uint32
+	Padding  [5]uint32
+	Attrs    [gpioV2LineNumAttrsMax]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	Offsets         [gpioV2LinesMax]uint32
+	Consumer        [gpioMaxNameSize]byte
+	Config          gpioV2LineConfig
+	NumLines        uint32
+	EventBufferSize uint32
+	Padding         [5]uint32
+	Fd              int32
+}
+
+type gpioV2LineEvent struct {
+	TimestampNs uint64
+	ID          uint32
+	Offset      uint32
+	Seqno       uint32
+	LineSeqno   uint32
+	Padding     [6]uint32
+}
+
+// addAttr appends a line attribute that applies to the lines in mask.
+func (c *gpioV2LineConfig) addAttr(id uint32, value, mask uint64) error {
+	if c.NumAttrs >= gpioV2LineNumAttrsMax {
+		return fmt.Errorf("too many gpio line attributes")
+	}
+	c.Attrs[c.NumAttrs] = gpioV2LineConfigAttribute{
+		Attr: gpioV2LineAttribute{ID: id, Value: value},
+		Mask: mask,
+	}
+	c.NumAttrs++
+	return nil
+}
+
+func iowr(nr, size uintptr) uintptr {
+	const (
+		iocRead  = 2
+		iocWrite = 1
+		gpioType = 0xB4
+	)
+	return (iocRead|iocWrite)<<30 | size<<16 | gpioType<<8 | nr
+}
+
+var (
+	gpioV2GetLineIoctl       = iowr(0x07, unsafe.Sizeof(gpioV2LineRequest{}))
+	gpioV2LineGetValuesIoctl = iowr(0x0E, unsafe.Sizeof(gpioV2LineValues{}))
+	gpioV2LineSetValuesIoctl = iowr(0x0F, unsafe.Sizeof(gpioV2LineValues{}))
+)
+
+// gpioChip is the ioctl layer of a GPIO character device. Drivers only use
+// the chip through this interface so they can be exercised without hardware.
+type gpioChip interface {
+	// RequestLines requests the lines in req and returns a handle to them.
+	RequestLines(req *gpioV2LineRequest) (gpioLines, error)
+	Close() error
+}
+
+// gpioLines is a set of lines requested from a gpioChip.
+type gpioLines interface {
+	GetValues(v *gpioV2LineValues) error
+	SetValues(v *gpioV2LineValues) error
+	// ReadEvent blocks until an edge event is available on lines
+	// requested with edge detection.
+	ReadEvent(e *gpioV2LineEvent) error
+	Close() error
+}
+
+// openGPIOChip opens a GPIO character device. A bare name such as
+// "gpiochip0" is looked up in /dev.
+var openGPIOChip = func(name string) (gpioChip, error) {
+	if !strings.ContainsRune(name, filepath.Separator) {
+		name = filepath.Join("/dev", name)
+	}
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &cdevChip{f: f}, nil
+}
+
+type cdevChip struct {
+	f *os.File
+}
+
+func (c *cdevChip) RequestLines(req *gpioV2LineRequest) (gpioLines, error) {
+	err := ioctl(c.f.Fd(), gpioV2GetLineIoctl, unsafe.Pointer(req))
+	if err != nil {
+		return nil, fmt.Errorf("request gpio lines on %s: %v", c.f.Name(), err)
+	}
+	fd := int(req.Fd)
+	// A non-blocking descriptor lets the runtime poller interrupt
+	// reads when the lines are closed.
+	err = syscall.SetNonblock(fd, true)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return &cdevLines{f: os.NewFile(uintptr(fd), c.f.Name()+":lines")}, nil
+}
+
+func (c *cdevChip) Close() error {
+	return c.f.Close()
+}
+
+type cdevLines struct {
+	f *os.File
+}
+
+func (l *cdevLines) GetValues(v *gpioV2LineValues) error {
+	return l.ioctl(gpioV2LineGetValuesIoctl, unsafe.Pointer(v))
+}
+
+func (l *cdevLines) SetValues(v *gpioV2LineValues) error {
+	return l.ioctl(gpioV2LineSetValuesIoctl, unsafe.Pointer(v))
+}
+
+// ioctl runs req on the lines. Fd is not used, as it puts the descriptor
+// back in blocking mode and Close could then not interrupt ReadEvent.
+func (l *cdevLines) ioctl(req uintptr, arg unsafe.Pointer) error {
+	rc, err := l.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ioErr error
+	err = rc.Control(func(fd uintptr) {
+		ioErr = ioctl(fd, req, arg)
+	})
+	if err != nil {
+		return err
+	}
+	return ioErr
+}
+
+func (l *cdevLines) ReadEvent(e *gpioV2LineEvent) error {
+	buf := (*[unsafe.Sizeof(gpioV2LineEvent{})]byte)(unsafe.Pointer(e))
+	_, err := io.ReadFull(l.f, buf[:])
+	return err
+}
+
+func (l *cdevLines) Close() error {
+	return l.f.Close()
+}
+
+// isClosed reports whether err was caused by reading closed lines.
+func isClosed(err error) bool {
+	return errors.Is(err, os.ErrClosed)
+}
+
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// gpioBiasFlags returns the line flags for a bias setting.
+func gpioBiasFlags(bias string) (uint64, error) {
+	switch bias {
+	case "", "as-is":
+		return 0, nil
+	case "pull-up":
+		return gpioV2LineFlagBiasPullUp, nil
+	case "pull-down":
+		return gpioV2LineFlagBiasPullDown, nil
+	case "disabled":
+		return gpioV2LineFlagBiasDisabled, nil
+	}
+	return 0, fmt.Errorf("unknown gpio bias %q, want one of as-is, pull-up, pull-down or disabled", bias)
+}
+
+// newLineRequest returns a request for a single line with the given flags.
+func newLineRequest(offset int, flags uint64) *gpioV2LineRequest {
+	req := &gpioV2LineRequest{NumLines: 1}
+	req.Offsets[0] = uint32(offset)
+	copy(req.Consumer[:gpioMaxNameSize-1], "garage-opener")
+	req.Config.Flags = flags
+	return req
+}
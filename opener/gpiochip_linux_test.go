@@ -0,0 +1,359 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChip is a gpioChip that hands out a single fakeLines and records
+// the request for it.
+type fakeChip struct {
+	name   string
+	lines  *fakeLines
+	req    *gpioV2LineRequest
+	closed bool
+}
+
+func (c *fakeChip) RequestLines(req *gpioV2LineRequest) (gpioLines, error) {
+	if c.req != nil {
+		return nil, os.ErrExist
+	}
+	r := *req
+	c.req = &r
+	l := c.lines
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.activeLow = req.Config.Flags&gpioV2LineFlagActiveLow != 0
+	for _, a := range req.Config.Attrs[:req.Config.NumAttrs] {
+		if a.Attr.ID == gpioV2LineAttrIDValues && a.Mask&1 != 0 {
+			l.physical = (a.Attr.Value&1 != 0) != l.activeLow
+		}
+	}
+	return l, nil
+}
+
+func (c *fakeChip) Close() error {
+	c.closed = true
+	return nil
+}
+
+// fakeLines is a single line. Like the kernel it applies the active-low
+// flag, so physical is the level on the pin.
+type fakeLines struct {
+	mu        sync.Mutex
+	activeLow bool
+	physical  bool
+	closed    bool
+
+	events chan gpioV2LineEvent
+	done   chan struct{}
+}
+
+func newFakeLines(physical bool) *fakeLines {
+	return &fakeLines{
+		physical: physical,
+		events:   make(chan gpioV2LineEvent),
+		done:     make(chan struct{}),
+	}
+}
+
+// level returns the level on the pin.
+func (l *fakeLines) level() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.physical
+}
+
+// drive sets the level on the pin and sends an edge event.
+func (l *fakeLines) drive(physical bool) {
+	l.mu.Lock()
+	l.physical = physical
+	l.mu.Unlock()
+	id := uint32(1)
+	if !physical {
+		id = 2
+	}
+	l.events <- gpioV2LineEvent{TimestampNs: uint64(time.Now().UnixNano()), ID: id}
+}
+
+func (l *fakeLines) GetValues(v *gpioV2LineValues) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return os.ErrClosed
+	}
+	v.Bits = 0
+	if l.physical != l.activeLow {
+		v.Bits = v.Mask & 1
+	}
+	return nil
+}
+
+func (l *fakeLines) SetValues(v *gpioV2LineValues) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return os.ErrClosed
+	}
+	if v.Mask&1 != 0 {
+		l.physical = (v.Bits&1 != 0) != l.activeLow
+	}
+	return nil
+}
+
+func (l *fakeLines) ReadEvent(e *gpioV2LineEvent) error {
+	select {
+	case ev := <-l.events:
+		*e = ev
+		return nil
+	case <-l.done:
+		return os.ErrClosed
+	}
+}
+
+func (l *fakeLines) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closed = true
+		close(l.done)
+	}
+	return nil
+}
+
+// useFakeChip makes openGPIOChip return chip until the returned function
+// is called.
+func useFakeChip(t *testing.T, chip *fakeChip) func() {
+	orig := openGPIOChip
+	openGPIOChip = func(name string) (gpioChip, error) {
+		if name != chip.name {
+			t.Errorf("opened chip %q, want %q", name, chip.name)
+		}
+		return chip, nil
+	}
+	return func() { openGPIOChip = orig }
+}
+
+// checkRequest checks the parts of a request that do not depend on the
+// configuration.
+func checkRequest(t *testing.T, name string, req *gpioV2LineRequest, pin int) {
+	if req.NumLines != 1 || req.Offsets[0] != uint32(pin) {
+		t.Errorf("%s: requested %d lines at %d, want 1 at %d", name, req.NumLines, req.Offsets[0], pin)
+	}
+	if consumer := strings.TrimRight(string(req.Consumer[:]), "\x00"); consumer != "garage-opener" {
+		t.Errorf("%s: consumer %q", name, consumer)
+	}
+}
+
+func TestGPIOBiasFlags(t *testing.T) {
+	list := []struct {
+		bias  string
+		flags uint64
+		err   bool
+	}{
+		{bias: "", flags: 0},
+		{bias: "as-is", flags: 0},
+		{bias: "pull-up", flags: gpioV2LineFlagBiasPullUp},
+		{bias: "pull-down", flags: gpioV2LineFlagBiasPullDown},
+		{bias: "disabled", flags: gpioV2LineFlagBiasDisabled},
+		{bias: "pullup", err: true},
+		{bias: "Pull-Up", err: true},
+	}
+	for _, item := range list {
+		flags, err := gpioBiasFlags(item.bias)
+		if (err != nil) != item.err {
+			t.Errorf("%q: got error %v, want error %t", item.bias, err, item.err)
+			continue
+		}
+		if flags != item.flags {
+			t.Errorf("%q: got flags %#x, want %#x", item.bias, flags, item.flags)
+		}
+		// Every bias the configuration accepts must be known to the driver.
+		if verr := validateBias("Bias", item.bias); (verr != nil) != item.err {
+			t.Errorf("%q: validateBias got %v, gpioBiasFlags got %v", item.bias, verr, err)
+		}
+	}
+}
+
+func TestGPIOChipActuator(t *testing.T) {
+	list := []struct {
+		name      string
+		activeLow bool
+		bias      string
+		initial   bool
+		flags     uint64
+		// released is the pin level while the button is released.
+		released bool
+	}{
+		{name: "active high", flags: gpioV2LineFlagOutput, released: false},
+		{name: "active low", activeLow: true, flags: gpioV2LineFlagOutput | gpioV2LineFlagActiveLow, released: true},
+		{name: "pull-down", bias: "pull-down", flags: gpioV2LineFlagOutput | gpioV2LineFlagBiasPullDown, released: false},
+		{name: "active low pull-up", activeLow: true, bias: "pull-up", flags: gpioV2LineFlagOutput | gpioV2LineFlagActiveLow | gpioV2LineFlagBiasPullUp, released: true},
+		{name: "initial", initial: true, flags: gpioV2LineFlagOutput, released: false},
+		{name: "initial active low", activeLow: true, initial: true, flags: gpioV2LineFlagOutput | gpioV2LineFlagActiveLow, released: true},
+	}
+	for _, item := range list {
+		// The pin starts at the opposite of the wanted level, so the
+		// initial value must be requested.
+		chip := &fakeChip{name: "gpiochip1", lines: newFakeLines(!item.released)}
+		restore := useFakeChip(t, chip)
+
+		c := defaultConfig()
+		c.Actuator.Driver = "gpiochip"
+		c.Actuator.Chip = "gpiochip1"
+		c.Actuator.Pin = 23
+		c.Actuator.ActiveLow = item.activeLow
+		c.Actuator.Bias = item.bias
+		c.Actuator.Initial = item.initial
+		act, err := openGPIOChipActuator(c)
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", item.name, err)
+			continue
+		}
+		if !chip.closed {
+			t.Errorf("%s: chip left open", item.name)
+		}
+		req := chip.req
+		checkRequest(t, item.name, req, 23)
+		if req.Config.Flags != item.flags {
+			t.Errorf("%s: got flags %#x, want %#x", item.name, req.Config.Flags, item.flags)
+		}
+		if req.Config.NumAttrs != 1 || req.Config.Attrs[0].Attr.ID != gpioV2LineAttrIDValues || req.Config.Attrs[0].Mask != 1 {
+			t.Errorf("%s: got attributes %+v, want one output value", item.name, req.Config.Attrs[:req.Config.NumAttrs])
+		}
+
+		want := item.released
+		if item.initial {
+			want = !want
+		}
+		if got := chip.lines.level(); got != want {
+			t.Errorf("%s: pin is %t after request, want %t", item.name, got, want)
+		}
+		for _, active := range []bool{true, false, true, false} {
+			err = act.Set(active)
+			if err != nil {
+				t.Errorf("%s: set %t: %v", item.name, active, err)
+			}
+			if got := chip.lines.level(); got != (active != item.released) {
+				t.Errorf("%s: pin is %t when set %t", item.name, got, active)
+			}
+		}
+		act.Close()
+		if act.Set(true) == nil {
+			t.Errorf("%s: set after close succeeded", item.name)
+		}
+	}
+}
+
+func TestGPIOChipActuatorConfig(t *testing.T) {
+	c := defaultConfig()
+	c.Actuator.Driver = "gpiochip"
+	c.Actuator.Initial = true
+	if err := c.validate(); err == nil {
+		t.Error("a relay that starts active was accepted")
+	}
+	c.Actuator.Initial = false
+	c.Actuator.Bias = "up"
+	if err := c.validate(); err == nil {
+		t.Error("an unknown bias was accepted")
+	}
+}
+
+func TestGPIOChipInput(t *testing.T) {
+	const edges = gpioV2LineFlagInput | gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+	list := []struct {
+		name      string
+		activeLow bool
+		bias      string
+		flags     uint64
+	}{
+		{name: "active high", flags: edges},
+		{name: "active low pull-up", activeLow: true, bias: "pull-up", flags: edges | gpioV2LineFlagActiveLow | gpioV2LineFlagBiasPullUp},
+		{name: "pull-down", bias: "pull-down", flags: edges | gpioV2LineFlagBiasPullDown},
+		{name: "bias disabled", bias: "disabled", flags: edges | gpioV2LineFlagBiasDisabled},
+	}
+	for _, item := range list {
+		lines := newFakeLines(false)
+		chip := &fakeChip{name: "gpiochip0", lines: lines}
+		restore := useFakeChip(t, chip)
+
+		c := defaultConfig()
+		c.Sensors.Chip = "gpiochip0"
+		ic := &InputConfig{Pin: 5, ActiveLow: item.activeLow, Bias: item.bias}
+		in, err := openGPIOChipInput(c, ic)
+		restore()
+		if err != nil {
+			t.Errorf("%s: %v", item.name, err)
+			continue
+		}
+		if !chip.closed {
+			t.Errorf("%s: chip left open", item.name)
+		}
+		checkRequest(t, item.name, chip.req, 5)
+		if chip.req.Config.Flags != item.flags {
+			t.Errorf("%s: got flags %#x, want %#x", item.name, chip.req.Config.Flags, item.flags)
+		}
+
+		changed := make(chan struct{}, 4)
+		err = in.Watch(func() { changed <- struct{}{} })
+		if err != nil {
+			t.Errorf("%s: watch: %v", item.name, err)
+		}
+		for _, physical := range []bool{false, true, false} {
+			if physical != lines.level() {
+				lines.drive(physical)
+				select {
+				case <-changed:
+				case <-time.After(time.Second):
+					t.Errorf("%s: no change reported for an edge", item.name)
+				}
+			}
+			got, err := in.Get()
+			if err != nil {
+				t.Errorf("%s: get: %v", item.name, err)
+			}
+			if want := physical != item.activeLow; got != want {
+				t.Errorf("%s: got %t with the pin at %t, want %t", item.name, got, physical, want)
+			}
+		}
+		in.Close()
+		select {
+		case <-changed:
+			t.Errorf("%s: change reported after close", item.name)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestCdevLinesCloseInterruptsRead(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	l := &cdevLines{f: r}
+	// A pipe is not a GPIO line, so the ioctls fail, but they must leave
+	// the descriptor non-blocking.
+	l.GetValues(&gpioV2LineValues{Mask: 1})
+	l.SetValues(&gpioV2LineValues{Mask: 1})
+
+	done := make(chan error, 1)
+	go func() {
+		var e gpioV2LineEvent
+		done <- l.ReadEvent(&e)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	l.Close()
+	select {
+	case err := <-done:
+		if !isClosed(err) {
+			t.Errorf("read ended with %v, want closed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("close did not interrupt a blocked read")
+	}
+}
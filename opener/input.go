@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Input is a digital input such as a reed switch.
+type Input interface {
+	// Get returns the current logical level of the input.
+	Get() (bool, error)
+	// Watch calls changed, from another goroutine, each time an edge
+	// is detected on the input. It must be called at most once.
+	Watch(changed func()) error
+	Close() error
+}
+
+type inputFactory func(c *Config, ic *InputConfig) (Input, error)
+
+var inputs = map[string]inputFactory{}
+
+// registerInput makes an input driver available by name.
+// It should be called from an init function.
+func registerInput(name string, f inputFactory) {
+	if _, found := inputs[name]; found {
+		panic("input driver registered twice: " + name)
+	}
+	inputs[name] = f
+}
+
+// openInput opens an input with the sensor driver selected in the configuration.
+func openInput(c *Config, ic *InputConfig) (Input, error) {
+	f, found := inputs[c.Sensors.Driver]
+	if !found {
+		return nil, fmt.Errorf("unknown input driver %q", c.Sensors.Driver)
+	}
+	in, err := f(c, ic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s input on pin %d: %v", c.Sensors.Driver, ic.Pin, err)
+	}
+	return in, nil
+}
+
+func inputNames() []string {
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// watchInput calls report with the current level of in, then again each
+// time the level changes and stays stable for period. It stops when done
+// is closed.
+func watchInput(in Input, period time.Duration, done <-chan struct{}, report func(level bool)) error {
+	edge := make(chan struct{}, 1)
+	err := in.Watch(func() {
+		select {
+		case edge <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		return err
+	}
+	level, err := in.Get()
+	if err != nil {
+		return err
+	}
+	report(level)
+
+	go func() {
+		settle := time.NewTimer(period)
+		settle.Stop()
+		defer settle.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-edge:
+				settle.Stop()
+				settle.Reset(period)
+			case <-settle.C:
+				v, err := in.Get()
+				if err != nil {
+					continue
+				}
+				if v != level {
+					level = v
+					report(level)
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+func init() {
+	registerInput("gpiochip", openGPIOChipInput)
+}
+
+// gpiochipInput reads a switch through a GPIO character device line
+// requested with edge detection on both edges.
+type gpiochipInput struct {
+	lines gpioLines
+}
+
+func openGPIOChipInput(c *Config, ic *InputConfig) (Input, error) {
+	bias, err := gpioBiasFlags(ic.Bias)
+	if err != nil {
+		return nil, err
+	}
+	flags := gpioV2LineFlagInput | gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling | bias
+	if ic.ActiveLow {
+		flags |= gpioV2LineFlagActiveLow
+	}
+	chip, err := openGPIOChip(c.Sensors.Chip)
+	if err != nil {
+		return nil, err
+	}
+	defer chip.Close()
+
+	lines, err := chip.RequestLines(newLineRequest(ic.Pin, flags))
+	if err != nil {
+		return nil, err
+	}
+	return &gpiochipInput{lines: lines}, nil
+}
+
+func (in *gpiochipInput) Get() (bool, error) {
+	v := &gpioV2LineValues{Mask: 1}
+	err := in.lines.GetValues(v)
+	return v.Bits&1 != 0, err
+}
+
+func (in *gpiochipInput) Watch(changed func()) error {
+	go func() {
+		var e gpioV2LineEvent
+		for {
+			err := in.lines.ReadEvent(&e)
+			if err != nil {
+				if !isClosed(err) {
+					logger.Error("gpio read event ", err)
+				}
+				return
+			}
+			changed()
+		}
+	}()
+	return nil
+}
+
+func (in *gpiochipInput) Close() error {
+	return in.lines.Close()
+}
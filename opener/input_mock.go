@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sync"
+)
+
+func init() {
+	registerInput("mock", func(c *Config, ic *InputConfig) (Input, error) {
+		return &mockInput{}, nil
+	})
+}
+
+// mockInput is an input whose level is set by the program.
+type mockInput struct {
+	mu      sync.Mutex
+	level   bool
+	changed func()
+}
+
+func (in *mockInput) Get() (bool, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.level, nil
+}
+
+func (in *mockInput) Watch(changed func()) error {
+	in.mu.Lock()
+	in.changed = changed
+	in.mu.Unlock()
+	return nil
+}
+
+func (in *mockInput) Close() error {
+	return nil
+}
+
+// Set changes the input level and signals an edge if it changed.
+func (in *mockInput) Set(level bool) {
+	in.mu.Lock()
+	changed := in.changed
+	if in.level == level {
+		changed = nil
+	}
+	in.level = level
+	in.mu.Unlock()
+	if changed != nil {
+		changed()
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/davecheney/gpio"
+)
+
+func init() {
+	registerInput("sysfs", openSysfsInput)
+}
+
+// sysfsInput reads a switch through the sysfs GPIO interface.
+type sysfsInput struct {
+	pin       gpio.Pin
+	activeLow bool
+	watching  bool
+}
+
+func openSysfsInput(c *Config, ic *InputConfig) (Input, error) {
+	pin, err := gpio.OpenPin(ic.Pin, gpio.ModeInput)
+	if err != nil {
+		return nil, err
+	}
+	return &sysfsInput{pin: pin, activeLow: ic.ActiveLow}, nil
+}
+
+func (in *sysfsInput) Get() (bool, error) {
+	v := in.pin.Get()
+	return v != in.activeLow, in.pin.Err()
+}
+
+func (in *sysfsInput) Watch(changed func()) error {
+	err := in.pin.BeginWatch(gpio.EdgeBoth, gpio.IRQEvent(changed))
+	if err != nil {
+		return err
+	}
+	in.watching = true
+	return nil
+}
+
+func (in *sysfsInput) Close() error {
+	if in.watching {
+		in.pin.EndWatch()
+	}
+	return in.pin.Close()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSysfsInputBias(t *testing.T) {
+	list := []struct {
+		driver, bias string
+		ok           bool
+	}{
+		{driver: "sysfs", bias: "", ok: true},
+		{driver: "sysfs", bias: "as-is", ok: true},
+		{driver: "sysfs", bias: "pull-up"},
+		{driver: "sysfs", bias: "disabled"},
+		{driver: "mock", bias: "pull-up", ok: true},
+	}
+	for _, item := range list {
+		c := defaultConfig()
+		c.Actuator.Driver = "log"
+		c.Sensors.Driver = item.driver
+		c.Sensors.Closed = &InputConfig{Pin: 4, Bias: item.bias}
+		err := c.validate()
+		if (err == nil) != item.ok {
+			t.Errorf("%s driver with bias %q: got %v, want ok %t", item.driver, item.bias, err, item.ok)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchInput(t *testing.T) {
+	const period = 30 * time.Millisecond
+	// step sets the input level, then waits.
+	type step struct {
+		level bool
+		wait  time.Duration
+	}
+	list := []struct {
+		name    string
+		initial bool
+		steps   []step
+		want    []bool
+	}{
+		{name: "steady", initial: false, want: []bool{false}},
+		{name: "steady active", initial: true, want: []bool{true}},
+		{
+			name:  "change",
+			steps: []step{{true, 3 * period}},
+			want:  []bool{false, true},
+		},
+		{
+			name: "bounce",
+			steps: []step{
+				{true, period / 6}, {false, period / 6}, {true, period / 6}, {false, period / 6}, {true, 3 * period},
+			},
+			want: []bool{false, true},
+		},
+		{
+			name: "glitch",
+			steps: []step{
+				{true, period / 6}, {false, 3 * period},
+			},
+			want: []bool{false},
+		},
+		{
+			name: "change and back",
+			steps: []step{
+				{true, 3 * period}, {false, period / 6}, {true, period / 6}, {false, 3 * period},
+			},
+			want: []bool{false, true, false},
+		},
+	}
+	for _, item := range list {
+		in := &mockInput{level: item.initial}
+		done := make(chan struct{})
+
+		var mu sync.Mutex
+		var got []bool
+		err := watchInput(in, period, done, func(level bool) {
+			mu.Lock()
+			got = append(got, level)
+			mu.Unlock()
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", item.name, err)
+		}
+		for _, s := range item.steps {
+			in.Set(s.level)
+			time.Sleep(s.wait)
+		}
+		close(done)
+
+		mu.Lock()
+		if !reflect.DeepEqual(got, item.want) {
+			t.Errorf("%s: reported %v, want %v", item.name, got, item.want)
+		}
+		mu.Unlock()
+	}
+}
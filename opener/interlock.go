@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kardianos/garage/comm"
+)
+
+// interlock is the only way door commands reach the relay. It refuses
+// commands that could start the door closing while a safety input is
+// active.
+//
+// Only one press is queued or in progress at a time. The door state
+// reflects a press once it is done, so each command is planned against
+// the motion the last press started.
+type interlock struct {
+	door *doorState
+	sig  chan press
+
+	mu     sync.Mutex
+	active map[string]bool
+	busy   bool // A press is queued or in progress.
+	closed bool
+}
+
+func newInterlock(door *doorState, sig chan press) *interlock {
+	return &interlock{
+		door:   door,
+		sig:    sig,
+		active: make(map[string]bool),
+	}
+}
+
+// setSafety records the level of a safety input. When an input becomes
+// active, a press queued for the relay is dropped and its command fails.
+func (il *interlock) setSafety(name string, active bool) {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	if !active {
+		if il.active[name] {
+			logger.Infof("safety input %q released", name)
+		}
+		delete(il.active, name)
+		return
+	}
+	logger.Warningf("safety input %q active", name)
+	il.active[name] = true
+	if il.closed {
+		return
+	}
+	select {
+	case p := <-il.sig:
+		logger.Warningf("dropped queued press, safety input %q active", name)
+		p.done <- fmt.Errorf("refused: safety input %s became active before the press", name)
+	default:
+	}
+}
+
+// close refuses further commands and closes the relay signal so the
+// output loop finishes once any queued press is done.
+func (il *interlock) close() {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+	if il.closed {
+		return
+	}
+	il.closed = true
+	close(il.sig)
+}
+
+// command performs action, pressing the relay if needed. When it presses
+// the relay it returns once the press is done.
+func (il *interlock) command(action comm.Action) *comm.CommandResult {
+	p, res := il.queue(action)
+	if res != nil {
+		return res
+	}
+	err := <-p.done
+
+	il.mu.Lock()
+	il.busy = false
+	il.mu.Unlock()
+
+	if err != nil {
+		return &comm.CommandResult{Message: err.Error()}
+	}
+	return &comm.CommandResult{OK: true}
+}
+
+// queue plans action and queues a press of the relay if one is needed.
+// It returns the result of the command if no press was queued.
+func (il *interlock) queue(action comm.Action) (press, *comm.CommandResult) {
+	il.mu.Lock()
+	defer il.mu.Unlock()
+
+	if il.closed {
+		return press{}, &comm.CommandResult{Message: "refused: opener is stopping"}
+	}
+	if il.busy {
+		return press{}, &comm.CommandResult{Message: "refused: opener busy with another press"}
+	}
+
+	state, lastDir := il.door.motion()
+	p, err := plan(action, state, lastDir)
+	if err != nil {
+		return press{}, &comm.CommandResult{Message: err.Error()}
+	}
+	if !p.press {
+		return press{}, &comm.CommandResult{OK: true, Message: p.note}
+	}
+	if p.closes && len(il.active) != 0 {
+		names := make([]string, 0, len(il.active))
+		for name := range il.active {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		msg := fmt.Sprintf("refused: door may close while safety input %s is active", strings.Join(names, ", "))
+		logger.Warningf("%v %s", action, msg)
+		return press{}, &comm.CommandResult{Message: msg}
+	}
+	pr := press{done: make(chan error, 1)}
+	select {
+	case il.sig <- pr:
+	default:
+		return press{}, &comm.CommandResult{Message: "refused: opener busy"}
+	}
+	il.busy = true
+	return pr, nil
+}
+
+// pressPlan is how a command is carried out.
+type pressPlan struct {
+	press  bool   // Press the relay.
+	closes bool   // The press could start the door closing.
+	note   string // Why no press is needed.
+}
+
+// plan decides how to carry out action given the door state, or returns
+// why it cannot be done with a single press.
+func plan(action comm.Action, state, lastDir comm.DoorState) (pressPlan, error) {
+	switch action {
+	case comm.Action_TOGGLE:
+		return pressPlan{press: true, closes: pressCloses(state, lastDir)}, nil
+	case comm.Action_OPEN_DOOR:
+		switch state {
+		case comm.DoorState_OPEN, comm.DoorState_OPENING:
+			return pressPlan{note: "door is already open"}, nil
+		case comm.DoorState_CLOSED, comm.DoorState_CLOSING:
+			return pressPlan{press: true}, nil
+		case comm.DoorState_STOPPED:
+			if lastDir == comm.DoorState_CLOSING {
+				return pressPlan{press: true}, nil
+			}
+			return pressPlan{}, fmt.Errorf("refused: door stopped while opening, a press would close it")
+		}
+	case comm.Action_CLOSE_DOOR:
+		switch state {
+		case comm.DoorState_CLOSED, comm.DoorState_CLOSING:
+			return pressPlan{note: "door is already closed"}, nil
+		case comm.DoorState_OPEN:
+			return pressPlan{press: true, closes: true}, nil
+		case comm.DoorState_OPENING:
+			return pressPlan{}, fmt.Errorf("refused: door is opening, a press would stop it")
+		case comm.DoorState_STOPPED:
+			if lastDir == comm.DoorState_OPENING {
+				return pressPlan{press: true, closes: true}, nil
+			}
+			return pressPlan{}, fmt.Errorf("refused: door stopped while closing, a press would open it")
+		}
+	default:
+		return pressPlan{}, fmt.Errorf("unknown action %v", action)
+	}
+	return pressPlan{}, fmt.Errorf("refused: door position is %v", state)
+}
+
+// pressCloses reports whether a press could start the door closing.
+// When the position is not known it assumes it could.
+func pressCloses(state, lastDir comm.DoorState) bool {
+	switch state {
+	case comm.DoorState_CLOSED, comm.DoorState_OPENING, comm.DoorState_CLOSING:
+		return false
+	case comm.DoorState_STOPPED:
+		return lastDir != comm.DoorState_CLOSING
+	}
+	return true
+}
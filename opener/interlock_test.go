@@ -0,0 +1,191 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+)
+
+func TestPlan(t *testing.T) {
+	const (
+		toggle    = comm.Action_TOGGLE
+		openDoor  = comm.Action_OPEN_DOOR
+		closeDoor = comm.Action_CLOSE_DOOR
+
+		unknown = comm.DoorState_UNKNOWN
+		closed  = comm.DoorState_CLOSED
+		opened  = comm.DoorState_OPEN
+		opening = comm.DoorState_OPENING
+		closing = comm.DoorState_CLOSING
+		stopped = comm.DoorState_STOPPED
+		between = comm.DoorState_BETWEEN
+	)
+	list := []struct {
+		action         comm.Action
+		state, lastDir comm.DoorState
+		press, closes  bool
+		refused        bool
+	}{
+		{action: toggle, state: closed, press: true},
+		{action: toggle, state: opened, press: true, closes: true},
+		{action: toggle, state: opening, press: true},
+		{action: toggle, state: closing, press: true},
+		{action: toggle, state: stopped, lastDir: opening, press: true, closes: true},
+		{action: toggle, state: stopped, lastDir: closing, press: true},
+		{action: toggle, state: unknown, press: true, closes: true},
+		{action: toggle, state: between, press: true, closes: true},
+
+		{action: openDoor, state: closed, press: true},
+		{action: openDoor, state: closing, press: true},
+		{action: openDoor, state: opened},
+		{action: openDoor, state: opening},
+		{action: openDoor, state: stopped, lastDir: closing, press: true},
+		{action: openDoor, state: stopped, lastDir: opening, refused: true},
+		{action: openDoor, state: unknown, refused: true},
+		{action: openDoor, state: between, refused: true},
+
+		{action: closeDoor, state: opened, press: true, closes: true},
+		{action: closeDoor, state: closed},
+		{action: closeDoor, state: closing},
+		{action: closeDoor, state: opening, refused: true},
+		{action: closeDoor, state: stopped, lastDir: opening, press: true, closes: true},
+		{action: closeDoor, state: stopped, lastDir: closing, refused: true},
+		{action: closeDoor, state: unknown, refused: true},
+		{action: closeDoor, state: between, refused: true},
+
+		{action: comm.Action(99), state: closed, refused: true},
+	}
+	for _, item := range list {
+		p, err := plan(item.action, item.state, item.lastDir)
+		if item.refused {
+			if err == nil {
+				t.Errorf("%v %v after %v: want refused, got %+v", item.action, item.state, item.lastDir, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v %v after %v: %v", item.action, item.state, item.lastDir, err)
+			continue
+		}
+		if p.press != item.press || p.closes != item.closes {
+			t.Errorf("%v %v after %v: got press %t closes %t, want press %t closes %t",
+				item.action, item.state, item.lastDir, p.press, p.closes, item.press, item.closes)
+		}
+		if !p.press && len(p.note) == 0 {
+			t.Errorf("%v %v after %v: no press and no note", item.action, item.state, item.lastDir)
+		}
+	}
+}
+
+// testInterlock is an interlock pressing a mock actuator, with the door
+// machine inferring the door state from the presses.
+type testInterlock struct {
+	*interlock
+	act     *mockActuator
+	door    *doorState
+	machine *doorMachine
+	done    chan struct{}
+}
+
+func newTestInterlock(width time.Duration) *testInterlock {
+	c := defaultConfig()
+	c.Actuator.PulseWidth = Duration(width)
+	c.Actuator.Gap = 0
+	c.Sensors.Closed = &InputConfig{}
+	door := newDoorState()
+	ti := &testInterlock{
+		act:     &mockActuator{},
+		door:    door,
+		machine: newDoorMachine(door, c),
+		done:    make(chan struct{}),
+	}
+	go ti.machine.run()
+	sig := make(chan press, 1)
+	go func() {
+		outputLoop(ti.act, c.Actuator, sig, ti.machine.press, &health{})
+		close(ti.done)
+	}()
+	ti.interlock = newInterlock(door, sig)
+	return ti
+}
+
+// open reports the door open with the closed switch.
+func (ti *testInterlock) open(t *testing.T) {
+	ti.machine.level(sensorLevel{active: false})
+	waitState(t, ti.door, comm.DoorState_OPEN)
+}
+
+func (ti *testInterlock) stop() {
+	ti.close()
+	<-ti.done
+	ti.machine.Close()
+}
+
+// commandAsync runs a command and returns its result on a channel.
+func (ti *testInterlock) commandAsync(action comm.Action) <-chan *comm.CommandResult {
+	res := make(chan *comm.CommandResult, 1)
+	go func() { res <- ti.command(action) }()
+	return res
+}
+
+func TestInterlockOnePress(t *testing.T) {
+	ti := newTestInterlock(100 * time.Millisecond)
+	defer ti.stop()
+	ti.open(t)
+
+	// A second close while the first press is in progress would stop
+	// the door, so it is refused.
+	first := ti.commandAsync(comm.Action_CLOSE_DOOR)
+	time.Sleep(20 * time.Millisecond)
+	if res := ti.command(comm.Action_CLOSE_DOOR); res.OK {
+		t.Errorf("second close during a press: got OK %q", res.Message)
+	}
+	if res := <-first; !res.OK {
+		t.Errorf("first close: %s", res.Message)
+	}
+	// Once the press is done the door is closing, so another close
+	// needs no press.
+	if state, _ := ti.door.motion(); state != comm.DoorState_CLOSING {
+		t.Errorf("door is %v after the press, want CLOSING", state)
+	}
+	if res := ti.command(comm.Action_CLOSE_DOOR); !res.OK || len(res.Message) == 0 {
+		t.Errorf("close while closing: got OK %t %q, want OK with a note", res.OK, res.Message)
+	}
+	if n := ti.act.Presses(); n != 1 {
+		t.Errorf("got %d presses, want 1", n)
+	}
+}
+
+func TestInterlockSafety(t *testing.T) {
+	ti := newTestInterlock(100 * time.Millisecond)
+	defer ti.stop()
+	ti.open(t)
+
+	ti.setSafety("photo-eye", true)
+	if res := ti.command(comm.Action_CLOSE_DOOR); res.OK {
+		t.Errorf("close with an active safety input: got OK %q", res.Message)
+	}
+	ti.setSafety("photo-eye", false)
+
+	// Hold the output loop in a press of its own, so the close is queued
+	// when the safety input becomes active.
+	blocker := press{done: make(chan error, 1)}
+	ti.sig <- blocker
+	time.Sleep(20 * time.Millisecond)
+	res := ti.commandAsync(comm.Action_CLOSE_DOOR)
+	time.Sleep(20 * time.Millisecond)
+	ti.setSafety("photo-eye", true)
+	select {
+	case r := <-res:
+		if r.OK {
+			t.Errorf("dropped close: got OK %q", r.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dropped close never answered")
+	}
+	<-blocker.done
+	if n := ti.act.Presses(); n != 1 {
+		t.Errorf("got %d presses, want only the blocking one", n)
+	}
+}
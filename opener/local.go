@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+)
+
+// localServer serves the Garage service on the local network so the door
+// can be operated while the mirror is unreachable. Commands take the same
+// path through the interlock as commands from the mirror.
+//
+// Clients must send the auth key and a client certificate signed by the
+// CA. The opener does not have the policy of the mirror, so only admins
+// and members may use it.
+type localServer struct {
+	il   *interlock
+	door *doorState
+	info *comm.OpenerInfo
+}
+
+// serveLocal starts the local server if it is configured.
+func serveLocal(c *Config, il *interlock, door *doorState) (*grpc.Server, error) {
+	lc := c.Local
+	if len(lc.Listen) == 0 {
+		return nil, nil
+	}
+	certPEM, keyPEM := comm.Cert(), comm.Key()
+	if len(lc.Cert) != 0 {
+		var err error
+		certPEM, err = ioutil.ReadFile(lc.Cert)
+		if err != nil {
+			return nil, err
+		}
+		keyPEM, err = ioutil.ReadFile(lc.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load local cert and key: %v", err)
+	}
+	caPEM := comm.CA()
+	if len(lc.CA) != 0 {
+		caPEM, err = ioutil.ReadFile(lc.CA)
+		if err != nil {
+			return nil, err
+		}
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("failed to load the local CA certificate")
+	}
+	authKey := lc.AuthKey
+	if len(authKey) == 0 {
+		authKey = comm.AuthKey()
+	}
+
+	listener, err := net.Listen("tcp", lc.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %v", lc.Listen, err)
+	}
+	opts := append([]grpc.ServerOption{
+		grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			CipherSuites: comm.Ciphers,
+			MinVersion:   tls.VersionTLS12,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    clientCAs,
+		})),
+	}, localInterceptors(authKey)...)
+	s := grpc.NewServer(opts...)
+	comm.RegisterGarageServer(s, &localServer{il: il, door: door, info: c.info()})
+
+	logger.Infof("serving local API on %s", listener.Addr())
+	go func() {
+		err := s.Serve(listener)
+		if err != nil {
+			logger.Error("local API ", err)
+		}
+	}()
+	return s, nil
+}
+
+// localAuthorize checks a request carries key and the client certificate
+// of an admin or member.
+func localAuthorize(ctx context.Context, key string) error {
+	if err := comm.CheckKey(ctx, key); err != nil {
+		return err
+	}
+	name, role, ok := comm.CertRole(ctx)
+	if !ok {
+		return grpc.Errorf(codes.Unauthenticated, "send a client certificate")
+	}
+	switch role {
+	case comm.Role_ADMIN, comm.Role_MEMBER:
+		return nil
+	}
+	return grpc.Errorf(codes.PermissionDenied, "%s %q may not use the local API, only admins and members may", strings.ToLower(role.String()), name)
+}
+
+// localInterceptors return server options that authorize every request
+// with localAuthorize.
+func localInterceptors(key string) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := localAuthorize(ctx, key); err != nil {
+				logger.Warningf("local %s: %v", info.FullMethod, grpc.ErrorDesc(err))
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := localAuthorize(ss.Context(), key); err != nil {
+				logger.Warningf("local %s: %v", info.FullMethod, grpc.ErrorDesc(err))
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+func (l *localServer) Ping(ctx context.Context, _ *comm.PingReq) (*comm.PingResp, error) {
+	return &comm.PingResp{}, nil
+}
+
+// checkDoor returns a NotFound error if name is set and is not this door.
+func (l *localServer) checkDoor(name string) error {
+	if len(name) != 0 && name != l.info.Door {
+		return grpc.Errorf(codes.NotFound, "door %q is not operated by this opener, it operates %q", name, l.info.Door)
+	}
+	return nil
+}
+
+func (l *localServer) Toggle(ctx context.Context, req *comm.ToggleReq) (*comm.ToggleResp, error) {
+	if err := l.checkDoor(req.Door); err != nil {
+		return nil, err
+	}
+	res := l.il.command(req.Action)
+	if !res.OK {
+		logger.Warningf("local %v: %s", req.Action, res.Message)
+		return nil, grpc.Errorf(codes.FailedPrecondition, "%s", res.Message)
+	}
+	return &comm.ToggleResp{Message: res.Message}, nil
+}
+
+func (l *localServer) Status(ctx context.Context, req *comm.StatusReq) (*comm.StatusResp, error) {
+	if err := l.checkDoor(req.Door); err != nil {
+		return nil, err
+	}
+	return &comm.StatusResp{Status: l.door.get(), OpenerOnline: true, Door: l.info.Door}, nil
+}
+
+func (l *localServer) Doors(ctx context.Context, _ *comm.DoorsReq) (*comm.DoorsResp, error) {
+	return &comm.DoorsResp{Doors: []*comm.Door{{
+		Name:   l.info.Door,
+		Status: l.door.get(),
+		Info:   l.info,
+		Online: true,
+	}}}, nil
+}
+
+func (l *localServer) History(ctx context.Context, _ *comm.HistoryReq) (*comm.HistoryResp, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "history is kept by the mirror")
+}
+
+func (l *localServer) Watch(*comm.WatchReq, comm.Garage_WatchServer) error {
+	return grpc.Errorf(codes.Unimplemented, "watch the door through the mirror")
+}
+
+func (l *localServer) Pair(ctx context.Context, _ *comm.PairReq) (*comm.PairResp, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "devices are paired with the mirror")
+}
+
+func (l *localServer) Enroll(ctx context.Context, _ *comm.EnrollReq) (*comm.EnrollResp, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "devices enroll with the mirror")
+}
+
+func (l *localServer) CreatePass(ctx context.Context, _ *comm.CreatePassReq) (*comm.Pass, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "guest passes are kept by the mirror")
+}
+
+func (l *localServer) ListPasses(ctx context.Context, _ *comm.ListPassesReq) (*comm.ListPassesResp, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "guest passes are kept by the mirror")
+}
+
+func (l *localServer) RevokePass(ctx context.Context, _ *comm.RevokePassReq) (*comm.Pass, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "guest passes are kept by the mirror")
+}
+
+func (l *localServer) Garage(comm.Garage_GarageServer) error {
+	return grpc.Errorf(codes.Unimplemented, "openers connect to the mirror, not to each other")
+}
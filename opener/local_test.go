@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/kardianos/garage/comm"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestLocalAuthorize(t *testing.T) {
+	const key = "local key"
+	list := []struct {
+		name string
+		key  string
+		// ou is the organizational unit of the client certificate, none
+		// is sent if noCert is set.
+		ou     string
+		noCert bool
+		code   codes.Code
+	}{
+		{name: "admin", key: key, ou: "admin", code: codes.OK},
+		{name: "member", key: key, ou: "member", code: codes.OK},
+		{name: "guest", key: key, ou: "guest", code: codes.PermissionDenied},
+		{name: "opener", key: key, ou: "opener", code: codes.PermissionDenied},
+		{name: "no role", key: key, code: codes.PermissionDenied},
+		{name: "unknown role", key: key, ou: "owner", code: codes.PermissionDenied},
+		{name: "no cert", key: key, noCert: true, code: codes.Unauthenticated},
+		{name: "wrong key", key: "other", ou: "admin", code: codes.Unauthenticated},
+		{name: "no key", ou: "admin", code: codes.Unauthenticated},
+	}
+	for _, item := range list {
+		var state tls.ConnectionState
+		if !item.noCert {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: "phone"}}
+			if len(item.ou) != 0 {
+				cert.Subject.OrganizationalUnit = []string{item.ou}
+			}
+			state.PeerCertificates = []*x509.Certificate{cert}
+		}
+		ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+		ctx = comm.NewContext(ctx, item.key, "")
+		err := localAuthorize(ctx, key)
+		if code := grpc.Code(err); code != item.code {
+			t.Errorf("%s: got %v (%v), want %v", item.name, code, err, item.code)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"time"
+
+	"github.com/kardianos/garage/comm"
+)
+
+// doorMachine infers the door state from relay presses, switch edges and
+// the door travel time.
+//
+// A press starts a stationary door moving, stops an opening door and
+// reverses a closing door, as most residential openers do. A stopped door
+// moves in the opposite direction to its last travel when pressed again.
+// A door that is still moving after the travel time has either arrived,
+// if there is no switch to confirm it, or is reported as stopped with a
+// fault.
+type doorMachine struct {
+	door      *doorState
+	travel    time.Duration
+	hasClosed bool
+	hasOpen   bool
+
+	pressed chan chan struct{}
+	levels  chan sensorLevel
+	done    chan struct{}
+
+	// Owned by the run goroutine.
+	state        comm.DoorState
+	closed, open bool
+	lastDir      comm.DoorState
+}
+
+// doorEvent is the outcome of a machine input.
+type doorEvent struct {
+	state  comm.DoorState
+	reason string
+	fault  bool
+}
+
+func newDoorMachine(door *doorState, c *Config) *doorMachine {
+	return &doorMachine{
+		door:      door,
+		travel:    time.Duration(c.Door.TravelTime),
+		hasClosed: c.Sensors.Closed != nil,
+		hasOpen:   c.Sensors.Open != nil,
+		pressed:   make(chan chan struct{}),
+		levels:    make(chan sensorLevel, 4),
+		done:      make(chan struct{}),
+		state:     comm.DoorState_UNKNOWN,
+	}
+}
+
+// press records that the relay was pressed. It returns once the door
+// state reflects the press.
+func (m *doorMachine) press() {
+	done := make(chan struct{})
+	select {
+	case m.pressed <- done:
+	case <-m.done:
+		return
+	}
+	select {
+	case <-done:
+	case <-m.done:
+	}
+}
+
+// level records a debounced switch level.
+func (m *doorMachine) level(l sensorLevel) {
+	select {
+	case m.levels <- l:
+	case <-m.done:
+	}
+}
+
+func (m *doorMachine) Close() error {
+	close(m.done)
+	return nil
+}
+
+// run processes machine inputs until the machine is closed.
+func (m *doorMachine) run() {
+	travel := time.NewTimer(m.travel)
+	travel.Stop()
+	defer travel.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case done := <-m.pressed:
+			m.apply(m.onPress(), travel)
+			close(done)
+		case l := <-m.levels:
+			m.apply(m.onLevel(l), travel)
+		case <-travel.C:
+			m.apply(m.onTravelElapsed(), travel)
+		}
+	}
+}
+
+// apply sets the door state from ev, starting the travel timer when the
+// door starts moving.
+func (m *doorMachine) apply(ev doorEvent, travel *time.Timer) {
+	if ev.state == m.state && !ev.fault {
+		return
+	}
+	if ev.fault {
+		logger.Warningf("door %v: %s", ev.state, ev.reason)
+	} else {
+		logger.Infof("door %v: %s", ev.state, ev.reason)
+	}
+	if ev.state == comm.DoorState_OPENING || ev.state == comm.DoorState_CLOSING {
+		m.lastDir = ev.state
+		if m.travel > 0 {
+			travel.Stop()
+			travel.Reset(m.travel)
+		}
+	}
+	m.state = ev.state
+	m.door.set(ev.state, ev.reason, ev.fault)
+}
+
+func (m *doorMachine) same(reason string) doorEvent {
+	return doorEvent{state: m.state, reason: reason}
+}
+
+func (m *doorMachine) onPress() doorEvent {
+	if m.travel <= 0 {
+		return m.same("relay pressed")
+	}
+	switch m.state {
+	case comm.DoorState_CLOSED:
+		return doorEvent{state: comm.DoorState_OPENING, reason: "relay pressed while closed"}
+	case comm.DoorState_OPEN:
+		return doorEvent{state: comm.DoorState_CLOSING, reason: "relay pressed while open"}
+	case comm.DoorState_OPENING:
+		return doorEvent{state: comm.DoorState_STOPPED, reason: "relay pressed while opening"}
+	case comm.DoorState_CLOSING:
+		return doorEvent{state: comm.DoorState_OPENING, reason: "relay pressed while closing, door reverses"}
+	case comm.DoorState_STOPPED:
+		switch m.lastDir {
+		case comm.DoorState_OPENING:
+			return doorEvent{state: comm.DoorState_CLOSING, reason: "relay pressed while stopped after opening"}
+		case comm.DoorState_CLOSING:
+			return doorEvent{state: comm.DoorState_OPENING, reason: "relay pressed while stopped after closing"}
+		}
+	}
+	return doorEvent{state: comm.DoorState_UNKNOWN, reason: "relay pressed, direction unknown"}
+}
+
+func (m *doorMachine) onLevel(l sensorLevel) doorEvent {
+	if l.open {
+		m.open = l.active
+	} else {
+		m.closed = l.active
+	}
+	if m.travel <= 0 {
+		state, reason := sensorState(m.closed, m.open, m.hasOpen)
+		return doorEvent{state: state, reason: reason}
+	}
+	switch {
+	case m.closed && m.open:
+		return doorEvent{state: comm.DoorState_UNKNOWN, reason: "open and closed switches are both active", fault: true}
+	case m.closed:
+		return doorEvent{state: comm.DoorState_CLOSED, reason: "closed switch active"}
+	case m.open:
+		return doorEvent{state: comm.DoorState_OPEN, reason: "open switch active"}
+	}
+	// A switch was released.
+	switch m.state {
+	case comm.DoorState_CLOSED:
+		return doorEvent{state: comm.DoorState_OPENING, reason: "closed switch released"}
+	case comm.DoorState_OPEN:
+		return doorEvent{state: comm.DoorState_CLOSING, reason: "open switch released"}
+	case comm.DoorState_UNKNOWN:
+		if !l.open && !m.hasOpen {
+			return doorEvent{state: comm.DoorState_OPEN, reason: "closed switch not active"}
+		}
+		return m.same("neither switch active")
+	}
+	return m.same("switch released while moving")
+}
+
+func (m *doorMachine) onTravelElapsed() doorEvent {
+	switch m.state {
+	case comm.DoorState_OPENING:
+		if m.hasOpen {
+			return doorEvent{state: comm.DoorState_STOPPED, reason: "failed to reach open within travel time", fault: true}
+		}
+		return doorEvent{state: comm.DoorState_OPEN, reason: "travel time elapsed while opening"}
+	case comm.DoorState_CLOSING:
+		if m.hasClosed {
+			return doorEvent{state: comm.DoorState_STOPPED, reason: "failed to reach closed within travel time", fault: true}
+		}
+		return doorEvent{state: comm.DoorState_CLOSED, reason: "travel time elapsed while closing"}
+	}
+	return m.same("travel time elapsed")
+}
+
+// sensorState returns the door state implied by the switch levels alone.
+func sensorState(closed, open, hasOpen bool) (comm.DoorState, string) {
+	switch {
+	case closed && open:
+		return comm.DoorState_UNKNOWN, "open and closed switches are both active"
+	case closed:
+		return comm.DoorState_CLOSED, "closed switch active"
+	case open:
+		return comm.DoorState_OPEN, "open switch active"
+	case hasOpen:
+		return comm.DoorState_BETWEEN, "neither switch active"
+	}
+	return comm.DoorState_OPEN, "closed switch released"
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kardianos/garage/comm"
+)
+
+// machineInput is an input to the door machine in a transition test.
+type machineInput int
+
+const (
+	inPress machineInput = iota
+	inClosedActive
+	inClosedReleased
+	inOpenActive
+	inOpenReleased
+	inTravelElapsed
+)
+
+func (in machineInput) String() string {
+	return [...]string{"press", "closed active", "closed released", "open active", "open released", "travel elapsed"}[in]
+}
+
+func TestDoorMachineTransitions(t *testing.T) {
+	const (
+		unknown = comm.DoorState_UNKNOWN
+		closed  = comm.DoorState_CLOSED
+		opened  = comm.DoorState_OPEN
+		opening = comm.DoorState_OPENING
+		closing = comm.DoorState_CLOSING
+		stopped = comm.DoorState_STOPPED
+		between = comm.DoorState_BETWEEN
+	)
+	type switches struct {
+		hasClosed, hasOpen bool
+		closed, open       bool
+	}
+	var (
+		none       = switches{}
+		closedOnly = switches{hasClosed: true}
+		both       = switches{hasClosed: true, hasOpen: true}
+	)
+	list := []struct {
+		name           string
+		travel         time.Duration
+		sw             switches
+		state, lastDir comm.DoorState
+		in             machineInput
+		want           comm.DoorState
+		fault          bool
+	}{
+		{name: "press closed", sw: none, state: closed, in: inPress, want: opening},
+		{name: "press open", sw: none, state: opened, in: inPress, want: closing},
+		{name: "press opening", sw: none, state: opening, in: inPress, want: stopped},
+		{name: "press closing", sw: none, state: closing, in: inPress, want: opening},
+		{name: "press stopped opening", sw: none, state: stopped, lastDir: opening, in: inPress, want: closing},
+		{name: "press stopped closing", sw: none, state: stopped, lastDir: closing, in: inPress, want: opening},
+		{name: "press unknown", sw: none, state: unknown, in: inPress, want: unknown},
+		{name: "press without travel", travel: -1, sw: none, state: opened, in: inPress, want: opened},
+
+		{name: "closed switch", sw: closedOnly, state: closing, in: inClosedActive, want: closed},
+		{name: "closed released", sw: switches{hasClosed: true, closed: true}, state: closed, in: inClosedReleased, want: opening},
+		{name: "closed released at start", sw: closedOnly, state: unknown, in: inClosedReleased, want: opened},
+		{name: "open switch", sw: both, state: opening, in: inOpenActive, want: opened},
+		{name: "open released", sw: switches{hasClosed: true, hasOpen: true, open: true}, state: opened, in: inOpenReleased, want: closing},
+		{name: "neither at start", sw: both, state: unknown, in: inClosedReleased, want: unknown},
+		{name: "released while moving", sw: both, state: opening, in: inClosedReleased, want: opening},
+		{name: "both active", sw: switches{hasClosed: true, hasOpen: true, closed: true}, state: closed, in: inOpenActive, want: unknown, fault: true},
+		{name: "levels without travel", travel: -1, sw: both, state: unknown, in: inClosedReleased, want: between},
+		{name: "closed level without travel", travel: -1, sw: both, state: unknown, in: inClosedActive, want: closed},
+
+		{name: "opened by time", sw: closedOnly, state: opening, in: inTravelElapsed, want: opened},
+		{name: "closed by time", sw: none, state: closing, in: inTravelElapsed, want: closed},
+		{name: "open switch missed", sw: both, state: opening, in: inTravelElapsed, want: stopped, fault: true},
+		{name: "closed switch missed", sw: closedOnly, state: closing, in: inTravelElapsed, want: stopped, fault: true},
+		{name: "time while stopped", sw: none, state: stopped, in: inTravelElapsed, want: stopped},
+	}
+	for _, item := range list {
+		travel := item.travel
+		if travel == 0 {
+			travel = 10 * time.Second
+		} else if travel < 0 {
+			travel = 0
+		}
+		m := &doorMachine{
+			travel:    travel,
+			hasClosed: item.sw.hasClosed,
+			hasOpen:   item.sw.hasOpen,
+			state:     item.state,
+			closed:    item.sw.closed,
+			open:      item.sw.open,
+			lastDir:   item.lastDir,
+		}
+		var ev doorEvent
+		switch item.in {
+		case inPress:
+			ev = m.onPress()
+		case inClosedActive, inClosedReleased:
+			ev = m.onLevel(sensorLevel{open: false, active: item.in == inClosedActive})
+		case inOpenActive, inOpenReleased:
+			ev = m.onLevel(sensorLevel{open: true, active: item.in == inOpenActive})
+		case inTravelElapsed:
+			ev = m.onTravelElapsed()
+		}
+		if ev.state != item.want || ev.fault != item.fault {
+			t.Errorf("%s: %v on %v got %v fault %t (%s), want %v fault %t",
+				item.name, item.in, item.state, ev.state, ev.fault, ev.reason, item.want, item.fault)
+		}
+		if len(ev.reason) == 0 {
+			t.Errorf("%s: no reason", item.name)
+		}
+	}
+}
+
+// waitState waits for the door to reach want.
+func waitState(t *testing.T, door *doorState, want comm.DoorState) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		state, _ := door.motion()
+		if state == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("door is %v, want %v", state, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDoorMachineRun(t *testing.T) {
+	c := defaultConfig()
+	c.Door.TravelTime = Duration(100 * time.Millisecond)
+	c.Sensors.Closed = &InputConfig{}
+	door := newDoorState()
+	m := newDoorMachine(door, c)
+	go m.run()
+	defer m.Close()
+
+	m.level(sensorLevel{active: true})
+	waitState(t, door, comm.DoorState_CLOSED)
+	m.press()
+	waitState(t, door, comm.DoorState_OPENING)
+	m.level(sensorLevel{active: false})
+	// Without an open switch the door is open once the travel time elapses.
+	waitState(t, door, comm.DoorState_OPEN)
+	m.press()
+	waitState(t, door, comm.DoorState_CLOSING)
+	// The closed switch never reports, so closing fails.
+	waitState(t, door, comm.DoorState_STOPPED)
+	if st := door.get(); !st.Fault {
+		t.Errorf("stopped without a fault: %s", st.Reason)
+	}
+}
@@ -2,127 +2,333 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"os"
-	"os/signal"
+	"path/filepath"
 	"time"
 
 	"github.com/kardianos/garage/comm"
+	"github.com/kardianos/garage/comm/client"
 
+	"github.com/kardianos/service"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 )
 
-func main() {
-	sig := runOutput()
+var _ service.Interface = &program{}
 
-	ctx, quit := context.WithCancel(context.Background())
-	ossigs := make(chan os.Signal)
-	signal.Notify(ossigs, os.Interrupt, os.Kill)
+type program struct {
+	config *Config
+
+	quit       func()
+	done       chan struct{} // Closed when the mirror connection stops.
+	outputDone chan struct{} // Closed when the relay is released.
+	stopping   chan struct{} // Closed to stop the watchdog.
+
+	health *health
+
+	il      *interlock
+	machine *doorMachine
+	sens    *sensors
+	local   *grpc.Server
+}
+
+func (p *program) Start(svc service.Service) error {
+	config := p.config
+	act, err := openActuator(config)
+	if err != nil {
+		return err
+	}
+	logger.Infof("actuator %s on pin %d, active low %t, pulse %v, gap %v",
+		config.Actuator.Driver, config.Actuator.Pin, config.Actuator.ActiveLow,
+		time.Duration(config.Actuator.PulseWidth), time.Duration(config.Actuator.Gap))
+
+	p.health = &health{sentAt: time.Now()}
+	p.stopping = make(chan struct{})
+	door := newDoorState()
+	p.machine = newDoorMachine(door, config)
+	go p.machine.run()
+
+	p.outputDone = make(chan struct{})
+	sig := make(chan press, 1)
 	go func() {
-		<-ossigs
-		quit()
-		<-time.After(3 * time.Second)
-		os.Exit(0)
+		outputLoop(act, config.Actuator, sig, p.machine.press, p.health)
+		close(p.outputDone)
 	}()
+	p.il = newInterlock(door, sig)
 
-	certpool := x509.NewCertPool()
-	if !certpool.AppendCertsFromPEM(comm.CA()) { // Add CA public cert.
-		panic("failed to add cert")
+	p.sens, err = openSensors(config)
+	if err != nil {
+		p.stop()
+		return err
 	}
-	creds := credentials.NewTLS(&tls.Config{
-		RootCAs: certpool,
-	})
-	dnsName := fmt.Sprintf("%s:%d", comm.Host(), comm.Port())
-	conn, err := grpc.DialContext(ctx, dnsName,
-		grpc.WithTransportCredentials(creds),
-	)
+	if p.sens != nil {
+		err = p.sens.run(p.machine.level, p.il.setSafety)
+		if err != nil {
+			p.stop()
+			return fmt.Errorf("sensors %v", err)
+		}
+	}
+	p.local, err = serveLocal(config, p.il, door)
 	if err != nil {
-		log.Fatal("dial", err)
-		return
+		p.stop()
+		return err
 	}
-	defer conn.Close()
 
-	gc := comm.NewGarageClient(conn)
+	ctx, quit := context.WithCancel(context.Background())
+	p.quit = quit
+	p.done = make(chan struct{})
+
+	cc, err := mirrorClientConfig(ctx, config.Mirror)
+	if err != nil {
+		p.stop()
+		return err
+	}
+	conn, err := client.Dial(ctx, cc)
+	if err != nil {
+		p.stop()
+		return fmt.Errorf("dial %v", err)
+	}
 
 	s := &server{
-		sig: sig,
-		gc:  gc,
+		il:     p.il,
+		gc:     comm.NewGarageClient(conn),
+		info:   config.info(),
+		door:   door,
+		health: p.health,
 	}
+	go func() {
+		defer close(p.done)
+		defer conn.Close()
+		s.run(ctx)
+	}()
 
-	for {
+	err = runWatchdog(config, p.health, p.stopping)
+	if err != nil {
+		p.stop()
+		return err
+	}
+	err = sdNotify("READY=1")
+	if err != nil {
+		logger.Warning("systemd notify ", err)
+	}
+	return nil
+}
+
+func (p *program) Stop(svc service.Service) error {
+	// Stop should not block. Return within a few seconds.
+	p.stop()
+	timeout := time.After(3 * time.Second)
+	for _, done := range []chan struct{}{p.done, p.outputDone} {
+		if done == nil {
+			continue
+		}
 		select {
+		case <-done:
+		case <-timeout:
+			return fmt.Errorf("timed out waiting for shutdown")
+		}
+	}
+	return nil
+}
+
+// stop disconnects from the mirror, stops accepting commands and
+// releases the inputs. The relay is released by the output loop
+// once any press in progress completes.
+func (p *program) stop() {
+	sdNotify("STOPPING=1")
+	if p.stopping != nil {
+		select {
+		case <-p.stopping:
 		default:
-		case <-ctx.Done():
-			return
+			close(p.stopping)
 		}
-		err = s.Serve(ctx)
+	}
+	if p.quit != nil {
+		p.quit()
+	}
+	if p.local != nil {
+		p.local.GracefulStop()
+	}
+	if p.il != nil {
+		p.il.close()
+	}
+	if p.sens != nil {
+		p.sens.Close()
+	}
+	if p.machine != nil {
+		p.machine.Close()
+	}
+}
+
+var logger service.Logger
+
+func main() {
+	svcFlag := flag.String("service", "", "control the service")
+	configFile := flag.String("config", "", "path to the JSON configuration file")
+	flag.Parse()
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	svcConfig := &service.Config{
+		Name:        "garageopener",
+		DisplayName: "Garage Opener",
+		Description: "Operates the garage door for the garage mirror.",
+	}
+	if len(*configFile) != 0 {
+		abs, err := filepath.Abs(*configFile)
 		if err != nil {
-			log.Println("failed to serve", err)
-			time.Sleep(1 * time.Second)
-			continue
+			log.Fatal(err)
+		}
+		svcConfig.Arguments = []string{"-config", abs}
+	}
+
+	prg := &program{config: config}
+	s, err := service.New(prg, svcConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger, err = s.Logger(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(*svcFlag) != 0 {
+		err := service.Control(s, *svcFlag)
+		if err != nil {
+			log.Printf("Valid actions: %q\n", service.ControlAction)
+			log.Fatal(err)
 		}
+		return
+	}
+	err = s.Run()
+	if err != nil {
+		logger.Error(err)
 	}
 }
 
 type server struct {
-	gc  comm.GarageClient
-	sig chan struct{}
+	gc   comm.GarageClient
+	il   *interlock
+	info *comm.OpenerInfo
+	door *doorState
+
+	health *health
+}
+
+// errReconnect is returned when the mirror asks the opener to reconnect.
+var errReconnect = errors.New("mirror is shutting down")
+
+// reconnectDelay is the longest wait before connecting again after the
+// mirror shuts down. Each opener waits a random part of it, so they do not
+// all connect to the next mirror at once.
+const reconnectDelay = 5 * time.Second
+
+// run keeps the stream to the mirror open until ctx is done.
+func (s *server) run(ctx context.Context) {
+	for {
+		select {
+		default:
+		case <-ctx.Done():
+			return
+		}
+		err := s.Serve(ctx)
+		if err == errReconnect {
+			wait := time.Second + time.Duration(time.Now().UnixNano()%int64(reconnectDelay-time.Second))
+			logger.Infof("%v, reconnecting in %v", err, wait.Round(time.Millisecond))
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if err != nil {
+			logger.Warning("failed to serve ", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(1 * time.Second):
+			}
+		}
+	}
 }
 
 func (s *server) Serve(sctx context.Context) (err error) {
 	ggc, err := s.gc.Garage(sctx)
 	if err != nil {
-		log.Println("Garage", err)
-		return
+		return fmt.Errorf("Garage %v", err)
 	}
 	err = s.runGarageService(ggc)
+	if err == errReconnect {
+		return err
+	}
 	if err != nil {
-		log.Println("Garage Service", err)
-		return
+		return fmt.Errorf("Garage Service %v", err)
 	}
-	return
+	return nil
 }
 
 func (s *server) runGarageService(ggc comm.Garage_GarageClient) error {
+	err := ggc.Send(&comm.FromGarage{
+		TimeUnix: time.Now().Unix(),
+		Info:     s.info,
+		Status:   s.door.get(),
+	})
+	if err != nil {
+		return err
+	}
+	s.health.sent()
+	results := make(chan *comm.CommandResult, 3)
 	go func() {
 		ticker := time.NewTicker(10 * time.Second)
 		defer ticker.Stop()
 		defer ggc.CloseSend()
 
 		for {
+			var msg *comm.FromGarage
 			select {
 			case <-ggc.Context().Done():
 				return
 			case now := <-ticker.C:
-				ggc.Send(&comm.FromGarage{TimeUnix: now.Unix()})
+				msg = &comm.FromGarage{TimeUnix: now.Unix()}
+			case <-s.door.changed:
+				msg = &comm.FromGarage{TimeUnix: time.Now().Unix(), Status: s.door.get()}
+			case res := <-results:
+				msg = &comm.FromGarage{TimeUnix: time.Now().Unix(), Result: res}
+			}
+			if ggc.Send(msg) == nil {
+				s.health.sent()
 			}
 		}
 	}()
 	for {
-		select {
-		default:
-		case <-ggc.Context().Done():
-			return nil
-		}
-
 		recv, err := ggc.Recv()
 		if err != nil {
-			log.Println("Recv", err)
+			select {
+			case <-ggc.Context().Done():
+				return nil
+			default:
+			}
 			return err
 		}
+		if recv.Reconnect {
+			return errReconnect
+		}
 		if recv.Toggle {
-			s.sig <- struct{}{}
+			res := s.il.command(recv.Action)
+			if !res.OK {
+				logger.Warningf("%v: %s", recv.Action, res.Message)
+			}
+			if recv.ID != 0 {
+				res.ID = recv.ID
+				select {
+				case results <- res:
+				case <-ggc.Context().Done():
+					return nil
+				}
+			}
 		}
 	}
-	return nil
-}
-
-func runOutput() chan struct{} {
-	sig := make(chan struct{}, 3)
-	go outputLoop(sig)
-	return sig
 }
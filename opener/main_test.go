@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kardianos/service"
+)
+
+func TestMain(m *testing.M) {
+	logger = service.ConsoleLogger
+	os.Exit(m.Run())
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// press is a press of the door button queued by the interlock. Its
+// outcome is sent on done once the button is released.
+type press struct {
+	done chan error
+}
+
+// outputLoop presses the door button once for each press received.
+// The pressed function is called as soon as each press is released,
+// before its outcome is sent. Each press is recorded in h so a stuck
+// relay can be detected.
+func outputLoop(act Actuator, ac ActuatorConfig, sig <-chan press, pressed func(), h *health) {
+	defer act.Close()
+	width, gap := time.Duration(ac.PulseWidth), time.Duration(ac.Gap)
+	for p := range sig {
+		h.busy(true)
+		err := pulse(act, width)
+		if err != nil {
+			logger.Error("pulse ", err)
+			err = fmt.Errorf("failed to press the door button: %v", err)
+		} else {
+			pressed()
+		}
+		p.done <- err
+		// Wait for the opener to be ready for the next press.
+		time.Sleep(gap)
+		h.busy(false)
+	}
+}
+
+// pulse presses and releases the door button.
+func pulse(act Actuator, width time.Duration) error {
+	err := act.Set(true)
+	if err != nil {
+		return err
+	}
+	time.Sleep(width)
+	return act.Set(false)
+}
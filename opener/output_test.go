@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutputLoop(t *testing.T) {
+	list := []struct {
+		name    string
+		presses int
+		width   time.Duration
+		gap     time.Duration
+	}{
+		{name: "none", presses: 0, width: 50 * time.Millisecond},
+		{name: "one", presses: 1, width: 50 * time.Millisecond, gap: 20 * time.Millisecond},
+		{name: "queued", presses: 3, width: 50 * time.Millisecond, gap: 30 * time.Millisecond},
+		{name: "no gap", presses: 2, width: 50 * time.Millisecond},
+	}
+	for _, item := range list {
+		act := &mockActuator{}
+		ac := ActuatorConfig{PulseWidth: Duration(item.width), Gap: Duration(item.gap)}
+		sig := make(chan press, item.presses)
+		var pressedAt []time.Time
+		h := &health{}
+		done := make(chan struct{})
+		go func() {
+			outputLoop(act, ac, sig, func() { pressedAt = append(pressedAt, time.Now()) }, h)
+			close(done)
+		}()
+		var queued []press
+		for i := 0; i < item.presses; i++ {
+			p := press{done: make(chan error, 1)}
+			queued = append(queued, p)
+			sig <- p
+		}
+		close(sig)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: output loop did not finish", item.name)
+		}
+
+		for i, p := range queued {
+			select {
+			case err := <-p.done:
+				if err != nil {
+					t.Errorf("%s: press %d: %v", item.name, i, err)
+				}
+			default:
+				t.Errorf("%s: press %d has no outcome", item.name, i)
+			}
+		}
+		if !act.closed {
+			t.Errorf("%s: actuator not closed", item.name)
+		}
+		if n := act.Presses(); n != item.presses {
+			t.Errorf("%s: got %d presses, want %d", item.name, n, item.presses)
+		}
+		if len(pressedAt) != item.presses {
+			t.Errorf("%s: pressed called %d times, want %d", item.name, len(pressedAt), item.presses)
+		}
+		events := act.Events()
+		if len(events) != 2*item.presses {
+			t.Errorf("%s: got %d output changes, want %d", item.name, len(events), 2*item.presses)
+			continue
+		}
+		for i := 0; i < len(events); i += 2 {
+			press, release := events[i], events[i+1]
+			if !press.Active || release.Active {
+				t.Errorf("%s: press %d drove %t then %t", item.name, i/2, press.Active, release.Active)
+			}
+			if d := release.At.Sub(press.At); d < item.width {
+				t.Errorf("%s: press %d held for %v, want at least %v", item.name, i/2, d, item.width)
+			}
+			if i == 0 {
+				continue
+			}
+			if d := press.At.Sub(events[i-1].At); d < item.gap {
+				t.Errorf("%s: press %d came %v after the last, want at least %v", item.name, i/2, d, item.gap)
+			}
+		}
+		if err := h.output(time.Now().Add(time.Hour), time.Second); err != nil {
+			t.Errorf("%s: output still busy: %v", item.name, err)
+		}
+	}
+}
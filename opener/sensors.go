@@ -0,0 +1,107 @@
+package main
+
+import (
+	"time"
+)
+
+// sensors watches the door position switches and the safety inputs.
+type sensors struct {
+	closed, open Input // Either may be nil.
+	safety       []safetyInput
+	debounce     time.Duration
+	done         chan struct{}
+}
+
+type safetyInput struct {
+	name string
+	in   Input
+}
+
+// openSensors opens the configured inputs. It returns nil if the
+// opener has no inputs.
+func openSensors(c *Config) (*sensors, error) {
+	sc := c.Sensors
+	if len(sc.Driver) == 0 {
+		return nil, nil
+	}
+	s := &sensors{
+		debounce: time.Duration(sc.Debounce),
+		done:     make(chan struct{}),
+	}
+	var err error
+	if sc.Closed != nil {
+		s.closed, err = openInput(c, sc.Closed)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	if sc.Open != nil {
+		s.open, err = openInput(c, sc.Open)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	for i := range c.Safety {
+		sf := &c.Safety[i]
+		in, err := openInput(c, &sf.InputConfig)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.safety = append(s.safety, safetyInput{name: sf.Name, in: in})
+	}
+	return s, nil
+}
+
+// sensorLevel is a debounced switch level.
+type sensorLevel struct {
+	open   bool // Set for the open switch, clear for the closed switch.
+	active bool
+}
+
+// run watches the inputs and reports each debounced level change,
+// switches to report and safety inputs to safety.
+func (s *sensors) run(report func(sensorLevel), safety func(name string, active bool)) error {
+	if s.closed != nil {
+		err := watchInput(s.closed, s.debounce, s.done, func(active bool) {
+			report(sensorLevel{open: false, active: active})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if s.open != nil {
+		err := watchInput(s.open, s.debounce, s.done, func(active bool) {
+			report(sensorLevel{open: true, active: active})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, sf := range s.safety {
+		name := sf.name
+		err := watchInput(sf.in, s.debounce, s.done, func(active bool) {
+			safety(name, active)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sensors) Close() error {
+	close(s.done)
+	for _, sf := range s.safety {
+		sf.in.Close()
+	}
+	if s.open != nil {
+		s.open.Close()
+	}
+	if s.closed != nil {
+		s.closed.Close()
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+func init() {
+	registerActuator("sim", func(c *Config) (Actuator, error) {
+		return &simActuator{door: simDoorFor(c)}, nil
+	})
+	registerInput("sim", func(c *Config, ic *InputConfig) (Input, error) {
+		return simDoorFor(c).input(c, ic), nil
+	})
+}
+
+var (
+	simMu    sync.Mutex
+	simDoors = map[*Config]*simDoor{}
+)
+
+// simDoorFor returns the simulated door shared by the sim drivers
+// opened for c, starting it on first use. The door stops when the sim
+// actuator is closed.
+func simDoorFor(c *Config) *simDoor {
+	simMu.Lock()
+	defer simMu.Unlock()
+	d := simDoors[c]
+	if d == nil {
+		d = newSimDoor(c.Sim)
+		simDoors[c] = d
+	}
+	return d
+}
+
+// simTick is how often the simulated door moves.
+const simTick = 50 * time.Millisecond
+
+// simDoor models a door driven by a single button. A press starts a
+// closed or stopped door, stops an opening door and reverses a closing
+// door. Closing may be obstructed, which reverses the door, and the
+// switch at the end of travel may fail to report.
+type simDoor struct {
+	sc     SimConfig
+	travel time.Duration
+
+	mu   sync.Mutex
+	rand *rand.Rand
+	// pos is 0 when closed and 1 when open.
+	pos float64
+	// dir is 1 while opening, -1 while closing and 0 while stopped.
+	dir, lastDir int
+	// obstructAt is where closing is obstructed, or -1.
+	obstructAt float64
+	// obstructedUntil is when the obstruction clears.
+	obstructedUntil time.Time
+	// stuck is the switch that fails to report this travel, if any.
+	stuck *simInput
+
+	closed, open *simInput
+	// safety is the first safety input, which sees obstructions.
+	safety *simInput
+	others []*simInput
+
+	done chan struct{}
+}
+
+func newSimDoor(sc SimConfig) *simDoor {
+	seed := sc.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	d := &simDoor{
+		sc:         sc,
+		travel:     time.Duration(sc.TravelTime),
+		rand:       rand.New(rand.NewSource(seed)),
+		lastDir:    1,
+		obstructAt: -1,
+		done:       make(chan struct{}),
+	}
+	if sc.Open {
+		d.pos, d.lastDir = 1, -1
+	}
+	go d.run()
+	return d
+}
+
+// input returns the simulated input for ic, matched by pin.
+func (d *simDoor) input(c *Config, ic *InputConfig) *simInput {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	in := &simInput{door: d, pin: ic.Pin}
+	switch {
+	case c.Sensors.Closed != nil && ic.Pin == c.Sensors.Closed.Pin:
+		d.closed = in
+	case c.Sensors.Open != nil && ic.Pin == c.Sensors.Open.Pin:
+		d.open = in
+	case len(c.Safety) != 0 && ic.Pin == c.Safety[0].Pin:
+		d.safety = in
+	default:
+		d.others = append(d.others, in)
+	}
+	d.update(time.Now())
+	return in
+}
+
+// press is a press of the door button.
+func (d *simDoor) press() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case d.dir == 1:
+		d.lastDir, d.dir = 1, 0
+		logger.Infof("sim: door stopped at %.0f%%", d.pos*100)
+		return
+	case d.dir == -1:
+		d.lastDir, d.dir = -1, 1
+		logger.Infof("sim: door reversed at %.0f%%", d.pos*100)
+		return
+	case d.pos <= 0:
+		d.dir = 1
+	case d.pos >= 1:
+		d.dir = -1
+	default:
+		d.dir = -d.lastDir
+	}
+
+	// Each travel may be obstructed or lose its end switch.
+	d.obstructAt = -1
+	if d.dir == -1 && d.rand.Float64() < d.sc.Obstruction {
+		d.obstructAt = d.pos * (0.1 + 0.8*d.rand.Float64())
+	}
+	d.stuck = nil
+	if d.rand.Float64() < d.sc.SensorFailure {
+		d.stuck = d.closed
+		if d.dir == 1 {
+			d.stuck = d.open
+		}
+		if d.stuck != nil {
+			logger.Warningf("sim: the %s switch will fail to report this travel", d.stuck.role())
+		}
+	}
+	logger.Infof("sim: door %s from %.0f%%", map[int]string{1: "opening", -1: "closing"}[d.dir], d.pos*100)
+}
+
+// run moves the door until it is closed.
+func (d *simDoor) run() {
+	ticker := time.NewTicker(simTick)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-d.done:
+			return
+		case now := <-ticker.C:
+			d.mu.Lock()
+			d.step(now.Sub(last))
+			d.update(now)
+			d.mu.Unlock()
+			last = now
+		}
+	}
+}
+
+// close stops the door and forgets it, so drivers opened later for the
+// same configuration get a new door.
+func (d *simDoor) close() {
+	simMu.Lock()
+	defer simMu.Unlock()
+	for c, other := range simDoors {
+		if other == d {
+			delete(simDoors, c)
+		}
+	}
+	select {
+	case <-d.done:
+	default:
+		close(d.done)
+	}
+}
+
+// step moves the door for elapsed time. The door must be locked.
+func (d *simDoor) step(elapsed time.Duration) {
+	if d.dir == 0 {
+		return
+	}
+	d.pos += float64(d.dir) * float64(elapsed) / float64(d.travel)
+	if d.dir == -1 && d.obstructAt >= 0 && d.pos <= d.obstructAt {
+		logger.Warningf("sim: obstruction at %.0f%%, reversing", d.pos*100)
+		d.obstructAt = -1
+		d.obstructedUntil = time.Now().Add(time.Duration(d.sc.ObstructionTime))
+		d.lastDir, d.dir = -1, 1
+	}
+	switch {
+	case d.pos >= 1:
+		d.pos, d.lastDir, d.dir = 1, 1, 0
+		logger.Info("sim: door open")
+	case d.pos <= 0:
+		d.pos, d.lastDir, d.dir = 0, -1, 0
+		logger.Info("sim: door closed")
+	}
+}
+
+// update sets the input levels from the door position. The door must be locked.
+func (d *simDoor) update(now time.Time) {
+	if d.closed != nil {
+		d.closed.set(d.pos <= 0 && d.stuck != d.closed)
+	}
+	if d.open != nil {
+		d.open.set(d.pos >= 1 && d.stuck != d.open)
+	}
+	if d.safety != nil {
+		d.safety.set(now.Before(d.obstructedUntil))
+	}
+}
+
+// simInput is a switch on the simulated door. A change of level
+// bounces for the configured time before it settles.
+type simInput struct {
+	door *simDoor
+	pin  int
+
+	mu      sync.Mutex
+	level   bool // Level reported now, which may be bouncing.
+	final   bool // Level once settled.
+	gen     int  // Incremented each change to cancel bounces.
+	changed func()
+}
+
+func (in *simInput) role() string {
+	switch in {
+	case in.door.closed:
+		return "closed"
+	case in.door.open:
+		return "open"
+	}
+	return "safety"
+}
+
+// set changes the settled level of the input. The door must be locked.
+func (in *simInput) set(level bool) {
+	in.mu.Lock()
+	if in.final == level {
+		in.mu.Unlock()
+		return
+	}
+	in.final = level
+	in.gen++
+	gen := in.gen
+	in.mu.Unlock()
+
+	bounce := time.Duration(in.door.sc.Bounce)
+	flips := 0
+	if bounce > 0 {
+		flips = 2 * in.door.rand.Intn(3)
+	}
+	in.flip(level, gen)
+	// Bounce back and forth, ending on the new level.
+	for i := 1; i <= flips; i++ {
+		v := level
+		if i%2 == 1 {
+			v = !level
+		}
+		time.AfterFunc(bounce*time.Duration(i)/time.Duration(flips), func() { in.flip(v, gen) })
+	}
+}
+
+func (in *simInput) flip(level bool, gen int) {
+	in.mu.Lock()
+	if gen != in.gen || in.level == level {
+		in.mu.Unlock()
+		return
+	}
+	in.level = level
+	changed := in.changed
+	in.mu.Unlock()
+	if changed != nil {
+		changed()
+	}
+}
+
+func (in *simInput) Get() (bool, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.level, nil
+}
+
+func (in *simInput) Watch(changed func()) error {
+	in.mu.Lock()
+	in.changed = changed
+	in.mu.Unlock()
+	return nil
+}
+
+func (in *simInput) Close() error {
+	return nil
+}
+
+// simActuator presses the button of the simulated door. Like most
+// openers, the door responds when the button is released.
+type simActuator struct {
+	door   *simDoor
+	active bool
+}
+
+func (a *simActuator) Set(active bool) error {
+	if !active && a.active {
+		a.door.press()
+	}
+	a.active = active
+	return nil
+}
+
+func (a *simActuator) Close() error {
+	a.door.close()
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSimDoorStops(t *testing.T) {
+	c := defaultConfig()
+	c.Actuator.Driver = "sim"
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		act, err := openActuator(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in, err := inputs["sim"](c, &InputConfig{Pin: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		in.Close()
+		act.Close()
+	}
+	simMu.Lock()
+	n := len(simDoors)
+	simMu.Unlock()
+	if n != 0 {
+		t.Errorf("%d simulated doors left after closing", n)
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left running, %d before", n, before)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The systemd unit installed by the service package does not enable the
+// watchdog. To use it add a drop-in such as
+// /etc/systemd/system/garageopener.service.d/watchdog.conf:
+//
+//	[Service]
+//	Type=notify
+//	WatchdogSec=30s
+//	Restart=always
+//
+// The opener then sends READY=1 once started and WATCHDOG=1 at half the
+// watchdog interval while the relay output loop is responsive and the
+// mirror stream is working, so a dead connection gets the device
+// restarted. Whether the mirror stream is working is also reported with
+// STATUS, which is shown by systemctl status.
+
+// health tracks whether the mirror stream and the relay output loop
+// are working.
+type health struct {
+	mu sync.Mutex
+	// sentAt is the time of the last successful send to the mirror.
+	// It starts as the start time so the stream has time to connect.
+	sentAt time.Time
+	// busySince is when the output loop started its current press.
+	// It is zero while the loop is waiting for a press.
+	busySince time.Time
+}
+
+// sent records a successful send to the mirror.
+func (h *health) sent() {
+	h.mu.Lock()
+	h.sentAt = time.Now()
+	h.mu.Unlock()
+}
+
+// busy records the output loop starting or finishing a press.
+func (h *health) busy(b bool) {
+	h.mu.Lock()
+	if b {
+		h.busySince = time.Now()
+	} else {
+		h.busySince = time.Time{}
+	}
+	h.mu.Unlock()
+}
+
+// output returns an error if a press has taken longer than limit.
+func (h *health) output(now time.Time, limit time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.busySince.IsZero() && now.Sub(h.busySince) > limit {
+		return fmt.Errorf("output loop stuck in a press for %v", now.Sub(h.busySince))
+	}
+	return nil
+}
+
+// stream returns an error if nothing has been sent to the mirror within timeout.
+func (h *health) stream(now time.Time, timeout time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if d := now.Sub(h.sentAt); d > timeout {
+		return fmt.Errorf("mirror stream silent for %v", d)
+	}
+	return nil
+}
+
+// check returns why the watchdogs should not be fed at now: a press taking
+// longer than outputLimit, or nothing sent to the mirror within
+// streamTimeout.
+func (h *health) check(now time.Time, outputLimit, streamTimeout time.Duration) error {
+	if err := h.output(now, outputLimit); err != nil {
+		return err
+	}
+	return h.stream(now, streamTimeout)
+}
+
+// sdNotify sends state to the systemd notify socket. It does nothing
+// when not started by systemd with Type=notify.
+func sdNotify(state string) error {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if len(name) == 0 {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogInterval returns the systemd watchdog interval, or zero
+// if the watchdog is not enabled for this process.
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); len(pid) != 0 && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// streamCheckInterval is how often the mirror stream is checked for the
+// systemd status.
+const streamCheckInterval = 10 * time.Second
+
+// runWatchdog notifies the systemd watchdog and pets the hardware watchdog
+// until done is closed. Each is only fed while the relay output loop is
+// healthy and the mirror stream has sent within the stream timeout, which
+// is the grace a connection has to recover before the device restarts.
+// The hardware watchdog is disarmed on a clean stop.
+func runWatchdog(c *Config, h *health, done chan struct{}) error {
+	ac, wc := c.Actuator, c.Watchdog
+	// A press should never take much longer than the pulse and gap.
+	outputLimit := time.Duration(ac.PulseWidth) + time.Duration(ac.Gap) + 10*time.Second
+	streamTimeout := time.Duration(wc.StreamTimeout)
+
+	var tickers []*time.Ticker
+	var sdTick <-chan time.Time
+	if d := sdWatchdogInterval(); d > 0 {
+		t := time.NewTicker(d / 2)
+		tickers = append(tickers, t)
+		sdTick = t.C
+	}
+
+	var statusTick <-chan time.Time
+	if len(os.Getenv("NOTIFY_SOCKET")) != 0 {
+		t := time.NewTicker(streamCheckInterval)
+		tickers = append(tickers, t)
+		statusTick = t.C
+	}
+
+	var dev *os.File
+	var devTick <-chan time.Time
+	if len(wc.Device) != 0 {
+		var err error
+		dev, err = os.OpenFile(wc.Device, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("watchdog device %v", err)
+		}
+		t := time.NewTicker(time.Duration(wc.Interval))
+		tickers = append(tickers, t)
+		devTick = t.C
+		logger.Infof("petting watchdog %s every %v", wc.Device, time.Duration(wc.Interval))
+	}
+	if len(tickers) == 0 {
+		return nil
+	}
+
+	go func() {
+		for _, t := range tickers {
+			defer t.Stop()
+		}
+		var lastErr, streamErr error
+		for {
+			select {
+			case <-done:
+				if dev != nil {
+					// The magic close character disarms the watchdog.
+					dev.Write([]byte("V"))
+					dev.Close()
+				}
+				return
+			case now := <-sdTick:
+				if h.check(now, outputLimit, streamTimeout) != nil {
+					continue
+				}
+				err := sdNotify("WATCHDOG=1")
+				if err != nil {
+					logger.Warning("systemd watchdog ", err)
+				}
+			case now := <-statusTick:
+				err := h.stream(now, streamTimeout)
+				if (err == nil) == (streamErr == nil) {
+					continue
+				}
+				streamErr = err
+				status := "connected to the mirror"
+				if err != nil {
+					logger.Warning(err)
+					status = err.Error()
+				} else {
+					logger.Info("mirror stream working again")
+				}
+				sdNotify("STATUS=" + status)
+			case now := <-devTick:
+				err := h.check(now, outputLimit, streamTimeout)
+				if err != nil {
+					if lastErr == nil {
+						logger.Errorf("not petting watchdog: %v", err)
+					}
+					lastErr = err
+					continue
+				}
+				if lastErr != nil {
+					logger.Info("petting watchdog again")
+					lastErr = nil
+				}
+				_, err = dev.Write([]byte{0})
+				if err != nil {
+					logger.Error("watchdog device ", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
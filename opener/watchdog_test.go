@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck(t *testing.T) {
+	now := time.Now()
+	list := []struct {
+		name      string
+		sentAt    time.Time
+		busySince time.Time
+		ok        bool
+	}{
+		{name: "healthy", sentAt: now.Add(-time.Second), ok: true},
+		{name: "pressing", sentAt: now, busySince: now.Add(-time.Second), ok: true},
+		{name: "stream in grace", sentAt: now.Add(-50 * time.Second), ok: true},
+		{name: "stream stale", sentAt: now.Add(-2 * time.Minute), ok: false},
+		{name: "output stuck", sentAt: now, busySince: now.Add(-time.Minute), ok: false},
+	}
+	for _, item := range list {
+		h := &health{sentAt: item.sentAt, busySince: item.busySince}
+		err := h.check(now, 15*time.Second, time.Minute)
+		if (err == nil) != item.ok {
+			t.Errorf("%s: got %v, want ok %t", item.name, err, item.ok)
+		}
+	}
+}
+
+func TestRunWatchdogStaleStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watchdog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// A plain file stands in for the device, each pet adds a byte.
+	dev := filepath.Join(dir, "watchdog")
+	err = ioutil.WriteFile(dev, nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pets := func() int64 {
+		fi, err := os.Stat(dev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.Size()
+	}
+
+	const streamTimeout = 200 * time.Millisecond
+	c := defaultConfig()
+	c.Watchdog.Device = dev
+	c.Watchdog.Interval = Duration(10 * time.Millisecond)
+	c.Watchdog.StreamTimeout = Duration(streamTimeout)
+	h := &health{sentAt: time.Now()}
+	done := make(chan struct{})
+	err = runWatchdog(c, h, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	// While the stream sends, the watchdog is petted.
+	for i := 0; i < 10; i++ {
+		h.sent()
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := pets(); n == 0 {
+		t.Fatal("not petted while the stream was working")
+	}
+	// Within the grace the stream has to recover, it still is.
+	before := pets()
+	time.Sleep(streamTimeout / 2)
+	if n := pets(); n == before {
+		t.Error("not petted within the stream grace")
+	}
+	// Once the stream is stale the pets stop.
+	time.Sleep(streamTimeout)
+	stale := pets()
+	time.Sleep(100 * time.Millisecond)
+	if n := pets(); n != stale {
+		t.Errorf("petted %d times with the stream stale", n-stale)
+	}
+	// A send resumes them.
+	h.sent()
+	time.Sleep(50 * time.Millisecond)
+	if n := pets(); n == stale {
+		t.Error("not petted after the stream recovered")
+	}
+}